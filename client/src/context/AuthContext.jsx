@@ -13,13 +13,29 @@ function getTokenFromUrl() {
   return token;
 }
 
-// Clean the URL after extracting token (remove ?token=xxx)
+// Check for a pairing code in URL query params (from `nappctl auth pair`)
+function getPairingCodeFromUrl() {
+  const params = new URLSearchParams(window.location.search);
+  return params.get('pair');
+}
+
+// Clean the URL after extracting token (remove ?token=xxx or ?pair=xxx)
 function cleanUrlToken() {
   const url = new URL(window.location.href);
   url.searchParams.delete('token');
+  url.searchParams.delete('pair');
   window.history.replaceState({}, '', url.pathname + url.search);
 }
 
+// Name shown for this device in the server's paired sessions
+function getDeviceName() {
+  const ua = navigator.userAgent;
+  if (/iPhone/.test(ua)) return 'iPhone';
+  if (/iPad/.test(ua)) return 'iPad';
+  if (/Android/.test(ua)) return 'Android';
+  return navigator.platform || 'Browser';
+}
+
 export function AuthProvider({ children }) {
   const [token, setToken] = useState(() => {
     // First check URL for token (QR code flow)
@@ -63,7 +79,15 @@ export function AuthProvider({ children }) {
     addDebugLog(`token state: ${token ? 'present' : 'absent'}`);
     addDebugLog(`serverUrl: ${serverUrl}`);
     
-    if (urlToken && !autoConnectAttempted) {
+    const pairingCode = getPairingCodeFromUrl();
+    addDebugLog(`pairingCode: ${pairingCode ? 'present' : 'absent'}`);
+
+    if (pairingCode && !autoConnectAttempted) {
+      // Redeem the pairing code for a session token, then auto-connect with it
+      addDebugLog('Starting pairing from URL code');
+      setAutoConnectAttempted(true);
+      pairFromUrl(pairingCode);
+    } else if (urlToken && !autoConnectAttempted) {
       // Auto-connect with URL token
       addDebugLog('Starting auto-connect from URL token');
       setAutoConnectAttempted(true);
@@ -77,6 +101,34 @@ export function AuthProvider({ children }) {
     }
   }, []);
 
+  async function pairFromUrl(code) {
+    const currentUrl = `${window.location.protocol}//${window.location.host}`;
+    try {
+      addDebugLog(`Pairing with: ${currentUrl}`);
+      const response = await fetch(`${currentUrl}/api/auth/pair`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ code, device: getDeviceName() })
+      });
+
+      addDebugLog(`Pairing response status: ${response.status}`);
+      if (!response.ok) {
+        const errorText = await response.text();
+        addDebugLog(`Pairing FAILED: ${errorText}`);
+        cleanUrlToken();
+        setIsLoading(false);
+        return;
+      }
+
+      const { token: sessionToken } = await response.json();
+      await autoConnectFromUrl(sessionToken);
+    } catch (error) {
+      addDebugLog(`EXCEPTION: ${error.message}`);
+      cleanUrlToken();
+      setIsLoading(false);
+    }
+  }
+
   async function autoConnectFromUrl(urlToken) {
     try {
       // Use current host as server URL
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/approvals"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var approvalsCmd = &cobra.Command{
+	Use:     "approvals",
+	Aliases: []string{"approval"},
+	Short:   "Answer agents' tool approvals",
+	Long: `List and answer the tool calls chat agents are blocked on, by hand or
+automatically with a policy.`,
+}
+
+var approvalsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List tool calls awaiting approval",
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		client := mustAPIClient(cmd)
+
+		pending, err := client.PendingApprovals(context.Background())
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(pending)
+			return
+		}
+
+		if len(pending) == 0 {
+			color.Yellow("No tool calls awaiting approval")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Conversation", "Topic", "Tool", "Request", "Requested"})
+		table.SetBorder(false)
+		table.SetColumnSeparator("")
+
+		for _, a := range pending {
+			requested := ""
+			if a.RequestedAt > 0 {
+				requested = formatTime(a.RequestedAt.Time())
+			}
+			table.Append([]string{a.ConversationID, a.Topic, a.ToolName, describeToolCall(&a), requested})
+		}
+
+		table.Render()
+	},
+}
+
+var approvalsWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Answer tool approvals with a policy",
+	Long: `Poll the server for tool calls awaiting approval and decide each with the
+policy (default: approvals.yaml in the data directory, or the built-in
+policy printed by 'nappctl approvals policy --default').
+
+Calls the policy allows or denies are answered at once. Calls it asks
+about are shown as a desktop dialog (osascript on macOS, zenity or kdialog
+on Linux); without one, or with --no-prompt, they are left for the mobile
+app. Every decision is logged with the conversation ID and tool name.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		policyFile, _ := cmd.Flags().GetString("policy")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		noPrompt, _ := cmd.Flags().GetBool("no-prompt")
+		promptTimeout, _ := cmd.Flags().GetDuration("prompt-timeout")
+
+		policy, source := mustLoadPolicy(policyFile)
+		client := mustAPIClient(cmd)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		w := &approvalWatcher{
+			client:        client,
+			policy:        policy,
+			dryRun:        dryRun,
+			prompt:        !noPrompt && !dryRun,
+			promptTimeout: promptTimeout,
+			handled:       map[string]bool{},
+			prompting:     map[string]bool{},
+			answers:       make(chan promptAnswer),
+		}
+
+		color.Green("✓ Watching for tool approvals (policy: %s)", source)
+		if dryRun {
+			color.Yellow("Dry run: decisions are logged but not sent")
+		} else if w.prompt && !approvals.PromptAvailable() {
+			color.Yellow("Warning: no desktop dialog (osascript, zenity or kdialog) available; calls to ask about are left for the app")
+			w.prompt = false
+		}
+		if err := w.run(ctx, interval); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var approvalsPolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Print the approvals policy",
+	Long: `Validate and print the policy approvals watch uses. Start your own with:
+
+  nappctl approvals policy --default > ~/.napptrapp/approvals.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		policyFile, _ := cmd.Flags().GetString("policy")
+		builtin, _ := cmd.Flags().GetBool("default")
+
+		if builtin {
+			fmt.Print(approvals.DefaultPolicy)
+			return
+		}
+
+		_, source := mustLoadPolicy(policyFile)
+		if source == "built-in" {
+			fmt.Fprintln(os.Stderr, color.HiBlackString("# No policy file, using the built-in policy"))
+			fmt.Print(approvals.DefaultPolicy)
+			return
+		}
+
+		data, err := os.ReadFile(source)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, color.HiBlackString("# %s", source))
+		fmt.Print(string(data))
+	},
+}
+
+func init() {
+	addRemoteFlags(approvalsCmd)
+	approvalsCmd.PersistentFlags().String("policy", "", "Policy file (default: approvals.yaml in the data directory)")
+
+	addOutputFlag(approvalsListCmd)
+
+	approvalsWatchCmd.Flags().Duration("interval", 2*time.Second, "Polling interval")
+	approvalsWatchCmd.Flags().Bool("dry-run", false, "Log decisions without answering")
+	approvalsWatchCmd.Flags().Bool("no-prompt", false, "Leave calls the policy asks about to the mobile app")
+	approvalsWatchCmd.Flags().Duration("prompt-timeout", 2*time.Minute, "How long a desktop dialog waits for an answer")
+
+	approvalsPolicyCmd.Flags().Bool("default", false, "Print the built-in policy")
+
+	approvalsCmd.AddCommand(approvalsListCmd)
+	approvalsCmd.AddCommand(approvalsWatchCmd)
+	approvalsCmd.AddCommand(approvalsPolicyCmd)
+}
+
+// mustLoadPolicy loads the policy file, or approvals.yaml in the data
+// directory when file is empty, falling back to the built-in policy if
+// that does not exist. It returns the policy and where it came from.
+func mustLoadPolicy(file string) (*approvals.Policy, string) {
+	explicit := file != ""
+	if !explicit {
+		dataDir, err := config.ResolveDataDir()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		file = config.GetApprovalPolicyPath(dataDir)
+	}
+
+	policy, err := approvals.Load(file)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return approvals.Default(), "built-in"
+	}
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	return policy, file
+}
+
+// promptAnswer is the outcome of a desktop dialog.
+type promptAnswer struct {
+	approval *api.PendingApproval
+	allowed  bool
+	err      error
+}
+
+// approvalWatcher polls for pending approvals and answers them.
+type approvalWatcher struct {
+	client        *api.Client
+	policy        *approvals.Policy
+	dryRun        bool
+	prompt        bool
+	promptTimeout time.Duration
+
+	// handled holds the approvals already decided or left for the app;
+	// prompting the ones with an open dialog.
+	handled   map[string]bool
+	prompting map[string]bool
+	answers   chan promptAnswer
+}
+
+func (w *approvalWatcher) run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx); err != nil {
+			if api.IsUnauthorized(err) {
+				return err
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			logApprovalError(err)
+		}
+
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case answer := <-w.answers:
+				w.finishPrompt(ctx, answer)
+			case <-ticker.C:
+				waiting = false
+			}
+		}
+	}
+}
+
+// poll decides the approvals that appeared since the last poll.
+func (w *approvalWatcher) poll(ctx context.Context) error {
+	pending, err := w.client.PendingApprovals(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]bool{}
+	for i := range pending {
+		a := &pending[i]
+		key := a.ConversationID + "\x00" + a.ToolUseID
+		current[key] = true
+		if w.handled[key] || w.prompting[key] {
+			continue
+		}
+
+		decision := w.policy.Evaluate(approvals.Request{ToolName: a.ToolName, Input: a.Input, ProjectPath: a.ProjectPath})
+		if decision.Action == approvals.Ask && w.prompt {
+			w.prompting[key] = true
+			logApproval(a, decision, "prompting")
+			go w.ask(ctx, a)
+			continue
+		}
+
+		w.handled[key] = true
+		switch {
+		case decision.Action == approvals.Ask:
+			logApproval(a, decision, "left for the app")
+		case w.dryRun:
+			logApproval(a, decision, "dry run")
+		default:
+			w.answer(ctx, a, decision)
+		}
+	}
+
+	// Forget answered approvals
+	for key := range w.handled {
+		if !current[key] {
+			delete(w.handled, key)
+		}
+	}
+	return nil
+}
+
+// ask shows a desktop dialog for an approval.
+func (w *approvalWatcher) ask(ctx context.Context, a *api.PendingApproval) {
+	promptCtx, cancel := context.WithTimeout(ctx, w.promptTimeout)
+	defer cancel()
+
+	title := "nappctl: " + a.ToolName
+	message := fmt.Sprintf("%s wants to use %s:\n\n%s", chatLabel(a), a.ToolName, describeToolCall(a))
+	allowed, err := approvals.Prompt(promptCtx, title, message)
+
+	select {
+	case w.answers <- promptAnswer{approval: a, allowed: allowed, err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// finishPrompt answers an approval the user decided in a dialog.
+func (w *approvalWatcher) finishPrompt(ctx context.Context, answer promptAnswer) {
+	a := answer.approval
+	key := a.ConversationID + "\x00" + a.ToolUseID
+	delete(w.prompting, key)
+	w.handled[key] = true
+
+	asked := approvals.Decision{Action: approvals.Ask, Rule: "prompt"}
+	switch {
+	case errors.Is(answer.err, approvals.ErrNoPrompt):
+		w.prompt = false
+		logApproval(a, asked, "no desktop dialog, left for the app")
+	case errors.Is(answer.err, context.DeadlineExceeded):
+		logApproval(a, asked, "no answer, left for the app")
+	case answer.err != nil:
+		logApproval(a, asked, fmt.Sprintf("dialog failed (%v), left for the app", answer.err))
+	case answer.allowed:
+		w.answer(ctx, a, approvals.Decision{Action: approvals.Allow, Rule: "prompt"})
+	default:
+		w.answer(ctx, a, approvals.Decision{Action: approvals.Deny, Rule: "prompt"})
+	}
+}
+
+// answer sends an allow or deny decision and logs it.
+func (w *approvalWatcher) answer(ctx context.Context, a *api.PendingApproval, decision approvals.Decision) {
+	err := w.client.AnswerApproval(ctx, a.ConversationID, a.ToolUseID, decision.Action == approvals.Allow)
+	switch {
+	case err == nil:
+		logApproval(a, decision, "")
+	case api.IsNotFound(err):
+		logApproval(a, decision, "already answered")
+	default:
+		logApproval(a, decision, fmt.Sprintf("failed: %v", err))
+	}
+}
+
+// logApproval logs a decision about a tool call.
+func logApproval(a *api.PendingApproval, decision approvals.Decision, note string) {
+	action := fmt.Sprintf("%-5s", decision.Action)
+	switch decision.Action {
+	case approvals.Allow:
+		action = color.GreenString(action)
+	case approvals.Deny:
+		action = color.RedString(action)
+	default:
+		action = color.YellowString(action)
+	}
+
+	line := fmt.Sprintf("%s %s conversation=%s tool=%s rule=%q %q",
+		time.Now().Format("2006-01-02 15:04:05"), action, a.ConversationID, a.ToolName, decision.Rule, describeToolCall(a))
+	if note != "" {
+		line += color.HiBlackString(" (%s)", note)
+	}
+	fmt.Println(line)
+}
+
+func logApprovalError(err error) {
+	fmt.Printf("%s %s %v\n", time.Now().Format("2006-01-02 15:04:05"), color.RedString("error"), err)
+}
+
+// describeToolCall summarizes a tool call's input.
+func describeToolCall(a *api.PendingApproval) string {
+	if command, ok := a.Input["command"].(string); ok && command != "" {
+		return truncateLine(command, 80)
+	}
+	if file := approvals.InputPath(a.Input); file != "" {
+		return file
+	}
+	data, _ := json.Marshal(a.Input)
+	return toolSummary(string(data))
+}
+
+// chatLabel names an approval's chat for dialogs.
+func chatLabel(a *api.PendingApproval) string {
+	label := "Chat " + a.ConversationID
+	if a.Topic != "" {
+		label = fmt.Sprintf("%q", a.Topic)
+	}
+	if a.Tool != "" {
+		label += " (" + a.Tool + ")"
+	}
+	return label
+}
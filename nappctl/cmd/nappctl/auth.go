@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/auth"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/server"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/service"
 	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var authCmd = &cobra.Command{
@@ -45,22 +56,7 @@ var authShowCmd = &cobra.Command{
 		fmt.Println("Token:", token)
 
 		if qr || tailscale {
-			// Load config to get server URL
-			cfg, err := config.Load()
-			if err != nil {
-				color.Red("Error loading config: %v", err)
-				os.Exit(1)
-			}
-
-			if qr {
-				url := fmt.Sprintf("%s?token=%s", cfg.GetServerURL(), token)
-				fmt.Println("\nQR Code:")
-				auth.PrintQRCode(url)
-			}
-
-			if tailscale {
-				printTailscaleQR(cfg.Port, token)
-			}
+			showQR(cmd, token)
 		}
 	},
 }
@@ -87,7 +83,7 @@ var authGenerateCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		token, err := auth.CreateAndSaveToken(authPath)
+		token, err := auth.CreateAndSaveToken(authPath, configuredSecretStore())
 		if err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
@@ -95,25 +91,10 @@ var authGenerateCmd = &cobra.Command{
 
 		color.Green("✓ Token generated successfully")
 		fmt.Println("Token:", token)
-		fmt.Printf("Saved to: %s\n", authPath)
+		fmt.Printf("Saved to: %s\n", tokenLocation(authPath))
 
 		if qr || tailscale {
-			// Load config to get server URL
-			cfg, err := config.Load()
-			if err != nil {
-				color.Red("Error loading config: %v", err)
-				os.Exit(1)
-			}
-
-			if qr {
-				url := fmt.Sprintf("%s?token=%s", cfg.GetServerURL(), token)
-				fmt.Println("\nQR Code:")
-				auth.PrintQRCode(url)
-			}
-
-			if tailscale {
-				printTailscaleQR(cfg.Port, token)
-			}
+			showQR(cmd, token)
 		}
 	},
 }
@@ -121,10 +102,19 @@ var authGenerateCmd = &cobra.Command{
 var authRotateCmd = &cobra.Command{
 	Use:   "rotate",
 	Short: "Rotate token",
-	Long:  "Generate a new token and invalidate the old one.",
+	Long: `Generate a new token and invalidate the old one.
+
+If the server is running, it is told to reload auth.json so the new token
+takes effect immediately; the switch is then verified by checking that
+the new token is accepted and the old one rejected. A server that cannot
+be reloaded is restarted instead. Paired sessions are kept.
+
+The new token is saved in the configured secret store (see
+"nappctl auth store"), moving it there if the old one was kept elsewhere.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		qr, _ := cmd.Flags().GetBool("qr")
 		tailscale, _ := cmd.Flags().GetBool("tailscale")
+		noReload, _ := cmd.Flags().GetBool("no-reload")
 
 		dataDir, err := config.ResolveDataDir()
 		if err != nil {
@@ -133,7 +123,13 @@ var authRotateCmd = &cobra.Command{
 		}
 		authPath := config.GetAuthPath(dataDir)
 
-		token, err := auth.RotateToken(authPath)
+		oldToken, err := auth.GetToken(authPath)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		token, err := auth.RotateToken(authPath, configuredSecretStore())
 		if err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
@@ -142,23 +138,20 @@ var authRotateCmd = &cobra.Command{
 		color.Green("✓ Token rotated successfully")
 		fmt.Println("New Token:", token)
 
-		if qr || tailscale {
-			// Load config to get server URL
-			cfg, err := config.Load()
-			if err != nil {
-				color.Red("Error loading config: %v", err)
-				os.Exit(1)
-			}
-
-			if qr {
-				url := fmt.Sprintf("%s?token=%s", cfg.GetServerURL(), token)
-				fmt.Println("\nQR Code:")
-				auth.PrintQRCode(url)
+		if !noReload {
+			reloadServerAuth(dataDir, oldToken, token)
+		} else if serverRunning(dataDir) {
+			// The server adopts auth.json when it next saves it, but cannot
+			// read a token kept in another secret store
+			if backend, _ := auth.CurrentBackend(authPath); backend == auth.BackendFile {
+				color.Yellow("The running server switches to the new token when it next saves auth.json (within 5 minutes) or restarts")
+			} else {
+				color.Yellow("The running server keeps the old token until it restarts: nappctl server restart")
 			}
+		}
 
-			if tailscale {
-				printTailscaleQR(cfg.Port, token)
-			}
+		if qr || tailscale {
+			showQR(cmd, token)
 		}
 	},
 }
@@ -166,7 +159,7 @@ var authRotateCmd = &cobra.Command{
 var authDeleteCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Delete token",
-	Long:  "Remove the authentication token file.",
+	Long:  "Remove the authentication token file, and the token from its secret store.",
 	Run: func(cmd *cobra.Command, args []string) {
 		dataDir, err := config.ResolveDataDir()
 		if err != nil {
@@ -191,76 +184,854 @@ var authDeleteCmd = &cobra.Command{
 
 var authQRCmd = &cobra.Command{
 	Use:   "qr",
-	Short: "Show token as QR code",
-	Long: `Display the authentication token as a QR code for easy scanning.
+	Short: "Show a QR code for connecting a device",
+	Long: `Display a QR code for the mobile app to scan. Like "nappctl auth pair", it
+holds a one-time pairing code and waits for the device to pair; the server
+must be running.
+
+--master-token shows a QR code holding the master token instead, for app
+versions without pairing. That token ends up in screenshots and browser
+history, so prefer pairing.
 
 Use --tailscale (-t) to also display a QR code using your Tailscale IP,
 allowing iOS devices on the same tailnet to connect.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		tailscale, _ := cmd.Flags().GetBool("tailscale")
+		masterToken, _ := cmd.Flags().GetBool("master-token")
+		if !masterToken {
+			pairDevice(tailscale)
+			return
+		}
 
 		dataDir, err := config.ResolveDataDir()
 		if err != nil {
 			color.Red("Error resolving data directory: %v", err)
 			os.Exit(1)
 		}
-		authPath := config.GetAuthPath(dataDir)
-
-		token, err := auth.GetToken(authPath)
+		token, err := auth.GetToken(config.GetAuthPath(dataDir))
 		if err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
-
 		if token == "" {
 			color.Yellow("No token found. Generate one with: nappctl auth generate")
 			os.Exit(1)
 		}
+		printMasterTokenQR(token, tailscale)
+	},
+}
+
+var authPairCmd = &cobra.Command{
+	Use:   "pair",
+	Short: "Pair a device with a one-time code",
+	Long: `Show a QR code with a one-time pairing code for a phone to scan.
+
+The code can be redeemed once, within 5 minutes, for a session of the
+device's own, so the master token never ends up in screenshots or browser
+history. The command counts down until the device pairs and then prints
+its name; list and revoke paired devices with "nappctl auth sessions".
+Interrupting it voids the code.
+
+The server must be running. Use --tailscale (-t) to also display a QR code
+using your Tailscale IP.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tailscale, _ := cmd.Flags().GetBool("tailscale")
+		pairDevice(tailscale)
+	},
+}
+
+var authTokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "Manage named, scoped tokens",
+	Long: `Manage named tokens that give a CI script or a teammate's device limited,
+individually revocable access.
+
+Scopes:
+  read-only    GET requests anywhere in the API, and file watching
+  files-write  writing, uploading, moving and deleting files, git operations
+  terminal     the terminals API and terminal sessions
+  chat         the conversations API and chat sessions
+
+Named tokens cannot manage auth themselves. A running server picks up
+changes immediately; revoking a token also closes its open connections.`,
+}
+
+var authTokensCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a named token",
+	Example: `  nappctl auth tokens create --name ci --scope read-only --ttl 24h
+  nappctl auth tokens create --name alex-phone --scope read-only,chat`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		scopes, _ := cmd.Flags().GetStringSlice("scope")
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+
+		if name == "" {
+			color.Red("Error: --name is required")
+			os.Exit(1)
+		}
+		if ttl < 0 {
+			color.Red("Error: --ttl must not be negative")
+			os.Exit(1)
+		}
 
-		// Load config to get server URL
-		cfg, err := config.Load()
+		dataDir, authPath := mustAuthPath()
+		token, named, err := auth.CreateNamedToken(authPath, name, scopes, ttl)
 		if err != nil {
-			color.Red("Error loading config: %v", err)
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Token %q created", name)
+		fmt.Println("Token:  ", token)
+		fmt.Println("Scopes: ", strings.Join(named.Scopes, ", "))
+		if named.ExpiresAt != 0 {
+			fmt.Println("Expires:", formatTime(time.UnixMilli(named.ExpiresAt)))
+		}
+		color.Yellow("This is the only time the token is shown.")
+
+		notifyServerAuthChanged(dataDir)
+	},
+}
+
+var authTokensListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List named tokens",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		_, authPath := mustAuthPath()
+
+		tokens, err := auth.ListNamedTokens(authPath)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			type tokenJSON struct {
+				Name      string     `json:"name"`
+				Scopes    []string   `json:"scopes"`
+				CreatedAt time.Time  `json:"createdAt"`
+				ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+				LastUsed  *time.Time `json:"lastUsed,omitempty"`
+				Expired   bool       `json:"expired"`
+			}
+			list := []tokenJSON{}
+			for _, t := range tokens {
+				list = append(list, tokenJSON{
+					Name:      t.Name,
+					Scopes:    t.Scopes,
+					CreatedAt: time.UnixMilli(t.CreatedAt),
+					ExpiresAt: millisPtr(t.ExpiresAt),
+					LastUsed:  millisPtr(t.LastUsed),
+					Expired:   t.Expired(time.Now()),
+				})
+			}
+			printJSON(list)
+			return
+		}
+
+		if len(tokens) == 0 {
+			color.Yellow("No named tokens. Create one with: nappctl auth tokens create --name NAME --scope SCOPE")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Name", "Scopes", "Created", "Expires", "Last Used"})
+		table.SetBorder(false)
+		table.SetColumnSeparator("")
+
+		now := time.Now()
+		for _, t := range tokens {
+			expires := "never"
+			if t.ExpiresAt != 0 {
+				expires = formatTime(time.UnixMilli(t.ExpiresAt))
+				if t.Expired(now) {
+					expires += " (expired)"
+				}
+			}
+			lastUsed := "-"
+			if t.LastUsed != 0 {
+				lastUsed = formatTime(time.UnixMilli(t.LastUsed))
+			}
+			table.Append([]string{
+				t.Name,
+				strings.Join(t.Scopes, ", "),
+				formatTime(time.UnixMilli(t.CreatedAt)),
+				expires,
+				lastUsed,
+			})
+		}
+		table.Render()
+	},
+}
+
+var authTokensRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke a named token",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dataDir, authPath := mustAuthPath()
+
+		if err := auth.RevokeNamedToken(authPath, args[0]); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Token %q revoked", args[0])
+		notifyServerAuthChanged(dataDir)
+	},
+}
+
+var authSessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List and revoke paired devices' sessions",
+	Long: `List and revoke the sessions of paired devices.
+
+When the server is running, sessions are read and revoked through it, so
+revocation takes effect immediately and last-seen times are current.
+Otherwise (or with --offline) auth.json is used directly. Session IDs may
+be abbreviated to any unique prefix.`,
+}
+
+var authSessionsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List sessions",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		offline, _ := cmd.Flags().GetBool("offline")
+		_, authPath := mustAuthPath()
+
+		var sessions []auth.Session
+		if client := sessionsClient(offline); client != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			list, err := client.ListSessions(ctx)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			for _, s := range list {
+				sessions = append(sessions, auth.Session{
+					ID:           s.ID,
+					Device:       s.Device,
+					UserAgent:    s.UserAgent,
+					IP:           s.IP,
+					CreatedAt:    int64(s.CreatedAt),
+					LastActivity: int64(s.LastActivity),
+				})
+			}
+		} else {
+			var err error
+			if sessions, err = auth.ListSessions(authPath); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+		}
+
+		if output == "json" {
+			if sessions == nil {
+				sessions = []auth.Session{}
+			}
+			printJSON(sessions)
+			return
+		}
+
+		if len(sessions) == 0 {
+			color.Yellow("No sessions")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"ID", "Device", "IP", "Created", "Last Seen"})
+		table.SetBorder(false)
+		table.SetColumnSeparator("")
+
+		for _, s := range sessions {
+			device := s.Device
+			if device == "" {
+				device = s.UserAgent
+			}
+			if device == "" {
+				device = "-"
+			}
+			ip := s.IP
+			if ip == "" {
+				ip = "-"
+			}
+			table.Append([]string{
+				s.ID,
+				truncateLine(device, 48),
+				ip,
+				formatTime(time.UnixMilli(s.CreatedAt)),
+				formatTime(time.UnixMilli(s.LastActivity)),
+			})
+		}
+		table.Render()
+	},
+}
+
+var authSessionsRevokeCmd = &cobra.Command{
+	Use:   "revoke <id|--all>",
+	Short: "Revoke a session, or all of them",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		offline, _ := cmd.Flags().GetBool("offline")
+
+		if (len(args) == 1) == all {
+			color.Red("Error: give a session ID or --all")
 			os.Exit(1)
 		}
 
-		// Use local network IP instead of localhost for mobile access
-		host := cfg.Host
-		if host == "localhost" || host == "127.0.0.1" || host == "" {
-			if localIP := auth.GetLocalIP(); localIP != "" {
-				host = localIP
-				color.Yellow("Using local IP %s instead of localhost for mobile access", localIP)
+		dataDir, authPath := mustAuthPath()
+
+		if client := sessionsClient(offline); client != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+
+			if all {
+				count, err := client.RevokeAllSessions(ctx)
+				if err != nil {
+					color.Red("Error: %v", err)
+					os.Exit(1)
+				}
+				color.Green("✓ Revoked %d session(s)", count)
+				return
+			}
+
+			list, err := client.ListSessions(ctx)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			sessions := make([]auth.Session, len(list))
+			for i, s := range list {
+				sessions[i] = auth.Session{ID: s.ID, Device: s.Device}
+			}
+			session, err := auth.FindSession(sessions, args[0])
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			if err := client.RevokeSession(ctx, session.ID); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			color.Green("✓ Session %s revoked", session.ID)
+			return
+		}
+
+		if all {
+			count, err := auth.RevokeAllSessions(authPath)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
 			}
+			color.Green("✓ Revoked %d session(s)", count)
+		} else {
+			session, err := auth.RevokeSession(authPath, args[0])
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			color.Green("✓ Session %s revoked", session.ID)
+		}
+		if offline {
+			notifyServerAuthChanged(dataDir)
 		}
+	},
+}
+
+var authStoreCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Show where the master token is kept",
+	Long: `Show which secret store keeps the master token.
+
+Backends:
+  file       in auth.json, protected only by its permissions (default)
+  encrypted  in auth-token.age, encrypted with a passphrase (age, scrypt).
+             The passphrase is read from NAPPTRAPP_AUTH_PASSPHRASE, or
+             asked for on the terminal.
+  keyring    in the OS keyring (the Secret Service over D-Bus on Linux)
+
+Generated and rotated tokens are saved in the backend selected with
+"nappctl config set secret-store"; "nappctl auth store migrate" moves the
+current token. The server cannot read the encrypted or keyring backends:
+nappctl passes it the token when it starts it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, authPath := mustAuthPath()
+		configured := configuredSecretStore()
+
+		current, err := auth.CurrentBackend(authPath)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Secret store: %s\n", current)
+		fmt.Printf("Location:     %s\n", tokenLocation(authPath))
+		if configured != current {
+			color.Yellow("The config selects %s; move the token with: nappctl auth store migrate %s", configured, configured)
+		}
+	},
+}
+
+var authStoreMigrateCmd = &cobra.Command{
+	Use:   "migrate <file|encrypted|keyring>",
+	Short: "Move the master token to another secret store",
+	Long: `Move the master token to another secret store, keeping the token, and
+select that store in the config for future tokens. A running server is
+told to reload auth.json.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		backend := args[0]
+		if err := auth.ValidateBackend(backend); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		dataDir, authPath := mustAuthPath()
 
-		url := fmt.Sprintf("http://%s:%d?token=%s", host, cfg.Port, token)
-		fmt.Println("\nServer URL with token:")
-		fmt.Println(url)
-		fmt.Println("\nQR Code (Local Network):")
-		auth.PrintQRCode(url)
+		from, err := auth.MigrateSecretStore(authPath, backend)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if from == backend {
+			color.Yellow("The master token is already kept in %s", backend)
+		} else {
+			color.Green("✓ Master token moved from %s to %s", from, backend)
+			fmt.Printf("Location: %s\n", tokenLocation(authPath))
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			color.Red("Error loading config: %v", err)
+			os.Exit(1)
+		}
+		if cfg.SecretStore != backend {
+			cfg.SecretStore = backend
+			if err := config.Save(cfg); err != nil {
+				color.Red("Error saving config: %v", err)
+				os.Exit(1)
+			}
+		}
 
-		// Show Tailscale QR code if requested
-		if tailscale {
-			printTailscaleQR(cfg.Port, token)
+		if from != backend {
+			notifyServerAuthChanged(dataDir)
 		}
 	},
 }
 
 func init() {
-	authShowCmd.Flags().BoolP("qr", "q", false, "Show QR code")
-	authShowCmd.Flags().BoolP("tailscale", "t", false, "Also show Tailscale QR code")
 	authGenerateCmd.Flags().BoolP("force", "f", false, "Overwrite existing token")
-	authGenerateCmd.Flags().BoolP("qr", "q", false, "Show QR code")
-	authGenerateCmd.Flags().BoolP("tailscale", "t", false, "Also show Tailscale QR code")
-	authRotateCmd.Flags().BoolP("qr", "q", false, "Show QR code")
-	authRotateCmd.Flags().BoolP("tailscale", "t", false, "Also show Tailscale QR code")
+	authRotateCmd.Flags().Bool("no-reload", false, "Do not tell a running server to load the new token")
+	for _, cmd := range []*cobra.Command{authShowCmd, authGenerateCmd, authRotateCmd} {
+		cmd.Flags().BoolP("qr", "q", false, "Show a QR code with a one-time pairing code")
+		cmd.Flags().BoolP("tailscale", "t", false, "Also show Tailscale QR code")
+		cmd.Flags().Bool("master-token", false, "Put the master token in the QR code instead of a pairing code")
+	}
 	authQRCmd.Flags().BoolP("tailscale", "t", false, "Also show Tailscale QR code")
+	authQRCmd.Flags().Bool("master-token", false, "Put the master token in the QR code instead of a pairing code")
+	authPairCmd.Flags().BoolP("tailscale", "t", false, "Also show Tailscale QR code")
 
 	authCmd.AddCommand(authShowCmd)
 	authCmd.AddCommand(authGenerateCmd)
 	authCmd.AddCommand(authRotateCmd)
 	authCmd.AddCommand(authDeleteCmd)
 	authCmd.AddCommand(authQRCmd)
+	authCmd.AddCommand(authPairCmd)
+
+	authTokensCreateCmd.Flags().String("name", "", "Token name, e.g. ci or alex-phone")
+	authTokensCreateCmd.Flags().StringSlice("scope", nil, "Scopes: read-only, files-write, terminal, chat (repeat or comma-separate)")
+	authTokensCreateCmd.Flags().Duration("ttl", 0, "Time until the token expires, e.g. 24h (default: never)")
+	addOutputFlag(authTokensListCmd)
+
+	authTokensCmd.AddCommand(authTokensCreateCmd)
+	authTokensCmd.AddCommand(authTokensListCmd)
+	authTokensCmd.AddCommand(authTokensRevokeCmd)
+	authCmd.AddCommand(authTokensCmd)
+
+	authSessionsCmd.PersistentFlags().Bool("offline", false, "Use auth.json even if the server is running")
+	addOutputFlag(authSessionsListCmd)
+	authSessionsRevokeCmd.Flags().Bool("all", false, "Revoke every session")
+
+	authSessionsCmd.AddCommand(authSessionsListCmd)
+	authSessionsCmd.AddCommand(authSessionsRevokeCmd)
+	authCmd.AddCommand(authSessionsCmd)
+
+	authStoreCmd.AddCommand(authStoreMigrateCmd)
+	authCmd.AddCommand(authStoreCmd)
+}
+
+// reloadServerAuth tells a running server to adopt a rotated token and
+// reports whether the old token is now rejected. Failures are reported
+// but not fatal: the token has already been rotated on disk.
+func reloadServerAuth(dataDir, oldToken, newToken string) {
+	sup, err := server.NewSupervisor(server.Options{DataDir: dataDir})
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	reload, err := sup.ReloadAuth(ctx, oldToken, newToken)
+	switch {
+	case errors.Is(err, server.ErrNotRunning):
+		color.Yellow("Server is not running; the new token takes effect when it starts")
+	case err != nil:
+		color.Yellow("⚠ Could not reload the running server: %v", err)
+		fmt.Println("Restarting it to load the new token...")
+		if err := restartServerKeepingAuth(dataDir); err != nil {
+			color.Red("Error: failed to restart the server: %v", err)
+			fmt.Println("Stop it, then rotate the token again: nappctl server stop && nappctl auth rotate")
+			os.Exit(1)
+		}
+		color.Green("✓ Server restarted with the new token")
+	default:
+		color.Green("✓ Server (PID %d) reloaded: new token accepted, old token rejected", reload.PID)
+		if reload.SessionCount > 0 {
+			fmt.Printf("%d paired session(s) kept\n", reload.SessionCount)
+		}
+	}
+}
+
+// serverRunning reports whether a server is running on the data directory.
+func serverRunning(dataDir string) bool {
+	sup, err := server.NewSupervisor(server.Options{DataDir: dataDir})
+	if err != nil {
+		return false
+	}
+	_, running, err := sup.Status()
+	return err == nil && running
+}
+
+// restartServerKeepingAuth restarts a running server that could not be
+// made to reload auth.json. A server writes its in-memory copy of the file
+// when it stops (older ones without checking for outside changes), so the
+// file as nappctl left it is put back before the server starts again.
+func restartServerKeepingAuth(dataDir string) error {
+	authPath := config.GetAuthPath(dataDir)
+	authData, err := auth.ReadAuthFile(authPath)
+	if err != nil {
+		return err
+	}
+	if authData == nil {
+		return errors.New("auth.json holds no master token")
+	}
+
+	if serviceManaged() {
+		if err := service.Stop(); err != nil {
+			return err
+		}
+		if err := auth.WriteAuthFile(authPath, authData); err != nil {
+			return err
+		}
+		return service.Start()
+	}
+
+	sup, err := server.NewSupervisor(server.Options{DataDir: dataDir})
+	if err != nil {
+		return err
+	}
+	// Start it again on the port it was running on
+	info, _, err := sup.Status()
+	if err != nil {
+		return err
+	}
+	port := server.DefaultPort
+	if info != nil {
+		port = info.Port
+	}
+	sup, err = server.NewSupervisor(server.Options{DataDir: dataDir, Port: port, Escalate: true})
+	if err != nil {
+		return err
+	}
+
+	if _, err := sup.Stop(); err != nil && !errors.Is(err, server.ErrNotRunning) {
+		return fmt.Errorf("failed to stop server: %w", err)
+	}
+	if err := auth.WriteAuthFile(authPath, authData); err != nil {
+		return err
+	}
+	_, err = sup.Start()
+	return err
+}
+
+// mustAuthPath resolves the data directory and its auth.json, exiting on
+// errors.
+func mustAuthPath() (dataDir, authPath string) {
+	dataDir, err := config.ResolveDataDir()
+	if err != nil {
+		color.Red("Error resolving data directory: %v", err)
+		os.Exit(1)
+	}
+	return dataDir, config.GetAuthPath(dataDir)
+}
+
+// configuredSecretStore returns the secret store backend selected in the
+// config, exiting on errors.
+func configuredSecretStore() string {
+	cfg, err := config.Load()
+	if err != nil {
+		color.Red("Error loading config: %v", err)
+		os.Exit(1)
+	}
+	return cfg.SecretStore
+}
+
+// tokenLocation describes where the master token of the auth file is kept.
+func tokenLocation(authPath string) string {
+	backend, err := auth.CurrentBackend(authPath)
+	if err != nil {
+		return authPath
+	}
+	store, err := auth.OpenSecretStore(backend, authPath)
+	if err != nil {
+		return authPath
+	}
+	return store.Location()
+}
+
+// notifyServerAuthChanged tells a running server to reload auth.json after
+// nappctl changed it, reporting the outcome.
+func notifyServerAuthChanged(dataDir string) {
+	token, err := auth.GetToken(config.GetAuthPath(dataDir))
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	sup, err := server.NewSupervisor(server.Options{DataDir: dataDir})
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	reload, err := sup.NotifyAuthChanged(ctx, token)
+	switch {
+	case errors.Is(err, server.ErrNotRunning):
+		// Picked up when the server starts
+	case err != nil:
+		color.Yellow("⚠ Could not reload the running server: %v", err)
+		fmt.Println("Restarting it to apply the change...")
+		if err := restartServerKeepingAuth(dataDir); err != nil {
+			color.Red("Error: failed to restart the server: %v", err)
+			fmt.Println("Stop it, then make the change again: nappctl server stop")
+			os.Exit(1)
+		}
+		color.Green("✓ Server restarted")
+	default:
+		color.Green("✓ Server (PID %d) reloaded", reload.PID)
+	}
+}
+
+// sessionsClient returns an API client for the running local server,
+// authenticated with the master token, or nil to work on auth.json
+// directly: with --offline, or when the server is not running.
+func sessionsClient(offline bool) *api.Client {
+	if offline {
+		return nil
+	}
+	dataDir, authPath := mustAuthPath()
+	sup, err := server.NewSupervisor(server.Options{DataDir: dataDir})
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	url, _, err := sup.RunningURL()
+	if errors.Is(err, server.ErrNotRunning) {
+		return nil
+	} else if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+
+	token, err := auth.GetToken(authPath)
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	return api.NewClient(url, token)
+}
+
+// waitForPairing polls the pairing until it is redeemed or expires,
+// showing a countdown on a terminal, and returns its final state.
+func waitForPairing(ctx context.Context, client *api.Client, pairing *api.Pairing) (*api.PairingStatus, error) {
+	countdown := term.IsTerminal(int(os.Stdout.Fd()))
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer func() {
+		if countdown {
+			fmt.Printf("\r%-40s\r", "")
+		}
+	}()
+
+	for {
+		if countdown {
+			left := time.Until(pairing.ExpiresAt.Time()).Round(time.Second)
+			if left < 0 {
+				left = 0
+			}
+			fmt.Printf("\rWaiting for the device... expires in %d:%02d ", int(left.Minutes()), int(left.Seconds())%60)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, err := client.GetPairing(ctx, pairing.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if status.Status != api.PairingPending {
+			return status, nil
+		}
+	}
+}
+
+// mobileHost returns the configured host, or the local network IP instead
+// of localhost so that a phone can reach the server.
+func mobileHost(cfg *config.Config) string {
+	host := cfg.Host
+	if host == "localhost" || host == "127.0.0.1" || host == "" {
+		if localIP := auth.GetLocalIP(); localIP != "" {
+			host = localIP
+			color.Yellow("Using local IP %s instead of localhost for mobile access", localIP)
+		}
+	}
+	return host
+}
+
+// millisPtr converts a Unix millisecond timestamp to a time, or nil when
+// unset.
+func millisPtr(ms int64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms)
+	return &t
+}
+
+// showQR implements --qr and --tailscale: it pairs a device, or with
+// --master-token shows token itself.
+func showQR(cmd *cobra.Command, token string) {
+	tailscale, _ := cmd.Flags().GetBool("tailscale")
+	if masterToken, _ := cmd.Flags().GetBool("master-token"); masterToken {
+		printMasterTokenQR(token, tailscale)
+		return
+	}
+	pairDevice(tailscale)
+}
+
+// printMasterTokenQR shows a QR code whose URL holds the master token.
+func printMasterTokenQR(token string, tailscale bool) {
+	cfg, err := config.Load()
+	if err != nil {
+		color.Red("Error loading config: %v", err)
+		os.Exit(1)
+	}
+
+	url := fmt.Sprintf("http://%s:%d?token=%s", mobileHost(cfg), cfg.Port, token)
+	fmt.Println("\nServer URL with token:")
+	fmt.Println(url)
+	fmt.Println("\nQR Code (Local Network):")
+	auth.PrintQRCode(url)
+
+	if tailscale {
+		printTailscaleQR(cfg.Port, token)
+	}
+
+	color.Yellow("\nThis QR code contains the master token. To pair a device without it: nappctl auth pair")
+}
+
+// pairDevice mints a pairing code, shows it as a QR code and waits for a
+// device to redeem it, exiting unless one does.
+func pairDevice(tailscale bool) {
+	client := sessionsClient(false)
+	if client == nil {
+		color.Red("Error: server is not running; pairing codes are issued by the server")
+		fmt.Println("Start it with: nappctl server start")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		color.Red("Error loading config: %v", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pairing, err := client.CreatePairing(ctx)
+	if err != nil {
+		switch {
+		case api.IsUnauthorized(err) || api.IsForbidden(err):
+			color.Red("Error: the server rejected the master token")
+		case api.IsNotFound(err):
+			color.Red("Error: the server does not support pairing codes; update and restart it")
+		default:
+			color.Red("Error: %v", err)
+		}
+		os.Exit(1)
+	}
+
+	url := fmt.Sprintf("http://%s:%d/?pair=%s", mobileHost(cfg), cfg.Port, pairing.Code)
+	fmt.Println("\nPairing URL:")
+	fmt.Println(url)
+	fmt.Println("\nQR Code (Local Network):")
+	auth.PrintQRCode(url)
+
+	if tailscale {
+		if tsIP := auth.GetTailscaleIP(); tsIP != "" {
+			tsURL := fmt.Sprintf("http://%s:%d/?pair=%s", tsIP, cfg.Port, pairing.Code)
+			color.Cyan("\nTailscale IP: %s", tsIP)
+			fmt.Println(tsURL)
+			fmt.Println("\nQR Code (Tailscale):")
+			auth.PrintQRCode(tsURL)
+		} else {
+			color.Yellow("\nTailscale: No Tailscale interface detected.")
+		}
+	}
+
+	fmt.Printf("\nPairing code: %s\n", pairing.Code)
+	status, err := waitForPairing(ctx, client, pairing)
+	switch {
+	case ctx.Err() != nil:
+		cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		client.CancelPairing(cancelCtx, pairing.ID)
+		color.Yellow("Pairing cancelled; the code no longer works")
+		os.Exit(1)
+	case err != nil:
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	case status.Status == api.PairingExpired:
+		color.Yellow("Pairing code expired; run nappctl auth pair again")
+		os.Exit(1)
+	}
+
+	device := status.Device
+	if device == "" {
+		device = "unnamed device"
+	}
+	color.Green("✓ Device paired: %s", device)
+	fmt.Printf("Session: %s (revoke with: nappctl auth sessions revoke %s)\n", pairing.ID, pairing.ID)
 }
 
 // printTailscaleQR detects the Tailscale IP and prints a QR code for it.
@@ -0,0 +1,1019 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/approvals"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// toolResultLines is how many lines of a tool result the REPL shows.
+const toolResultLines = 4
+
+// approvalBatchDelay is how long chat run collects approval requests; the
+// server sends those of a turn together and ends the turn once all are
+// answered.
+const approvalBatchDelay = 200 * time.Millisecond
+
+var errRunTimeout = errors.New("timed out")
+
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Chat with AI agents on the server",
+	Long: `Talk to the server's AI chat sessions (Claude, Cursor Agent, Gemini) from
+the terminal, including the ones started from the mobile app.`,
+}
+
+var chatAttachCmd = &cobra.Command{
+	Use:   "attach <conversation-id>",
+	Short: "Attach to a chat",
+	Long: `Attach to a chat over the WebSocket API and open a REPL: type a message and
+press Enter to send it. Assistant text, tool calls and tool results are
+streamed as they arrive, and approvals and questions are asked inline.
+
+Ctrl-C interrupts the agent's turn; when it is idle, Ctrl-C twice or Ctrl-D
+detaches. The chat keeps running on the server.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		history := mustHistoryFlag(cmd)
+		client := mustAPIClient(cmd)
+
+		chat, err := client.GetChat(context.Background(), args[0])
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		runChat(cmd, client, history, chat.ID, chat.ProjectPath, chat.Topic)
+	},
+}
+
+var chatNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Start a chat and attach to it",
+	Long: `Start a chat in a project and attach to it like chat attach. The tool and
+its model default to the server's (claude and the CLI's default model).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		history := mustHistoryFlag(cmd)
+		client := mustAPIClient(cmd)
+		chat := mustCreateChat(cmd, client)
+		color.Green("✓ Chat created: %s (%s)", chat.ConversationID, chat.Tool)
+
+		runChat(cmd, client, history, chat.ConversationID, chat.ProjectPath, chat.Topic)
+	},
+}
+
+var chatRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Send a prompt to a new chat without a REPL",
+	Long: `Start a chat in a project and send it a prompt, for scripts and CI.
+
+Without --wait the conversation ID is printed once the prompt is delivered
+and the agent keeps working on the server. With --wait nappctl waits until
+the agent has finished and prints its final message, or with --transcript
+the whole conversation as JSON lines; tool calls are logged to stderr.
+
+Tool calls that need approval are denied by default. --approve all allows
+them and --approve policy decides them with the approvals watch policy;
+calls the policy would ask about are denied.
+
+With --wait the exit status is 0 when the agent finished, 1 on errors
+(including error output from the agent) and 2 when --timeout expired and
+the agent was interrupted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		prompt, _ := cmd.Flags().GetString("prompt")
+		wait, _ := cmd.Flags().GetBool("wait")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		approve, _ := cmd.Flags().GetString("approve")
+		policyFile, _ := cmd.Flags().GetString("policy")
+		transcript, _ := cmd.Flags().GetBool("transcript")
+		remove, _ := cmd.Flags().GetBool("rm")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		if (transcript || remove) && !wait {
+			color.Red("Error: --transcript and --rm need --wait")
+			os.Exit(1)
+		}
+		if prompt == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			prompt = string(data)
+		}
+		if strings.TrimSpace(prompt) == "" {
+			color.Red("Error: the prompt is empty")
+			os.Exit(1)
+		}
+
+		r := &chatRun{approve: approve, quiet: quiet, tools: map[string]*chatRunTool{}}
+		switch approve {
+		case "deny", "all":
+		case "policy":
+			r.policy, _ = mustLoadPolicy(policyFile)
+		default:
+			color.Red("Error: unknown --approve %q (use deny, all or policy)", approve)
+			os.Exit(1)
+		}
+
+		client := mustAPIClient(cmd)
+		chat := mustCreateChat(cmd, client)
+		r.client, r.id, r.projectPath = client, chat.ConversationID, chat.ProjectPath
+
+		code := r.run(prompt, wait, timeout, transcript)
+		if remove {
+			if err := client.DeleteChat(context.Background(), r.id); err != nil {
+				color.Red("Error: failed to delete chat %s: %v", r.id, err)
+				code = 1
+			}
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	addRemoteFlags(chatCmd)
+
+	addREPLFlags(chatAttachCmd)
+
+	addREPLFlags(chatNewCmd)
+	addNewChatFlags(chatNewCmd)
+
+	addNewChatFlags(chatRunCmd)
+	chatRunCmd.Flags().String("prompt", "", "Prompt to send, or - to read it from stdin")
+	chatRunCmd.Flags().Bool("wait", false, "Wait for the agent to finish and print its answer")
+	chatRunCmd.Flags().Duration("timeout", 30*time.Minute, "With --wait, how long to wait before interrupting the agent")
+	chatRunCmd.Flags().String("approve", "deny", "Answer tool approvals: deny, all or policy")
+	chatRunCmd.Flags().String("policy", "", "Policy file for --approve policy (default: approvals.yaml in the data directory)")
+	chatRunCmd.Flags().Bool("transcript", false, "Print the whole conversation as JSON lines instead of the answer")
+	chatRunCmd.Flags().Bool("rm", false, "Delete the chat when done")
+	chatRunCmd.Flags().BoolP("quiet", "q", false, "Don't log tool calls to stderr")
+	chatRunCmd.MarkFlagRequired("prompt")
+
+	chatCmd.AddCommand(chatAttachCmd)
+	chatCmd.AddCommand(chatNewCmd)
+	chatCmd.AddCommand(chatRunCmd)
+}
+
+// addREPLFlags registers the flags of the commands that open a REPL.
+func addREPLFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("thinking", false, "Show the agent's thinking")
+	cmd.Flags().IntP("history", "n", 20, "Earlier messages to show when attaching")
+}
+
+// mustHistoryFlag returns --history, exiting if it is negative.
+func mustHistoryFlag(cmd *cobra.Command) int {
+	history, _ := cmd.Flags().GetInt("history")
+	if history < 0 {
+		color.Red("Error: --history must not be negative")
+		os.Exit(1)
+	}
+	return history
+}
+
+// addNewChatFlags registers the flags of the commands that start a chat.
+func addNewChatFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("project", "p", "", "Project to chat in (ID, path or name)")
+	cmd.Flags().String("tool", "", "AI CLI: claude, cursor-agent or gemini")
+	cmd.Flags().String("model", "", "Model for the tool")
+	cmd.Flags().String("mode", "", "Mode: agent, plan or ask")
+	cmd.Flags().String("topic", "", "Chat topic (default: generated from the first message)")
+	cmd.MarkFlagRequired("project")
+}
+
+// mustCreateChat starts a chat with the addNewChatFlags settings.
+func mustCreateChat(cmd *cobra.Command, client *api.Client) *api.CreatedChat {
+	ref, _ := cmd.Flags().GetString("project")
+	tool, _ := cmd.Flags().GetString("tool")
+	model, _ := cmd.Flags().GetString("model")
+	mode, _ := cmd.Flags().GetString("mode")
+	topic, _ := cmd.Flags().GetString("topic")
+	ctx := context.Background()
+
+	project := mustResolveProject(ctx, client, ref)
+	chat, err := client.CreateChat(ctx, api.CreateChatRequest{
+		ProjectPath: project.Path,
+		Tool:        tool,
+		Model:       model,
+		Mode:        mode,
+		Topic:       topic,
+	})
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	return chat
+}
+
+// runChat attaches to a chat and runs the REPL until the user detaches or
+// the connection fails.
+func runChat(cmd *cobra.Command, client *api.Client, history int, id, projectPath, topic string) {
+	thinking, _ := cmd.Flags().GetBool("thinking")
+	socket, err := client.Connect(context.Background())
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	defer socket.Close()
+
+	attached, err := waitChatAttached(socket, id, projectPath)
+	if err != nil {
+		color.Red("Error: %v", err)
+		socket.Close()
+		os.Exit(1)
+	}
+
+	if topic == "" {
+		topic = id
+	}
+	color.Green("✓ Attached to %s (%s, %s)", topic, attached.Tool, attached.Status)
+	color.HiBlack("Ctrl-C interrupts the agent, Ctrl-D detaches")
+	fmt.Println()
+
+	session := &chatSession{socket: socket, id: id, thinking: thinking, history: history, atLineStart: true}
+	if err := session.run(); err != nil {
+		session.endLine()
+		color.Red("Error: %v", err)
+		socket.Close()
+		os.Exit(1)
+	}
+	session.endLine()
+	color.Yellow("[detached from %s]", id)
+}
+
+// waitChatAttached attaches the socket to a chat and waits for the server
+// to confirm.
+func waitChatAttached(socket *api.Socket, id, projectPath string) (*api.ChatSocketMessage, error) {
+	if err := socket.ChatAttach(id, projectPath); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := socket.Receive()
+		if err != nil {
+			return nil, err
+		}
+		var body api.ChatSocketMessage
+		switch msg.Type {
+		case api.MsgChatAttached:
+			if err := msg.Decode(&body); err != nil {
+				return nil, err
+			}
+			if body.ConversationID == id {
+				return &body, nil
+			}
+		case api.MsgChatError, api.MsgError:
+			msg.Decode(&body)
+			return nil, fmt.Errorf("failed to attach to chat %s: %s", id, body.Message)
+		}
+	}
+}
+
+// chatPrompt is an approval request or question awaiting the user's
+// answer.
+type chatPrompt struct {
+	event *api.ChatEvent
+	// question is the index of the question being asked.
+	question int
+	answers  map[string][]string
+}
+
+// chatSession renders a chat's events and turns input lines into messages
+// or answers to the pending prompts.
+type chatSession struct {
+	socket   *api.Socket
+	id       string
+	thinking bool
+	history  int
+
+	// busy is set while the agent works on a turn.
+	busy bool
+	// interrupted is set by a Ctrl-C while idle; a second one detaches.
+	interrupted bool
+	prompts     []*chatPrompt
+
+	// block is the streamed block output is being appended to.
+	block       string
+	atLineStart bool
+	// tool is the tool call whose input is streaming.
+	tool      *api.ChatEvent
+	toolInput strings.Builder
+}
+
+func (s *chatSession) run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	messages := make(chan *api.Message)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := s.socket.Receive()
+			if err != nil {
+				failed <- err
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	// The history, if any, follows chatAttached; prompt once it is shown.
+	historyWait := time.After(500 * time.Millisecond)
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig != os.Interrupt {
+				s.socket.ChatDetach(s.id)
+				return nil
+			}
+			if s.busy {
+				s.socket.ChatCancel(s.id)
+				continue
+			}
+			if s.interrupted {
+				s.socket.ChatDetach(s.id)
+				return nil
+			}
+			s.interrupted = true
+			s.endLine()
+			color.HiBlack("(press Ctrl-C again or Ctrl-D to detach)")
+			s.showPrompt()
+
+		case line, ok := <-lines:
+			if !ok {
+				s.socket.ChatDetach(s.id)
+				return nil
+			}
+			s.interrupted = false
+			if err := s.input(line); err != nil {
+				return err
+			}
+
+		case msg := <-messages:
+			s.handle(msg)
+			if msg.Type == api.MsgChatHistory && historyWait != nil {
+				historyWait = nil
+				s.showPrompt()
+			}
+
+		case <-historyWait:
+			historyWait = nil
+			s.showPrompt()
+
+		case err := <-failed:
+			return err
+		}
+	}
+}
+
+// input handles a line typed by the user.
+func (s *chatSession) input(line string) error {
+	s.atLineStart = true
+
+	if len(s.prompts) > 0 {
+		if err := s.answer(s.prompts[0], line); err != nil {
+			return err
+		}
+		s.showPrompt()
+		return nil
+	}
+
+	if strings.TrimSpace(line) == "" {
+		s.showPrompt()
+		return nil
+	}
+	s.busy = true
+	return s.socket.ChatSend(s.id, line, "")
+}
+
+// answer applies a line to a prompt, sending the answer once it is
+// complete.
+func (s *chatSession) answer(p *chatPrompt, line string) error {
+	line = strings.TrimSpace(line)
+
+	if p.event.Type == api.ChatEventApprovalRequest {
+		s.prompts = s.prompts[1:]
+		approved := strings.EqualFold(line, "y") || strings.EqualFold(line, "yes")
+		if approved {
+			s.busy = true
+		}
+		return s.socket.ChatApproval(s.id, p.event.ToolID, approved)
+	}
+
+	q := p.event.Questions[p.question]
+	p.answers[q.Question] = parseQuestionAnswer(q, line)
+	p.question++
+	if p.question < len(p.event.Questions) {
+		return nil
+	}
+	s.prompts = s.prompts[1:]
+	s.busy = true
+	return s.socket.ChatQuestionAnswer(s.id, p.event.ToolID, p.answers)
+}
+
+// parseQuestionAnswer maps option numbers ("2" or "1,3") to their labels;
+// anything else is a free-form answer.
+func parseQuestionAnswer(q api.ChatQuestion, line string) []string {
+	var labels []string
+	for _, field := range strings.Split(line, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 || n > len(q.Options) {
+			return []string{line}
+		}
+		labels = append(labels, q.Options[n-1].Label)
+	}
+	if len(labels) > 1 && !q.MultiSelect {
+		return []string{line}
+	}
+	return labels
+}
+
+// showPrompt asks the pending prompt, if any, or shows the input prompt
+// when the agent is idle.
+func (s *chatSession) showPrompt() {
+	s.endLine()
+	if len(s.prompts) == 0 {
+		if !s.busy {
+			fmt.Print(color.New(color.Bold).Sprint("> "))
+			s.atLineStart = false
+		}
+		return
+	}
+
+	p := s.prompts[0]
+	if p.event.Type == api.ChatEventApprovalRequest {
+		fmt.Print(color.YellowString("? %s - allow? [y/N] ", p.event.Prompt))
+		s.atLineStart = false
+		return
+	}
+
+	q := p.event.Questions[p.question]
+	if q.Header != "" {
+		color.New(color.Bold).Printf("? %s: ", q.Header)
+	} else {
+		color.New(color.Bold).Print("? ")
+	}
+	fmt.Println(q.Question)
+	for i, option := range q.Options {
+		if option.Description != "" {
+			fmt.Printf("  %d. %s %s\n", i+1, option.Label, color.HiBlackString("- %s", option.Description))
+		} else {
+			fmt.Printf("  %d. %s\n", i+1, option.Label)
+		}
+	}
+	hint := "number"
+	if q.MultiSelect {
+		hint = "numbers, comma-separated"
+	}
+	fmt.Print(color.YellowString("Answer (%s, or text): ", hint))
+	s.atLineStart = false
+}
+
+// handle processes a message from the server.
+func (s *chatSession) handle(msg *api.Message) {
+	var body api.ChatSocketMessage
+	if !strings.HasPrefix(msg.Type, "chat") && msg.Type != api.MsgError {
+		return
+	}
+	if err := msg.Decode(&body); err != nil {
+		return
+	}
+	if body.ConversationID != "" && body.ConversationID != s.id {
+		return
+	}
+
+	switch msg.Type {
+	case api.MsgChatEvent:
+		if body.Event != nil {
+			s.render(body.Event, false)
+		}
+	case api.MsgChatHistory:
+		messages := body.Messages
+		if len(messages) > s.history {
+			messages = messages[len(messages)-s.history:]
+		}
+		for _, m := range messages {
+			s.render(&api.ChatEvent{
+				ID:       m.ID,
+				Type:     m.Type,
+				Role:     m.Role,
+				Content:  m.Content,
+				ToolID:   m.ToolID,
+				ToolName: m.ToolName,
+				IsError:  m.IsError,
+			}, true)
+		}
+		s.flushTool()
+		s.endLine()
+		if len(messages) > 0 {
+			fmt.Println()
+		}
+	case api.MsgChatCancelled:
+		s.flushTool()
+		s.endLine()
+		color.Yellow("Interrupted")
+		s.busy = false
+		s.showPrompt()
+	case api.MsgChatError, api.MsgError:
+		s.flushTool()
+		s.endLine()
+		color.Red("Error: %s", body.Message)
+		s.busy = false
+		s.showPrompt()
+	}
+}
+
+// render prints an event. History is rendered without prompts.
+func (s *chatSession) render(ev *api.ChatEvent, history bool) {
+	if s.tool != nil && !(ev.Type == api.ChatEventToolUse && ev.ToolName == "" && ev.ToolID == s.tool.ToolID) {
+		s.flushTool()
+	}
+
+	switch ev.Type {
+	case api.ChatEventText:
+		if ev.Role == "user" {
+			s.endLine()
+			color.New(color.Bold).Printf("> %s\n", ev.Content)
+			return
+		}
+		if !history {
+			s.busy = true
+		}
+		s.stream(ev.ID, ev.Content, nil)
+		if !ev.IsPartial {
+			s.endLine()
+		}
+
+	case api.ChatEventThinking:
+		if s.thinking {
+			s.stream(ev.ID, ev.Content, color.New(color.FgHiBlack))
+		}
+
+	case api.ChatEventToolUse:
+		if ev.ToolName == "" {
+			if s.tool != nil {
+				s.toolInput.WriteString(ev.Content)
+			}
+			return
+		}
+		s.endLine()
+		color.New(color.FgCyan).Printf("⏺ %s", ev.ToolName)
+		s.atLineStart = false
+		s.tool = ev
+		s.toolInput.Reset()
+		if ev.IsPartial {
+			return
+		}
+		// Stored tool calls keep their input in Content
+		if len(ev.Input) > 0 {
+			s.toolInput.Write(ev.Input)
+		} else {
+			s.toolInput.WriteString(ev.Content)
+		}
+		s.flushTool()
+
+	case api.ChatEventToolResult:
+		s.endLine()
+		printToolResult(ev.Content, ev.IsError)
+
+	case api.ChatEventApprovalRequest, api.ChatEventQuestionPrompt:
+		if history {
+			return
+		}
+		if ev.Type == api.ChatEventQuestionPrompt && len(ev.Questions) == 0 {
+			return
+		}
+		// The turn is over until the user answers
+		s.busy = false
+		s.prompts = append(s.prompts, &chatPrompt{event: ev, answers: map[string][]string{}})
+		if len(s.prompts) == 1 {
+			s.showPrompt()
+		}
+
+	case api.ChatEventSessionEnd:
+		if ev.IsTurnComplete && !history {
+			s.endLine()
+			s.busy = false
+			s.showPrompt()
+		}
+
+	case api.ChatEventError:
+		s.endLine()
+		color.Red("%s", strings.TrimSpace(ev.Content))
+
+	case api.ChatEventSystem:
+		s.endLine()
+		color.HiBlack("%s", ev.Content)
+
+	case api.ChatEventTopicUpdated:
+		s.endLine()
+		color.HiBlack("Topic: %s", ev.Topic)
+	}
+}
+
+// stream appends content to the block with the given ID, starting a new
+// line for a new block.
+func (s *chatSession) stream(id, content string, c *color.Color) {
+	if s.block != id {
+		s.endLine()
+		s.block = id
+	}
+	if content == "" {
+		return
+	}
+	if c != nil {
+		c.Print(content)
+	} else {
+		fmt.Print(content)
+	}
+	s.atLineStart = strings.HasSuffix(content, "\n")
+}
+
+// endLine ends the current line of output, if any.
+func (s *chatSession) endLine() {
+	if !s.atLineStart {
+		fmt.Println()
+		s.atLineStart = true
+	}
+	s.block = ""
+}
+
+// flushTool finishes the line of the tool call whose input was streaming
+// with a summary of the input.
+func (s *chatSession) flushTool() {
+	if s.tool == nil {
+		return
+	}
+	if summary := toolSummary(s.toolInput.String()); summary != "" {
+		color.New(color.FgCyan).Printf("(%s)", summary)
+	}
+	fmt.Println()
+	s.atLineStart = true
+	s.tool = nil
+	s.toolInput.Reset()
+}
+
+// toolSummaryKeys are the tool input fields that best describe a call.
+var toolSummaryKeys = []string{"command", "file_path", "path", "pattern", "url", "query", "description", "prompt"}
+
+// toolSummary describes a tool call's JSON input in a line.
+func toolSummary(input string) string {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(input), &fields) != nil || len(fields) == 0 {
+		return ""
+	}
+	for _, key := range toolSummaryKeys {
+		if value, ok := fields[key].(string); ok && value != "" {
+			return truncateLine(value, 80)
+		}
+	}
+	data, _ := json.Marshal(fields)
+	return truncateLine(string(data), 80)
+}
+
+// printToolResult prints the first lines of a tool's output.
+func printToolResult(content string, isError bool) {
+	c := color.New(color.FgHiBlack)
+	if isError {
+		c = color.New(color.FgRed)
+	}
+
+	content = strings.TrimRight(content, "\n")
+	if content == "" {
+		c.Println("  ⎿  (no output)")
+		return
+	}
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if i == toolResultLines {
+			c.Printf("     … +%d lines\n", len(lines)-i)
+			break
+		}
+		prefix := "     "
+		if i == 0 {
+			prefix = "  ⎿  "
+		}
+		c.Println(prefix + truncateLine(line, 120))
+	}
+}
+
+// truncateLine shortens s to one line of at most n characters.
+func truncateLine(s string, n int) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " …"
+	}
+	if r := []rune(s); len(r) > n {
+		s = string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// chatRunTool is a tool call whose input is streaming.
+type chatRunTool struct {
+	name  string
+	input strings.Builder
+}
+
+// chatRun sends a prompt to a chat and waits for the agent to finish.
+type chatRun struct {
+	client      *api.Client
+	socket      *api.Socket
+	id          string
+	projectPath string
+	approve     string
+	policy      *approvals.Policy
+	quiet       bool
+
+	// answer is the text of the agent's latest message; messageEnded is set
+	// once that message is complete, so the next text starts a new one.
+	answer       strings.Builder
+	messageEnded bool
+	tools        map[string]*chatRunTool
+	// approvals are collected for approvalBatchDelay before answering.
+	approvals []*api.ChatEvent
+	// skipTurnEnds counts the turn ends the server sends after answering
+	// approvals, while the agent carries on with the follow-up.
+	skipTurnEnds int
+	errors       []string
+}
+
+// run delivers the prompt and, with wait, waits for the agent and prints
+// the result. It returns the exit status.
+func (r *chatRun) run(prompt string, wait bool, timeout time.Duration, transcript bool) int {
+	socket, err := r.client.Connect(context.Background())
+	if err != nil {
+		color.Red("Error: %v", err)
+		return 1
+	}
+	defer socket.Close()
+	r.socket = socket
+
+	if _, err := waitChatAttached(socket, r.id, r.projectPath); err != nil {
+		color.Red("Error: %v", err)
+		return 1
+	}
+	if err := socket.ChatSend(r.id, prompt, ""); err != nil {
+		color.Red("Error: %v", err)
+		return 1
+	}
+
+	if !wait {
+		if err := r.waitSent(); err != nil {
+			color.Red("Error: %v", err)
+			return 1
+		}
+		fmt.Println(r.id)
+		fmt.Fprintf(os.Stderr, "Attach with: nappctl chat attach %s\n", r.id)
+		return 0
+	}
+
+	err = r.wait(timeout)
+	socket.ChatDetach(r.id)
+	switch {
+	case errors.Is(err, errRunTimeout):
+		color.Red("Error: no answer after %s, interrupted the agent", timeout)
+		return 2
+	case err != nil:
+		color.Red("Error: %v", err)
+		return 1
+	}
+
+	if transcript {
+		messages, err := r.client.GetChatMessages(context.Background(), r.id, 0, false)
+		if err != nil {
+			color.Red("Error: %v", err)
+			return 1
+		}
+		for _, m := range messages {
+			data, _ := json.Marshal(m)
+			fmt.Println(string(data))
+		}
+	} else if answer := strings.TrimSpace(r.answer.String()); answer != "" {
+		fmt.Println(answer)
+	}
+
+	if len(r.errors) > 0 {
+		color.Red("Error: the agent reported %d error(s)", len(r.errors))
+		return 1
+	}
+	return 0
+}
+
+// waitSent waits for the server to confirm the prompt was delivered.
+func (r *chatRun) waitSent() error {
+	for {
+		msg, err := r.socket.Receive()
+		if err != nil {
+			return err
+		}
+		var body api.ChatSocketMessage
+		switch msg.Type {
+		case api.MsgChatMessageSent:
+			return nil
+		case api.MsgChatError, api.MsgError:
+			msg.Decode(&body)
+			return errors.New(body.Message)
+		}
+	}
+}
+
+// wait processes the chat's events until the agent finishes its turn.
+func (r *chatRun) wait(timeout time.Duration) error {
+	messages := make(chan *api.Message)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := r.socket.Receive()
+			if err != nil {
+				failed <- err
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	var batch <-chan time.Time
+
+	for {
+		select {
+		case <-deadline.C:
+			r.socket.ChatCancel(r.id)
+			return errRunTimeout
+
+		case err := <-failed:
+			return err
+
+		case <-batch:
+			batch = nil
+			if err := r.answerApprovals(); err != nil {
+				return err
+			}
+
+		case msg := <-messages:
+			var body api.ChatSocketMessage
+			if msg.Decode(&body) != nil || (body.ConversationID != "" && body.ConversationID != r.id) {
+				continue
+			}
+			switch msg.Type {
+			case api.MsgChatError, api.MsgError:
+				return errors.New(body.Message)
+			case api.MsgChatCancelled:
+				return errors.New("the chat was interrupted")
+			case api.MsgChatEvent:
+				if body.Event == nil {
+					continue
+				}
+				if body.Event.Type == api.ChatEventApprovalRequest && batch == nil {
+					batch = time.After(approvalBatchDelay)
+				}
+				if r.event(body.Event) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// event handles a chat event and reports whether the agent has finished.
+func (r *chatRun) event(ev *api.ChatEvent) bool {
+	switch ev.Type {
+	case api.ChatEventText:
+		if ev.Role == "user" {
+			return false
+		}
+		if r.messageEnded {
+			r.answer.Reset()
+			r.messageEnded = false
+		}
+		r.answer.WriteString(ev.Content)
+
+	case api.ChatEventToolUse:
+		if ev.ToolName != "" {
+			r.tools[ev.ToolID] = &chatRunTool{name: ev.ToolName}
+		} else if tool := r.tools[ev.ToolID]; tool != nil {
+			tool.input.WriteString(ev.Content)
+		}
+
+	case api.ChatEventToolResult:
+		tool := r.tools[ev.ToolID]
+		delete(r.tools, ev.ToolID)
+		if r.quiet || tool == nil {
+			return false
+		}
+		call := tool.name
+		if summary := toolSummary(tool.input.String()); summary != "" {
+			call += "(" + summary + ")"
+		}
+		result := "1 line"
+		if lines := strings.Count(strings.TrimRight(ev.Content, "\n"), "\n") + 1; lines > 1 {
+			result = fmt.Sprintf("%d lines", lines)
+		}
+		if ev.IsError {
+			result = color.RedString("%s", truncateLine(ev.Content, 120))
+		}
+		fmt.Fprintf(os.Stderr, "%s %s\n", color.CyanString("⏺ %s", call), color.HiBlackString("⎿ ")+result)
+
+	case api.ChatEventApprovalRequest:
+		r.approvals = append(r.approvals, ev)
+
+	case api.ChatEventQuestionPrompt:
+		// Nobody can answer; the agent gets an error and carries on
+		r.errors = append(r.errors, "the agent asked a question")
+		color.New(color.FgRed).Fprintln(os.Stderr, "The agent asked a question, which chat run can't answer")
+
+	case api.ChatEventError:
+		text := strings.TrimSpace(ev.Content)
+		r.errors = append(r.errors, text)
+		color.New(color.FgRed).Fprintln(os.Stderr, text)
+
+	case api.ChatEventSessionEnd:
+		if !ev.IsTurnComplete {
+			r.messageEnded = true
+			return false
+		}
+		if r.skipTurnEnds > 0 {
+			r.skipTurnEnds--
+			return false
+		}
+		var code int
+		if _, err := fmt.Sscanf(ev.Content, "Process ended with code %d", &code); err == nil && code != 0 {
+			r.errors = append(r.errors, ev.Content)
+			color.New(color.FgRed).Fprintln(os.Stderr, ev.Content)
+		}
+		return true
+	}
+	return false
+}
+
+// answerApprovals decides the collected approval requests with --approve.
+func (r *chatRun) answerApprovals() error {
+	var pending []api.PendingApproval
+	if r.policy != nil {
+		var err error
+		if pending, err = r.client.PendingApprovals(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	for _, ev := range r.approvals {
+		decision := approvals.Decision{Action: approvals.Deny, Rule: "--approve deny"}
+		if r.approve == "all" {
+			decision = approvals.Decision{Action: approvals.Allow, Rule: "--approve all"}
+		}
+
+		a := &api.PendingApproval{ConversationID: r.id, ToolUseID: ev.ToolID, ToolName: ev.ToolName, ProjectPath: r.projectPath}
+		if r.policy != nil {
+			for i := range pending {
+				if pending[i].ConversationID == r.id && pending[i].ToolUseID == ev.ToolID {
+					a = &pending[i]
+				}
+			}
+			decision = r.policy.Evaluate(approvals.Request{ToolName: a.ToolName, Input: a.Input, ProjectPath: r.projectPath})
+		}
+
+		if !r.quiet {
+			note := ""
+			if decision.Action == approvals.Ask {
+				note = " (denied, nobody to ask)"
+			}
+			fmt.Fprintf(os.Stderr, "%s %s %s%s\n", color.YellowString("?"), decision.Action, ev.Prompt, color.HiBlackString("%s [%s]", note, decision.Rule))
+		}
+		if err := r.socket.ChatApproval(r.id, ev.ToolID, decision.Action == approvals.Allow); err != nil {
+			return err
+		}
+	}
+
+	if len(r.approvals) > 0 {
+		r.skipTurnEnds++
+	}
+	r.approvals = nil
+	return nil
+}
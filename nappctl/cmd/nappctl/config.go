@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/auth"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
@@ -36,6 +37,7 @@ var configShowCmd = &cobra.Command{
 		table.Append([]string{"Host", cfg.Host})
 		table.Append([]string{"Data Directory", cfg.DataDir})
 		table.Append([]string{"Server URL", cfg.GetServerURL()})
+		table.Append([]string{"Secret Store", cfg.SecretStore})
 
 		if cfg.AuthToken != "" {
 			table.Append([]string{"Auth Token", "***" + cfg.AuthToken[len(cfg.AuthToken)-4:]})
@@ -50,7 +52,7 @@ var configShowCmd = &cobra.Command{
 var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set a configuration value",
-	Long:  "Set a configuration value. Available keys: port, host",
+	Long:  "Set a configuration value. Available keys: port, host, secret-store",
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
@@ -78,9 +80,16 @@ var configSetCmd = &cobra.Command{
 		case "host":
 			cfg.Host = value
 
+		case "secret-store":
+			if err := auth.ValidateBackend(value); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			cfg.SecretStore = value
+
 		default:
 			color.Red("Unknown config key: %s", key)
-			fmt.Println("Available keys: port, host")
+			fmt.Println("Available keys: port, host, secret-store")
 			os.Exit(1)
 		}
 
@@ -91,13 +100,16 @@ var configSetCmd = &cobra.Command{
 
 		color.Green("✓ Configuration updated")
 		fmt.Printf("%s = %s\n", key, value)
+		if key == "secret-store" {
+			fmt.Println("New tokens are saved there; to move the current one: nappctl auth store migrate " + value)
+		}
 	},
 }
 
 var configGetCmd = &cobra.Command{
 	Use:   "get <key>",
 	Short: "Get a configuration value",
-	Long:  "Get a specific configuration value. Available keys: port, host, data-dir, server-url",
+	Long:  "Get a specific configuration value. Available keys: port, host, data-dir, server-url, secret-store",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		key := args[0]
@@ -117,6 +129,8 @@ var configGetCmd = &cobra.Command{
 			fmt.Println(cfg.DataDir)
 		case "server-url":
 			fmt.Println(cfg.GetServerURL())
+		case "secret-store":
+			fmt.Println(cfg.SecretStore)
 		case "auth-token":
 			if cfg.AuthToken != "" {
 				fmt.Println(cfg.AuthToken)
@@ -125,7 +139,7 @@ var configGetCmd = &cobra.Command{
 			}
 		default:
 			color.Red("Unknown config key: %s", key)
-			fmt.Println("Available keys: port, host, data-dir, server-url, auth-token")
+			fmt.Println("Available keys: port, host, data-dir, server-url, secret-store, auth-token")
 			os.Exit(1)
 		}
 	},
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/auth"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/server"
 	"github.com/fatih/color"
@@ -216,6 +217,20 @@ func checkAuthToken() error {
 		return fmt.Errorf("no auth.json found")
 	}
 
+	// Reading migrates a legacy nappctl-format file
+	token, err := auth.GetToken(authPath)
+	if err != nil {
+		return err
+	}
+	backend, err := auth.CurrentBackend(authPath)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("no token in the %s secret store", backend)
+	}
+
+	color.White("  Secret store: %s", backend)
 	return nil
 }
 
@@ -295,28 +310,13 @@ func fixAuthToken() error {
 		return err
 	}
 
-	authPath := config.GetAuthPath(dataDir)
-
-	// Check if auth file exists with server format
-	if data, err := os.ReadFile(authPath); err == nil {
-		// If file exists and has content, don't overwrite
-		if len(data) > 0 {
-			return nil
-		}
-	}
-
-	// Generate new token in nappctl format
-	token := generateToken()
-	authData := fmt.Sprintf(`{
-  "token": "%s",
-  "createdAt": "%s"
-}`, token, getCurrentTimestamp())
-
-	if err := os.MkdirAll(filepath.Dir(authPath), 0755); err != nil {
+	cfg, err := config.Load()
+	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(authPath, []byte(authData), 0600)
+	_, err = auth.CreateAndSaveToken(config.GetAuthPath(dataDir), cfg.SecretStore)
+	return err
 }
 
 func fixServerPathConfig() error {
@@ -339,24 +339,3 @@ func fixServerPathConfig() error {
 
 	return config.Save(cfg)
 }
-
-// Helper functions
-
-func generateToken() string {
-	// Simple UUID v4 generation
-	b := make([]byte, 16)
-	if _, err := os.ReadFile("/dev/urandom"); err == nil {
-		f, _ := os.Open("/dev/urandom")
-		defer f.Close()
-		f.Read(b)
-	}
-
-	b[6] = (b[6] & 0x0f) | 0x40
-	b[8] = (b[8] & 0x3f) | 0x80
-
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
-}
-
-func getCurrentTimestamp() string {
-	return "2026-02-10T00:00:00Z" // Simple ISO format timestamp
-}
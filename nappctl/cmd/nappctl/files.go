@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// maxDiffQuery bounds the query string of /api/files/diff, which carries
+// both file contents and must fit in Node's default 16KB header limit.
+const maxDiffQuery = 14 * 1024
+
+var filesCmd = &cobra.Command{
+	Use:     "files",
+	Aliases: []string{"file"},
+	Short:   "Read and write files on the server",
+	Long: `Read, write, list, move and delete files on the server's machine through
+the same authenticated API the mobile app uses.
+
+Remote paths are paths on the server; ~ is expanded by 'files ls' only.`,
+}
+
+var filesCatCmd = &cobra.Command{
+	Use:   "cat <remote>...",
+	Short: "Print remote files",
+	Long:  "Print the contents of remote files to stdout. Binary files are written as raw bytes.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		for _, remote := range args {
+			file, err := client.ReadFile(ctx, remote)
+			if err != nil {
+				color.Red("Error: %s: %v", remote, err)
+				os.Exit(1)
+			}
+			data, err := file.Bytes()
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			os.Stdout.Write(data)
+		}
+	},
+}
+
+var filesPutCmd = &cobra.Command{
+	Use:   "put <local>... <remote>",
+	Short: "Upload files to the server",
+	Long: `Upload local files to the server, overwriting existing files.
+
+With one local file, <remote> is the destination file unless it ends in /
+or is an existing directory. With several, <remote> is the destination
+directory, which is created if needed. A local path of - reads stdin.
+
+Files are streamed, up to 50 MB each.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		locals, remote := args[:len(args)-1], args[len(args)-1]
+
+		destDir, name := remote, ""
+		if len(locals) == 1 && !strings.HasSuffix(remote, "/") {
+			// A path that does not exist or is a file is the destination file
+			_, err := client.ListDir(ctx, remote)
+			if api.IsNotFound(err) || api.StatusCode(err) == http.StatusBadRequest {
+				destDir, name = path.Dir(remote), path.Base(remote)
+			} else if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+		}
+		if name == "" {
+			for _, local := range locals {
+				if local == "-" {
+					color.Red("Error: uploading stdin needs a destination file path")
+					os.Exit(1)
+				}
+			}
+		}
+
+		for _, local := range locals {
+			if local == "-" {
+				continue
+			}
+			info, err := os.Stat(local)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			if info.IsDir() {
+				color.Red("Error: %s is a directory", local)
+				os.Exit(1)
+			}
+			if info.Size() > api.MaxUploadSize {
+				color.Red("Error: %s is %s, over the server's %s upload limit", local, formatBytes(info.Size()), formatBytes(api.MaxUploadSize))
+				os.Exit(1)
+			}
+		}
+
+		progress := !quiet && output != "json" && isTerminal(os.Stderr)
+		total := &api.UploadResult{}
+
+		for start := 0; start < len(locals); start += api.MaxUploadFiles {
+			end := start + api.MaxUploadFiles
+			if end > len(locals) {
+				end = len(locals)
+			}
+
+			files, closeFiles, err := openUploads(locals[start:end], name, progress)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			result, err := client.UploadFiles(ctx, destDir, files)
+			closeFiles()
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+
+			total.Uploaded = append(total.Uploaded, result.Uploaded...)
+			total.Errors = append(total.Errors, result.Errors...)
+			total.TotalUploaded += result.TotalUploaded
+			total.TotalFailed += result.TotalFailed
+		}
+
+		if output == "json" {
+			printJSON(total)
+		} else {
+			for _, f := range total.Uploaded {
+				color.Green("✓ %s (%s)", f.Path, formatBytes(f.Size))
+			}
+			for _, e := range total.Errors {
+				color.Red("✗ %s: %s", e.Name, e.Error)
+			}
+		}
+		if total.TotalFailed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var filesLsCmd = &cobra.Command{
+	Use:     "ls [remote-dir]",
+	Aliases: []string{"list"},
+	Short:   "List a remote directory",
+	Long:    "List a directory on the server, directories first. Hidden entries are not shown. Defaults to ~.",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		client := mustAPIClient(cmd)
+
+		dir := "~"
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		listing, err := client.ListDir(context.Background(), dir)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(listing)
+			return
+		}
+
+		if len(listing.Items) == 0 {
+			color.Yellow("%s is empty", listing.ResolvedPath)
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Name", "Size", "Modified"})
+		table.SetBorder(false)
+		table.SetColumnSeparator("")
+
+		for _, item := range listing.Items {
+			if item.IsDirectory {
+				table.Append([]string{color.BlueString(item.Name + "/"), "-", formatTime(item.Modified)})
+			} else {
+				table.Append([]string{item.Name, formatBytes(item.Size), formatTime(item.Modified)})
+			}
+		}
+
+		table.Render()
+	},
+}
+
+var filesMvCmd = &cobra.Command{
+	Use:     "mv <remote-src> <remote-dst>",
+	Aliases: []string{"move"},
+	Short:   "Move or rename a remote file or directory",
+	Long: `Move a file or directory on the server. If <remote-dst> is an existing
+directory, the source is moved into it. Existing files are not overwritten.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		src, dst := args[0], args[1]
+		if strings.HasSuffix(dst, "/") {
+			dst = path.Join(dst, path.Base(src))
+		}
+
+		err := client.MoveFile(ctx, src, dst)
+		if api.IsConflict(err) {
+			// Like mv(1), moving onto a directory moves into it
+			if _, listErr := client.ListDir(ctx, dst); listErr == nil {
+				dst = path.Join(dst, path.Base(src))
+				err = client.MoveFile(ctx, src, dst)
+			}
+		}
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Moved %s to %s", src, dst)
+	},
+}
+
+var filesRmCmd = &cobra.Command{
+	Use:     "rm <remote>...",
+	Aliases: []string{"delete"},
+	Short:   "Delete remote files",
+	Long:    "Delete files on the server. Directories cannot be deleted.",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		if !force && !confirm(fmt.Sprintf("Delete %s on the server?", strings.Join(args, ", "))) {
+			color.Yellow("Cancelled")
+			os.Exit(0)
+		}
+
+		failed := false
+		for _, remote := range args {
+			if err := client.DeleteFile(ctx, remote); err != nil {
+				color.Red("Error: %s: %v", remote, err)
+				failed = true
+				continue
+			}
+			color.Green("✓ Deleted %s", remote)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var filesMkdirCmd = &cobra.Command{
+	Use:   "mkdir <remote-dir>...",
+	Short: "Create remote directories",
+	Long:  "Create directories on the server, including missing parents.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		parents, _ := cmd.Flags().GetBool("parents")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		for _, dir := range args {
+			err := client.Mkdir(ctx, dir)
+			if parents && api.IsConflict(err) {
+				continue
+			}
+			if err != nil {
+				color.Red("Error: %s: %v", dir, err)
+				os.Exit(1)
+			}
+			color.Green("✓ Created %s", dir)
+		}
+	},
+}
+
+var filesDiffCmd = &cobra.Command{
+	Use:   "diff <remote> [local]",
+	Short: "Diff a remote file against a local one",
+	Long: `Show a unified diff from a remote file to a local file, or to stdin when
+[local] is omitted or -. Exits with status 1 when the files differ.
+
+The diff is computed by the server, which limits it to small text files.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := mustAPIClient(cmd)
+
+		remote, local := args[0], "-"
+		if len(args) == 2 {
+			local = args[1]
+		}
+
+		var (
+			modified []byte
+			err      error
+		)
+		if local == "-" {
+			modified, err = io.ReadAll(os.Stdin)
+		} else {
+			modified, err = os.ReadFile(local)
+		}
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		file, err := client.ReadFile(context.Background(), remote)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		original, err := file.Bytes()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if string(original) == string(modified) {
+			return
+		}
+		if file.IsBinary {
+			fmt.Printf("Binary files %s and %s differ\n", remote, local)
+			os.Exit(1)
+		}
+		// The server rejects empty contents on either side
+		if len(original) == 0 || len(modified) == 0 {
+			color.Red("Error: cannot diff against an empty file")
+			os.Exit(2)
+		}
+		query := url.Values{"filePath": {remote}, "original": {string(original)}, "modified": {string(modified)}}
+		if len(query.Encode()) > maxDiffQuery {
+			color.Red("Error: files are too large for a server-side diff; try: nappctl files cat %s | diff -u - %s", remote, local)
+			os.Exit(2)
+		}
+
+		diff, err := client.Diff(context.Background(), remote, string(original), string(modified))
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(2)
+		}
+		printDiff(diff)
+		os.Exit(1)
+	},
+}
+
+func init() {
+	addRemoteFlags(filesCmd)
+
+	addOutputFlag(filesPutCmd)
+	filesPutCmd.Flags().BoolP("quiet", "q", false, "Do not show upload progress")
+
+	addOutputFlag(filesLsCmd)
+
+	filesRmCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
+
+	filesMkdirCmd.Flags().BoolP("parents", "p", false, "No error if the directory exists")
+
+	filesCmd.AddCommand(filesCatCmd)
+	filesCmd.AddCommand(filesPutCmd)
+	filesCmd.AddCommand(filesLsCmd)
+	filesCmd.AddCommand(filesMvCmd)
+	filesCmd.AddCommand(filesRmCmd)
+	filesCmd.AddCommand(filesMkdirCmd)
+	filesCmd.AddCommand(filesDiffCmd)
+}
+
+// openUploads opens local files for UploadFiles. A non-empty name renames
+// the (single) upload. The returned func closes the files.
+func openUploads(locals []string, name string, progress bool) ([]api.UploadFile, func(), error) {
+	var (
+		files   []api.UploadFile
+		closers []io.Closer
+	)
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+
+	for _, local := range locals {
+		var (
+			r    io.Reader = os.Stdin
+			size int64     = -1
+		)
+		if local != "-" {
+			f, err := os.Open(local)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			closers = append(closers, f)
+			r = f
+			if info, err := f.Stat(); err == nil {
+				size = info.Size()
+			}
+		}
+
+		upload := api.UploadFile{Name: filepath.Base(local), Content: r}
+		if name != "" {
+			upload.Name = name
+		}
+		if progress {
+			upload.Content = &progressReader{r: r, name: upload.Name, size: size}
+		}
+		files = append(files, upload)
+	}
+
+	return files, closeAll, nil
+}
+
+// progressReader reports how much of a file has been read on stderr.
+type progressReader struct {
+	r    io.Reader
+	name string
+	size int64 // -1 if unknown
+	read int64
+	last time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+
+	if err == io.EOF || time.Since(p.last) >= 100*time.Millisecond {
+		p.last = time.Now()
+		line := fmt.Sprintf("%s  %s", p.name, formatBytes(p.read))
+		if p.size > 0 {
+			line += fmt.Sprintf(" / %s (%d%%)", formatBytes(p.size), p.read*100/p.size)
+		}
+		fmt.Fprintf(os.Stderr, "\r%-70s", line)
+		if err == io.EOF {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+	return n, err
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printDiff prints a unified diff with added and removed lines colored.
+func printDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "):
+			color.New(color.Bold).Println(line)
+		case strings.HasPrefix(line, "+"):
+			color.Green("%s", line)
+		case strings.HasPrefix(line, "-"):
+			color.Red("%s", line)
+		case strings.HasPrefix(line, "@@"):
+			color.Cyan("%s", line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Review and commit changes in a project on the server",
+	Long: `Run git operations on a project's repository on the server through its
+/api/git endpoints, e.g. to review and commit changes an agent made.
+
+Every command needs --project (ID, path or name). Use --repo for a nested
+repository, relative to the project.`,
+}
+
+var gitStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the working tree status",
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		repo := mustGitRepo(cmd)
+
+		status, err := repo.Status(context.Background())
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(status)
+			return
+		}
+
+		branch := "On branch " + status.Branch
+		var tracking []string
+		if status.Ahead > 0 {
+			tracking = append(tracking, fmt.Sprintf("ahead %d", status.Ahead))
+		}
+		if status.Behind > 0 {
+			tracking = append(tracking, fmt.Sprintf("behind %d", status.Behind))
+		}
+		if len(tracking) > 0 {
+			branch += " (" + strings.Join(tracking, ", ") + ")"
+		}
+		fmt.Println(branch)
+
+		if status.Clean() {
+			fmt.Println("Nothing to commit, working tree clean")
+			return
+		}
+
+		printChanges("Staged changes:", status.Staged, color.GreenString)
+		printChanges("Unstaged changes:", status.Unstaged, color.RedString)
+
+		if len(status.Untracked) > 0 {
+			fmt.Println()
+			fmt.Println("Untracked files:")
+			for _, file := range status.Untracked {
+				fmt.Println("  " + color.RedString(file))
+			}
+		}
+	},
+}
+
+var gitDiffCmd = &cobra.Command{
+	Use:   "diff [file]...",
+	Short: "Show changes",
+	Long: `Show the unstaged changes to the given files, or to the whole repository.
+With --staged, show the staged changes; with --commit, the changes made by
+a commit. Untracked files are not included.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		staged, _ := cmd.Flags().GetBool("staged")
+		commit, _ := cmd.Flags().GetString("commit")
+		maxLines, _ := cmd.Flags().GetInt("max-lines")
+		repo := mustGitRepo(cmd)
+		ctx := context.Background()
+
+		files := args
+		if len(files) == 0 {
+			files = []string{"."}
+		}
+
+		opts := api.GitDiffOptions{Staged: staged, CommitHash: commit, MaxLines: maxLines}
+		for _, file := range files {
+			diff, err := repo.Diff(ctx, file, opts)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			if diff.Diff != "" {
+				printDiff(diff.Diff)
+			}
+		}
+	},
+}
+
+var gitStageCmd = &cobra.Command{
+	Use:     "stage [file]...",
+	Aliases: []string{"add"},
+	Short:   "Stage files",
+	Run: func(cmd *cobra.Command, args []string) {
+		files := gitFileArgs(cmd, args)
+		repo := mustGitRepo(cmd)
+
+		if err := repo.Stage(context.Background(), files); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Staged %s", describeFiles(files))
+	},
+}
+
+var gitUnstageCmd = &cobra.Command{
+	Use:   "unstage [file]...",
+	Short: "Unstage files",
+	Run: func(cmd *cobra.Command, args []string) {
+		files := gitFileArgs(cmd, args)
+		repo := mustGitRepo(cmd)
+
+		if err := repo.Unstage(context.Background(), files); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Unstaged %s", describeFiles(files))
+	},
+}
+
+var gitCommitCmd = &cobra.Command{
+	Use:   "commit [file]...",
+	Short: "Commit staged changes",
+	Long: `Commit the staged changes. Files given as arguments, or everything with
+--all, are staged first.
+
+With --generate, the server writes the commit message from the staged
+changes; it is shown for confirmation before committing.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		message, _ := cmd.Flags().GetString("message")
+		generate, _ := cmd.Flags().GetBool("generate")
+		all, _ := cmd.Flags().GetBool("all")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if message == "" && !generate {
+			color.Red("Error: a commit message is required (use --message or --generate)")
+			os.Exit(1)
+		}
+
+		files := args
+		if all {
+			files = []string{"."}
+		}
+
+		repo := mustGitRepo(cmd)
+		ctx := context.Background()
+
+		if generate && message == "" {
+			if len(files) > 0 {
+				if err := repo.Stage(ctx, files); err != nil {
+					color.Red("Error: %v", err)
+					os.Exit(1)
+				}
+				files = nil
+			}
+
+			fmt.Println("Generating commit message...")
+			suggestion, err := repo.GenerateCommitMessage(ctx)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			message = suggestion.Message
+
+			fmt.Println()
+			fmt.Println(message)
+			fmt.Println()
+			if !force && !confirm("Commit with this message?") {
+				color.Yellow("Cancelled")
+				os.Exit(0)
+			}
+		}
+
+		result, err := repo.Commit(ctx, message, files)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if result.Hash == "" {
+			color.Green("✓ Committed")
+			return
+		}
+		color.Green("✓ Committed %s", shortHash(result.Hash))
+	},
+}
+
+var gitBranchesCmd = &cobra.Command{
+	Use:     "branches",
+	Aliases: []string{"branch"},
+	Short:   "List branches",
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		remote, _ := cmd.Flags().GetBool("remote")
+		repo := mustGitRepo(cmd)
+
+		branches, err := repo.Branches(context.Background())
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if !remote {
+			var local []api.GitBranch
+			for _, b := range branches {
+				if !b.IsRemote {
+					local = append(local, b)
+				}
+			}
+			branches = local
+		}
+
+		if output == "json" {
+			printJSON(branches)
+			return
+		}
+
+		for _, b := range branches {
+			switch {
+			case b.IsCurrent:
+				fmt.Println("* " + color.GreenString(b.Name))
+			case b.IsRemote:
+				fmt.Println("  " + color.RedString("remotes/"+b.Name))
+			default:
+				fmt.Println("  " + b.Name)
+			}
+		}
+	},
+}
+
+var gitCheckoutCmd = &cobra.Command{
+	Use:   "checkout <branch>",
+	Short: "Switch branches",
+	Long: `Check out a branch. A remote branch gets a local tracking branch. With
+--create, a new branch is created at --start-point (default HEAD).`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		create, _ := cmd.Flags().GetBool("create")
+		startPoint, _ := cmd.Flags().GetString("start-point")
+		repo := mustGitRepo(cmd)
+		ctx := context.Background()
+
+		var (
+			result *api.GitResult
+			err    error
+		)
+		if create {
+			result, err = repo.CreateBranch(ctx, args[0], startPoint, true)
+		} else {
+			result, err = repo.Checkout(ctx, args[0])
+		}
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		branch := result.Branch
+		if branch == "" {
+			branch = args[0]
+		}
+		color.Green("✓ Switched to branch %s", branch)
+	},
+}
+
+var gitLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show commit history",
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		limit, _ := cmd.Flags().GetInt("limit")
+		skip, _ := cmd.Flags().GetInt("skip")
+		repo := mustGitRepo(cmd)
+
+		commits, err := repo.Log(context.Background(), limit, skip)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(commits)
+			return
+		}
+
+		for _, c := range commits {
+			line := color.YellowString(c.ShortHash)
+			if len(c.Refs) > 0 {
+				line += " " + color.CyanString("("+strings.Join(c.Refs, ", ")+")")
+			}
+			line += " " + c.Subject
+			line += " " + color.HiBlackString("- %s, %s", c.Author.Name, formatTime(c.Timestamp.Time()))
+			fmt.Println(line)
+		}
+	},
+}
+
+func init() {
+	addRemoteFlags(gitCmd)
+	gitCmd.PersistentFlags().StringP("project", "p", "", "Project ID, path or name")
+	gitCmd.PersistentFlags().String("repo", "", "Nested repository path, relative to the project")
+
+	addOutputFlag(gitStatusCmd)
+
+	gitDiffCmd.Flags().Bool("staged", false, "Show staged changes")
+	gitDiffCmd.Flags().String("commit", "", "Show the changes made by this commit")
+	gitDiffCmd.Flags().Int("max-lines", 0, "Truncate each diff (server default 2000 lines)")
+
+	gitStageCmd.Flags().BoolP("all", "A", false, "Stage all changes")
+	gitUnstageCmd.Flags().BoolP("all", "A", false, "Unstage all changes")
+
+	gitCommitCmd.Flags().StringP("message", "m", "", "Commit message")
+	gitCommitCmd.Flags().Bool("generate", false, "Have the server generate the commit message")
+	gitCommitCmd.Flags().BoolP("all", "a", false, "Stage all changes first")
+	gitCommitCmd.Flags().BoolP("force", "f", false, "Commit a generated message without confirmation")
+
+	addOutputFlag(gitBranchesCmd)
+	gitBranchesCmd.Flags().BoolP("remote", "r", false, "Include remote branches")
+
+	gitCheckoutCmd.Flags().BoolP("create", "b", false, "Create the branch")
+	gitCheckoutCmd.Flags().String("start-point", "", "Commit or branch to create the branch at")
+
+	addOutputFlag(gitLogCmd)
+	gitLogCmd.Flags().IntP("limit", "n", 10, "Number of commits")
+	gitLogCmd.Flags().Int("skip", 0, "Number of commits to skip")
+
+	gitCmd.AddCommand(gitStatusCmd)
+	gitCmd.AddCommand(gitDiffCmd)
+	gitCmd.AddCommand(gitStageCmd)
+	gitCmd.AddCommand(gitUnstageCmd)
+	gitCmd.AddCommand(gitCommitCmd)
+	gitCmd.AddCommand(gitBranchesCmd)
+	gitCmd.AddCommand(gitCheckoutCmd)
+	gitCmd.AddCommand(gitLogCmd)
+}
+
+// mustGitRepo returns the git API for the --project and --repo flags,
+// exiting if the project cannot be resolved.
+func mustGitRepo(cmd *cobra.Command) *api.GitRepo {
+	ref, _ := cmd.Flags().GetString("project")
+	repoPath, _ := cmd.Flags().GetString("repo")
+	if ref == "" {
+		color.Red("Error: --project is required")
+		os.Exit(1)
+	}
+
+	client := mustAPIClient(cmd)
+	project := mustResolveProject(context.Background(), client, ref)
+	return client.Git(project.ID, repoPath)
+}
+
+// gitFileArgs returns the files to stage or unstage: the arguments, or
+// the whole repository with --all.
+func gitFileArgs(cmd *cobra.Command, args []string) []string {
+	all, _ := cmd.Flags().GetBool("all")
+	if all {
+		return []string{"."}
+	}
+	if len(args) == 0 {
+		color.Red("Error: no files given (use --all for every change)")
+		os.Exit(1)
+	}
+	return args
+}
+
+// describeFiles names the files passed to stage or unstage.
+func describeFiles(files []string) string {
+	if len(files) == 1 && files[0] == "." {
+		return "all changes"
+	}
+	return strings.Join(files, ", ")
+}
+
+// printChanges prints a section of git status.
+func printChanges(title string, changes []api.GitFileChange, colorize func(string, ...interface{}) string) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println(title)
+	for _, c := range changes {
+		file := c.Path
+		if c.OldPath != "" {
+			file = c.OldPath + " -> " + c.Path
+		}
+		fmt.Printf("  %s\n", colorize("%-10s %s", c.Status+":", file))
+	}
+}
+
+// shortHash abbreviates a commit hash.
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,569 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/data"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/logs"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse chat history",
+	Long: `Browse the chat history stored in chat-persistence.db in the data directory.
+The database is read directly, so this works while the server is stopped.`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List conversations",
+	Long: `List conversations, most recently active first.
+
+--since accepts an RFC 3339 timestamp, a YYYY-MM-DD date or a duration
+relative to now (e.g. 2h, 168h).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		project, _ := cmd.Flags().GetString("project")
+		status, _ := cmd.Flags().GetString("status")
+		since, _ := cmd.Flags().GetString("since")
+		limit, _ := cmd.Flags().GetInt("limit")
+		offset, _ := cmd.Flags().GetInt("offset")
+
+		query := data.ConversationQuery{Status: status, Limit: limit, Offset: offset}
+		if project != "" {
+			abs, err := filepath.Abs(project)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			query.Project = abs
+		}
+		var err error
+		if query.Since, err = logs.ParseTime(since, time.Now()); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		history := mustOpenHistory()
+		defer history.Close()
+
+		conversations, err := history.ListConversations(query)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			if conversations == nil {
+				conversations = []data.Conversation{}
+			}
+			printJSON(conversations)
+			return
+		}
+
+		if len(conversations) == 0 {
+			color.Yellow("No conversations found")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"ID", "Topic", "Tool", "Status", "Messages", "Last Active", "Project"})
+		table.SetBorder(false)
+		table.SetColumnSeparator("")
+
+		for _, c := range conversations {
+			table.Append([]string{
+				c.ID,
+				truncateLine(c.Topic, 40),
+				c.Tool,
+				c.Status,
+				fmt.Sprint(c.MessageCount),
+				formatTime(c.LastActivity),
+				c.ProjectPath,
+			})
+		}
+
+		table.Render()
+		if limit > 0 && len(conversations) == limit {
+			color.HiBlack("More conversations may follow: use --offset %d", offset+limit)
+		}
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <conversation-id>",
+	Short: "Show a conversation's messages",
+	Long: `Show a conversation's messages, oldest first. The ID may be abbreviated to
+any unique prefix.
+
+--type restricts the messages shown to text, thinking, tool (tool calls
+and their results), error, or event (approvals, questions, session and
+system events). By default all but thinking and events are shown.
+
+--limit and --offset page through the messages; with --tail the page is
+counted back from the newest message.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		limit, _ := cmd.Flags().GetInt("limit")
+		offset, _ := cmd.Flags().GetInt("offset")
+		tail, _ := cmd.Flags().GetBool("tail")
+		full, _ := cmd.Flags().GetBool("full")
+
+		history := mustOpenHistory()
+		defer history.Close()
+
+		conversation, err := history.Conversation(args[0])
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		query := data.MessageQuery{Kinds: messageKinds(cmd), Limit: limit, Offset: offset, Last: tail}
+		messages, err := history.Messages(conversation.ID, query)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			if messages == nil {
+				messages = []data.Message{}
+			}
+			printJSON(struct {
+				Conversation *data.Conversation `json:"conversation"`
+				Messages     []data.Message     `json:"messages"`
+			}{conversation, messages})
+			return
+		}
+
+		fmt.Printf("%s %s\n", color.New(color.Bold).Sprint(conversation.Topic), color.HiBlackString(conversation.ID))
+		fmt.Printf("Project: %s\n", conversation.ProjectPath)
+		model := conversation.Tool
+		if conversation.Model != "" {
+			model += " (" + conversation.Model + ")"
+		}
+		fmt.Printf("Tool:    %s, %s mode\n", model, conversation.Mode)
+		fmt.Printf("Status:  %s, last active %s\n", conversation.Status, formatTime(conversation.LastActivity))
+		fmt.Println()
+
+		if len(messages) == 0 {
+			color.Yellow("No matching messages")
+			return
+		}
+		for i := range messages {
+			if i > 0 && messages[i].Type == "text" && messages[i].Role == "user" {
+				fmt.Println()
+			}
+			printHistoryMessage(&messages[i], full)
+		}
+		if limit > 0 && len(messages) == limit {
+			fmt.Println()
+			color.HiBlack("More messages may follow: use --offset %d", offset+limit)
+		}
+	},
+}
+
+var historyExportCmd = &cobra.Command{
+	Use:   "export [conversation-id]",
+	Short: "Export conversations as Markdown, JSON or HTML",
+	Long: `Export a conversation as a transcript to attach to a code review: md
+(Markdown), html (a self-contained page), json (the conversation and its
+messages) or jsonl (one message per line).
+
+With --project and --dir, every conversation in the project (optionally
+filtered by --status and --since) is exported to the directory as
+<conversation-id>.<format>.`,
+	Example: `  nappctl history export 3f2a --format md --file transcript.md
+  nappctl history export --project ~/src/web --dir transcripts --format html`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		file, _ := cmd.Flags().GetString("file")
+		project, _ := cmd.Flags().GetString("project")
+		dir, _ := cmd.Flags().GetString("dir")
+		status, _ := cmd.Flags().GetString("status")
+		since, _ := cmd.Flags().GetString("since")
+
+		if !data.ValidExportFormat(format) {
+			color.Red("Unknown format: %s (use %s)", format, strings.Join(data.ExportFormats, ", "))
+			os.Exit(1)
+		}
+		if (len(args) == 1) == (project != "") {
+			color.Red("Error: give a conversation ID or --project")
+			os.Exit(1)
+		}
+		if project != "" && dir == "" {
+			color.Red("Error: --project needs --dir")
+			os.Exit(1)
+		}
+
+		history := mustOpenHistory()
+		defer history.Close()
+		query := data.MessageQuery{Kinds: messageKinds(cmd)}
+
+		if len(args) == 1 {
+			conversation, err := history.Conversation(args[0])
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			if file == "" {
+				err = exportConversation(history, os.Stdout, format, conversation, query)
+			} else {
+				err = exportConversationFile(history, file, format, conversation, query)
+			}
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			if file != "" {
+				color.Green("✓ Exported %s to %s", conversation.ID, file)
+			}
+			return
+		}
+
+		abs, err := filepath.Abs(project)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		convQuery := data.ConversationQuery{Project: abs, Status: status}
+		if convQuery.Since, err = logs.ParseTime(since, time.Now()); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		conversations, err := history.ListConversations(convQuery)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if len(conversations) == 0 {
+			color.Yellow("No conversations found in %s", abs)
+			return
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		for i := range conversations {
+			c := &conversations[i]
+			out := filepath.Join(dir, c.ID+"."+format)
+			if err := exportConversationFile(history, out, format, c, query); err != nil {
+				color.Red("Error exporting %s: %v", c.ID, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s  %s\n", out, c.Topic)
+		}
+		color.Green("✓ Exported %d conversation(s) to %s", len(conversations), dir)
+	},
+}
+
+var historySearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search message content across conversations",
+	Long: `Search the content of every conversation's messages, best match first.
+
+Every word of the query must occur in a message; a trailing * matches a
+prefix. With --raw the query is an SQLite FTS5 expression, e.g.
+'"exact phrase" OR migrat*'.
+
+The index is kept in history-search.db in the data directory, separate
+from the server's database, and is brought up to date before each search.
+--since and --until accept the same times as history list.`,
+	Example: `  nappctl history search fixed the migration
+  nappctl history search 'flaky test*' --project ~/src/web --since 720h`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		project, _ := cmd.Flags().GetString("project")
+		tool, _ := cmd.Flags().GetString("tool")
+		since, _ := cmd.Flags().GetString("since")
+		until, _ := cmd.Flags().GetString("until")
+		limit, _ := cmd.Flags().GetInt("limit")
+		offset, _ := cmd.Flags().GetInt("offset")
+		raw, _ := cmd.Flags().GetBool("raw")
+		reindex, _ := cmd.Flags().GetBool("reindex")
+
+		query := data.SearchQuery{
+			Text:           strings.Join(args, " "),
+			Raw:            raw,
+			Tool:           tool,
+			Limit:          limit,
+			Offset:         offset,
+			HighlightStart: "**",
+			HighlightEnd:   "**",
+		}
+		if output != "json" {
+			query.HighlightStart, query.HighlightEnd = snippetMarkStart, snippetMarkEnd
+		}
+		if project != "" {
+			abs, err := filepath.Abs(project)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			query.Project = abs
+		}
+		now := time.Now()
+		var err error
+		if query.Since, err = logs.ParseTime(since, now); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if query.Until, err = logs.ParseTime(until, now); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		dataDir, err := config.ResolveDataDir()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		index, err := data.OpenSearchIndex(config.GetSearchIndexPath(dataDir), config.GetDBPath(dataDir))
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		defer index.Close()
+
+		sync := index.Sync
+		if reindex {
+			sync = index.Rebuild
+		}
+		added, err := sync()
+		if err != nil {
+			color.Red("Error updating search index: %v", err)
+			os.Exit(1)
+		}
+		if added > 0 && output != "json" {
+			color.HiBlack("Indexed %d message(s)", added)
+		}
+
+		hits, err := index.Search(query)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			if hits == nil {
+				hits = []data.SearchHit{}
+			}
+			printJSON(hits)
+			return
+		}
+
+		if len(hits) == 0 {
+			color.Yellow("No matching messages")
+			return
+		}
+		for i, h := range hits {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s %s\n", color.New(color.Bold).Sprint(h.Topic), color.HiBlackString(h.ConversationID))
+			who := h.Type
+			if h.Role != "" {
+				who = h.Role
+			}
+			if h.ToolName != "" {
+				who += " " + h.ToolName
+			}
+			color.HiBlack("%s  %s  %s  %s", formatTime(h.Timestamp), h.Tool, who, h.ProjectPath)
+			fmt.Println(highlightSnippet(strings.Join(strings.Fields(h.Snippet), " ")))
+		}
+		if limit > 0 && len(hits) == limit {
+			fmt.Println()
+			color.HiBlack("More matches may follow: use --offset %d", offset+limit)
+		}
+	},
+}
+
+func init() {
+	historyListCmd.Flags().StringP("project", "p", "", "Only conversations in this project directory")
+	historyListCmd.Flags().String("status", "", "Only conversations with this status (created, running, suspended, ended)")
+	historyListCmd.Flags().String("since", "", "Only conversations active at or after this time")
+	historyListCmd.Flags().IntP("limit", "n", 50, "Maximum number of conversations (0 for all)")
+	historyListCmd.Flags().Int("offset", 0, "Number of conversations to skip")
+	addOutputFlag(historyListCmd)
+
+	addMessageTypeFlag(historyShowCmd)
+	historyShowCmd.Flags().IntP("limit", "n", 0, "Maximum number of messages (0 for all)")
+	historyShowCmd.Flags().Int("offset", 0, "Number of messages to skip")
+	historyShowCmd.Flags().Bool("tail", false, "Page from the newest message")
+	historyShowCmd.Flags().Bool("full", false, "Show tool results in full")
+	addOutputFlag(historyShowCmd)
+
+	historyExportCmd.Flags().StringP("format", "f", data.FormatMarkdown, "Export format: md, json, jsonl or html")
+	historyExportCmd.Flags().String("file", "", "Write to this file instead of stdout")
+	historyExportCmd.Flags().StringP("project", "p", "", "Export every conversation in this project directory")
+	historyExportCmd.Flags().String("dir", "", "Directory to export a project's conversations to")
+	historyExportCmd.Flags().String("status", "", "With --project, only conversations with this status")
+	historyExportCmd.Flags().String("since", "", "With --project, only conversations active at or after this time")
+	addMessageTypeFlag(historyExportCmd)
+
+	historySearchCmd.Flags().StringP("project", "p", "", "Only conversations in this project directory")
+	historySearchCmd.Flags().String("tool", "", "Only conversations with this tool (e.g. claude, cursor-agent)")
+	historySearchCmd.Flags().String("since", "", "Only messages at or after this time")
+	historySearchCmd.Flags().String("until", "", "Only messages before this time")
+	historySearchCmd.Flags().IntP("limit", "n", 20, "Maximum number of matches (0 for all)")
+	historySearchCmd.Flags().Int("offset", 0, "Number of matches to skip")
+	historySearchCmd.Flags().Bool("raw", false, "Pass the query to SQLite FTS5 as an expression")
+	historySearchCmd.Flags().Bool("reindex", false, "Rebuild the search index from scratch")
+	addOutputFlag(historySearchCmd)
+
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyExportCmd)
+	historyCmd.AddCommand(historySearchCmd)
+}
+
+// addMessageTypeFlag adds the --type flag read by messageKinds.
+func addMessageTypeFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceP("type", "t", []string{data.KindText, data.KindTool, data.KindError},
+		"Message types: text, thinking, tool, error, event or all")
+}
+
+// messageKinds returns the message kinds selected with --type, or nil for
+// all.
+func messageKinds(cmd *cobra.Command) []string {
+	kinds, _ := cmd.Flags().GetStringSlice("type")
+	if len(kinds) == 1 && kinds[0] == "all" {
+		return nil
+	}
+	return kinds
+}
+
+// mustOpenHistory opens the chat database in the data directory, exiting
+// on errors.
+func mustOpenHistory() *data.History {
+	dataDir, err := config.ResolveDataDir()
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	history, err := data.OpenHistory(config.GetDBPath(dataDir))
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	return history
+}
+
+// exportConversation writes a conversation's messages matching query to w.
+func exportConversation(history *data.History, w io.Writer, format string, c *data.Conversation, query data.MessageQuery) error {
+	messages, err := history.Messages(c.ID, query)
+	if err != nil {
+		return err
+	}
+	return data.Export(w, format, c, messages)
+}
+
+// exportConversationFile is exportConversation to a file.
+func exportConversationFile(history *data.History, file, format string, c *data.Conversation, query data.MessageQuery) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := exportConversation(history, f, format, c, query); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Search snippets are marked up with control characters that
+// highlightSnippet replaces with color.
+const (
+	snippetMarkStart = "\x02"
+	snippetMarkEnd   = "\x03"
+)
+
+// highlightSnippet colors the matched terms of a search snippet.
+func highlightSnippet(snippet string) string {
+	highlight := color.New(color.FgYellow, color.Bold)
+	var b strings.Builder
+	for {
+		start := strings.Index(snippet, snippetMarkStart)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(snippet[start:], snippetMarkEnd)
+		if end < 0 {
+			break
+		}
+		b.WriteString(snippet[:start])
+		b.WriteString(highlight.Sprint(snippet[start+len(snippetMarkStart) : start+end]))
+		snippet = snippet[start+end+len(snippetMarkEnd):]
+	}
+	b.WriteString(snippet)
+	return b.String()
+}
+
+// printHistoryMessage prints a stored message the way chat attach renders
+// it.
+func printHistoryMessage(m *data.Message, full bool) {
+	switch m.Type {
+	case "text":
+		if m.Role == "user" {
+			color.New(color.Bold).Printf("> %s", m.Content)
+			color.HiBlack("  %s", formatTime(m.Timestamp))
+			return
+		}
+		fmt.Println(strings.TrimRight(m.Content, "\n"))
+
+	case "thinking":
+		color.HiBlack("%s", strings.TrimRight(m.Content, "\n"))
+
+	case "tool_use_start":
+		input := m.Content
+		if raw, ok := m.Metadata["input"]; ok {
+			if encoded, err := json.Marshal(raw); err == nil && string(encoded) != "{}" {
+				input = string(encoded)
+			}
+		}
+		color.New(color.FgCyan).Print("⏺ " + m.ToolName)
+		if summary := toolSummary(input); summary != "" {
+			color.New(color.FgCyan).Printf("(%s)", summary)
+		}
+		fmt.Println()
+
+	case "tool_use_result":
+		if full {
+			c := color.New(color.FgHiBlack)
+			if m.IsError {
+				c = color.New(color.FgRed)
+			}
+			c.Println(strings.TrimRight(m.Content, "\n"))
+			return
+		}
+		printToolResult(m.Content, m.IsError)
+
+	case "error":
+		color.Red("%s", strings.TrimSpace(m.Content))
+
+	default:
+		text := m.Content
+		if prompt, ok := m.Metadata["prompt"].(string); ok && prompt != "" {
+			text = prompt
+		}
+		if topic, ok := m.Metadata["topic"].(string); ok && m.Type == "topic_updated" {
+			text = topic
+		}
+		color.HiBlack("[%s] %s", m.Type, truncateLine(strings.TrimSpace(text), 120))
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/logs"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Read server logs over the API",
+	Long: `Read the logs of a running Napp Trapp server through its authenticated
+/api/logs endpoints. Use --server and --token to read a colleague's server
+(e.g. over Tailscale) without SSH access.
+
+For logs of the local server on disk, use 'nappctl server logs'.`,
+}
+
+var logsRemoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Show server logs",
+	Long: `Show log entries from the server.
+
+Without --date, entries come from the server's in-memory buffer of recent
+logs. With --date, the given day's log file is read. With --follow, new
+entries are polled for and printed as they arrive.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		date, _ := cmd.Flags().GetString("date")
+		level, _ := cmd.Flags().GetString("level")
+		category, _ := cmd.Flags().GetString("category")
+		limit, _ := cmd.Flags().GetInt("limit")
+		follow, _ := cmd.Flags().GetBool("follow")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		jsonOut, _ := cmd.Flags().GetBool("json")
+
+		if date != "" && follow {
+			color.Red("Error: --follow cannot be combined with --date")
+			os.Exit(1)
+		}
+		if level != "" && logs.LevelRank(level) < 0 {
+			color.Red("Error: unknown level %q (use %s)", level, strings.Join(logs.Levels, ", "))
+			os.Exit(1)
+		}
+
+		client := mustAPIClient(cmd)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		query := api.LogQuery{Limit: limit, Level: level, Category: category}
+
+		var (
+			entries []api.LogEntry
+			err     error
+		)
+		if date != "" {
+			entries, err = client.GetLogsForDate(ctx, date, query)
+		} else {
+			entries, err = client.GetLogs(ctx, query)
+		}
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		// The server returns newest first; print oldest first like tail
+		var last *api.LogEntry
+		for i := len(entries) - 1; i >= 0; i-- {
+			printLogEntry(remoteLogEntry(entries[i]), jsonOut)
+			last = &entries[i]
+		}
+
+		if !follow {
+			if len(entries) == 0 {
+				color.Yellow("No log entries")
+			}
+			return
+		}
+
+		if err := followRemoteLogs(ctx, client, query, last, interval, jsonOut); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var logsDatesCmd = &cobra.Command{
+	Use:   "dates",
+	Short: "List dates with server log files",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := mustAPIClient(cmd)
+
+		dates, err := client.GetLogDates(context.Background())
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if len(dates) == 0 {
+			color.Yellow("No log files on the server")
+			return
+		}
+		for _, date := range dates {
+			fmt.Println(date)
+		}
+	},
+}
+
+var logsClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all server logs",
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+
+		if !force && !confirm("Delete all log files on the server?") {
+			color.Yellow("Cancelled")
+			os.Exit(0)
+		}
+
+		client := mustAPIClient(cmd)
+
+		if err := client.ClearLogs(context.Background()); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Server logs cleared")
+	},
+}
+
+func init() {
+	addRemoteFlags(logsCmd)
+
+	logsRemoteCmd.Flags().String("date", "", "Read the log file for this date (YYYY-MM-DD)")
+	logsRemoteCmd.Flags().StringP("level", "l", "", "Minimum level: debug, info, warn or error")
+	logsRemoteCmd.Flags().StringP("category", "c", "", "Only entries whose category contains this text")
+	logsRemoteCmd.Flags().IntP("limit", "n", 100, "Maximum number of entries (server caps at 500, or 1000 with --date)")
+	logsRemoteCmd.Flags().BoolP("follow", "f", false, "Poll for new entries")
+	logsRemoteCmd.Flags().Duration("interval", 2*time.Second, "Polling interval for --follow")
+	logsRemoteCmd.Flags().Bool("json", false, "Print entries as raw JSON lines")
+
+	logsClearCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
+
+	logsCmd.AddCommand(logsRemoteCmd)
+	logsCmd.AddCommand(logsDatesCmd)
+	logsCmd.AddCommand(logsClearCmd)
+}
+
+// followRemoteLogs polls /api/logs for entries newer than last until ctx
+// is cancelled. The server's since filter is inclusive, so entries already
+// printed at the boundary timestamp are skipped.
+func followRemoteLogs(ctx context.Context, client *api.Client, query api.LogQuery, last *api.LogEntry, interval time.Duration, jsonOut bool) error {
+	query.Limit = 500
+	seen := map[string]bool{}
+	if last != nil {
+		query.Since = last.Timestamp
+		seen[remoteLogKey(*last)] = true
+	} else {
+		query.Since = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		entries, err := client.GetLogs(ctx, query)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for i := len(entries) - 1; i >= 0; i-- {
+			key := remoteLogKey(entries[i])
+			if seen[key] {
+				continue
+			}
+			printLogEntry(remoteLogEntry(entries[i]), jsonOut)
+
+			if entries[i].Timestamp != query.Since {
+				query.Since = entries[i].Timestamp
+				seen = map[string]bool{}
+			}
+			seen[key] = true
+		}
+	}
+}
+
+// remoteLogKey identifies an entry for de-duplication across polls.
+func remoteLogKey(e api.LogEntry) string {
+	return e.Timestamp + "\x00" + e.Category + "\x00" + e.Message + "\x00" + string(e.Data)
+}
+
+// remoteLogEntry converts an API log entry for printing with printLogEntry.
+func remoteLogEntry(e api.LogEntry) *logs.Entry {
+	raw, _ := json.Marshal(e)
+	entry := &logs.Entry{
+		Level:    e.Level,
+		Category: e.Category,
+		Message:  e.Message,
+		Data:     e.Data,
+		Raw:      string(raw),
+	}
+	entry.Timestamp, _ = time.Parse(time.RFC3339Nano, e.Timestamp)
+	return entry
+}
+
+// addLogFilterFlags registers the filter flags shared by log commands.
+func addLogFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("level", "l", "", "Minimum level: debug, info, warn or error")
+	cmd.Flags().StringP("category", "c", "", "Only entries whose category contains this text")
+	cmd.Flags().String("since", "", "Only entries at or after this time")
+	cmd.Flags().String("until", "", "Only entries at or before this time")
+	cmd.Flags().StringP("grep", "g", "", "Only entries whose message or data match this regular expression")
+	cmd.Flags().Bool("json", false, "Print entries as raw JSON lines")
+}
+
+// logFilterFromFlags builds a logs.Filter from the flags added by
+// addLogFilterFlags.
+func logFilterFromFlags(cmd *cobra.Command) (*logs.Filter, error) {
+	level, _ := cmd.Flags().GetString("level")
+	category, _ := cmd.Flags().GetString("category")
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	grep, _ := cmd.Flags().GetString("grep")
+
+	filter := &logs.Filter{
+		MinLevel: strings.ToLower(level),
+		Category: category,
+	}
+
+	if level != "" && logs.LevelRank(level) < 0 {
+		return nil, fmt.Errorf("unknown level %q (use %s)", level, strings.Join(logs.Levels, ", "))
+	}
+
+	now := time.Now()
+	var err error
+	if filter.Since, err = logs.ParseTime(since, now); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = logs.ParseTime(until, now); err != nil {
+		return nil, err
+	}
+
+	if grep != "" {
+		if filter.Grep, err = regexp.Compile(grep); err != nil {
+			return nil, fmt.Errorf("invalid --grep pattern: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+// printLogEntry prints a log entry, either as its raw JSON line or as a
+// colored one-line summary.
+func printLogEntry(entry *logs.Entry, jsonOut bool) {
+	if jsonOut || !entry.Structured() {
+		fmt.Println(entry.Raw)
+		return
+	}
+
+	level := fmt.Sprintf("%-5s", strings.ToUpper(entry.Level))
+	switch entry.Level {
+	case "error":
+		level = color.RedString(level)
+	case "warn":
+		level = color.YellowString(level)
+	case "info":
+		level = color.GreenString(level)
+	default:
+		level = color.HiBlackString(level)
+	}
+
+	line := fmt.Sprintf("%s %s %s %s",
+		color.HiBlackString(entry.Timestamp.Local().Format("2006-01-02 15:04:05.000")),
+		level,
+		color.CyanString("["+entry.Category+"]"),
+		entry.Message,
+	)
+	if len(entry.Data) > 0 && string(entry.Data) != "null" {
+		line += " " + color.HiBlackString(string(entry.Data))
+	}
+	fmt.Println(line)
+}
@@ -36,6 +36,15 @@ func init() {
 	rootCmd.AddCommand(dataCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(serviceCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(projectsCmd)
+	rootCmd.AddCommand(filesCmd)
+	rootCmd.AddCommand(gitCmd)
+	rootCmd.AddCommand(termCmd)
+	rootCmd.AddCommand(chatCmd)
+	rootCmd.AddCommand(approvalsCmd)
+	rootCmd.AddCommand(historyCmd)
 }
 
 func main() {
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var projectsCmd = &cobra.Command{
+	Use:     "projects",
+	Aliases: []string{"project"},
+	Short:   "Manage projects on the server",
+	Long: `List, inspect, create and remove the projects the server knows about,
+the same ones the mobile app shows.
+
+Projects can be referred to by ID, path or name.`,
+}
+
+var projectsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List projects",
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		client := mustAPIClient(cmd)
+
+		projects, err := client.ListProjects(context.Background())
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(projects)
+			return
+		}
+
+		if len(projects) == 0 {
+			color.Yellow("No projects")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Name", "Path", "Last Opened", "ID"})
+		table.SetBorder(false)
+		table.SetColumnSeparator("")
+
+		for _, p := range projects {
+			table.Append([]string{p.Name, p.Path, formatTime(p.LastOpened), p.ID})
+		}
+
+		table.Render()
+	},
+}
+
+var projectsShowCmd = &cobra.Command{
+	Use:   "show <project>",
+	Short: "Show project details",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		project := mustResolveProject(ctx, client, args[0])
+
+		if output == "json" {
+			printJSON(project)
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Setting", "Value"})
+		table.SetBorder(false)
+		table.SetColumnSeparator("")
+
+		table.Append([]string{"Name", project.Name})
+		table.Append([]string{"Path", project.Path})
+		table.Append([]string{"Last Opened", formatTime(project.LastOpened)})
+		table.Append([]string{"ID", project.ID})
+
+		if chats, err := client.ListProjectChats(ctx, project.ID); err == nil {
+			table.Append([]string{"Chat Windows", fmt.Sprintf("%d", len(chats))})
+		}
+
+		table.Render()
+	},
+}
+
+var projectsTreeCmd = &cobra.Command{
+	Use:   "tree <project>",
+	Short: "Show the project file tree",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		depth, _ := cmd.Flags().GetInt("depth")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		project := mustResolveProject(ctx, client, args[0])
+
+		tree, err := client.GetProjectTree(ctx, project.ID, depth)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(tree)
+			return
+		}
+
+		color.New(color.Bold).Println(project.Path)
+		printTree(tree, "")
+	},
+}
+
+var projectsCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new project",
+	Long: `Create a project directory on the server with a README, .gitignore and src/
+folder, and add it to the project list.
+
+With --git the server also runs git init and creates a private GitHub
+repository with the gh CLI.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		parent, _ := cmd.Flags().GetString("path")
+		template, _ := cmd.Flags().GetString("template")
+		git, _ := cmd.Flags().GetBool("git")
+		client := mustAPIClient(cmd)
+
+		project, err := client.CreateProject(context.Background(), api.CreateProjectRequest{
+			Name:          args[0],
+			Path:          parent,
+			Template:      template,
+			CreateGitRepo: git,
+		})
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(project)
+			return
+		}
+
+		color.Green("✓ Project created")
+		fmt.Printf("Path: %s\n", project.Path)
+		if project.GitRepoURL != "" {
+			fmt.Printf("Repository: %s\n", project.GitRepoURL)
+		}
+		if project.GitError != "" {
+			color.Yellow("Warning: %s", project.GitError)
+		}
+	},
+}
+
+var projectsOpenFolderCmd = &cobra.Command{
+	Use:   "open-folder <path>",
+	Short: "Add an existing folder on the server as a project",
+	Long: `Register an existing directory as a project. The path is resolved on the
+server; ~ expands to the server user's home directory.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		client := mustAPIClient(cmd)
+
+		project, err := client.OpenFolder(context.Background(), args[0])
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(project)
+			return
+		}
+
+		color.Green("✓ Project added: %s", project.Name)
+		fmt.Printf("Path: %s\n", project.Path)
+		fmt.Printf("ID: %s\n", project.ID)
+	},
+}
+
+var projectsDeleteCmd = &cobra.Command{
+	Use:     "delete <project>",
+	Aliases: []string{"remove", "rm"},
+	Short:   "Remove a project from the list",
+	Long:    "Remove a project from the server's project list. Files on disk are not deleted.",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		project := mustResolveProject(ctx, client, args[0])
+
+		if !force && !confirm(fmt.Sprintf("Remove %s (%s) from the project list?", project.Name, project.Path)) {
+			color.Yellow("Cancelled")
+			os.Exit(0)
+		}
+
+		if err := client.RemoveProject(ctx, project.ID); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Project removed from list (files were not deleted)")
+	},
+}
+
+func init() {
+	addRemoteFlags(projectsCmd)
+
+	addOutputFlag(projectsListCmd)
+	addOutputFlag(projectsShowCmd)
+
+	addOutputFlag(projectsTreeCmd)
+	projectsTreeCmd.Flags().Int("depth", 3, "Maximum depth")
+
+	addOutputFlag(projectsCreateCmd)
+	projectsCreateCmd.Flags().String("path", "", "Parent directory on the server (default: ~/Projects)")
+	projectsCreateCmd.Flags().String("template", "", "Project template")
+	projectsCreateCmd.Flags().Bool("git", false, "Initialise git and create a private GitHub repository")
+
+	addOutputFlag(projectsOpenFolderCmd)
+
+	projectsDeleteCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
+
+	projectsCmd.AddCommand(projectsListCmd)
+	projectsCmd.AddCommand(projectsShowCmd)
+	projectsCmd.AddCommand(projectsTreeCmd)
+	projectsCmd.AddCommand(projectsCreateCmd)
+	projectsCmd.AddCommand(projectsOpenFolderCmd)
+	projectsCmd.AddCommand(projectsDeleteCmd)
+}
+
+// mustResolveProject finds a project by ID, path or name, exiting if there
+// is no single match.
+func mustResolveProject(ctx context.Context, client *api.Client, ref string) *api.Project {
+	project, err := resolveProject(ctx, client, ref)
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	return project
+}
+
+// resolveProject finds a project by ID, path or name.
+func resolveProject(ctx context.Context, client *api.Client, ref string) (*api.Project, error) {
+	projects, err := client.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range projects {
+		if projects[i].ID == ref || projects[i].Path == ref {
+			return &projects[i], nil
+		}
+	}
+
+	var matches []*api.Project
+	for i := range projects {
+		if strings.EqualFold(projects[i].Name, ref) || filepath.Base(projects[i].Path) == ref {
+			matches = append(matches, &projects[i])
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		// The server also resolves IDs of directories not in the list
+		project, err := client.GetProject(ctx, ref)
+		if err != nil {
+			if api.IsNotFound(err) {
+				return nil, fmt.Errorf("project not found: %s", ref)
+			}
+			return nil, err
+		}
+		return project, nil
+	default:
+		var paths []string
+		for _, p := range matches {
+			paths = append(paths, p.Path)
+		}
+		return nil, fmt.Errorf("%q matches several projects, use the path or ID: %s", ref, strings.Join(paths, ", "))
+	}
+}
+
+// printTree prints a file tree with box-drawing guides.
+func printTree(nodes []api.TreeNode, prefix string) {
+	for i, node := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		if node.IsDir() {
+			fmt.Println(prefix + branch + color.BlueString(node.Name+"/"))
+			printTree(node.Children, prefix+next)
+		} else {
+			fmt.Println(prefix + branch + node.Name)
+		}
+	}
+}
+
+// formatTime formats a timestamp for tables, or "-" when unset.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/auth"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// addRemoteFlags registers the flags that select which server an API
+// command talks to. They are persistent so every subcommand inherits them.
+func addRemoteFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("server", "", "Server URL, e.g. http://100.64.0.5:3847 (default: from config)")
+	cmd.PersistentFlags().String("token", "", "Auth token, required with --server (default: auth_token from config, then auth.json)")
+}
+
+// newAPIClient creates an authenticated API client from --server/--token,
+// falling back to the configured server URL and the local auth token. The
+// local token is only sent to the configured server, never to a --server
+// given on the command line.
+func newAPIClient(cmd *cobra.Command) (*api.Client, error) {
+	serverURL, _ := cmd.Flags().GetString("server")
+	token, _ := cmd.Flags().GetString("token")
+
+	if serverURL != "" && token == "" {
+		return nil, fmt.Errorf("--token is required with --server")
+	}
+
+	if serverURL == "" || token == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+		serverURL = cfg.GetServerURL()
+		if token == "" {
+			token = cfg.AuthToken
+		}
+		if token == "" {
+			dataDir, err := config.ResolveDataDir()
+			if err != nil {
+				return nil, err
+			}
+			token, err = auth.GetToken(config.GetAuthPath(dataDir))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if token == "" {
+		return nil, fmt.Errorf("no auth token found; pass --token or run 'nappctl auth generate'")
+	}
+
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "http://" + serverURL
+	}
+	return api.NewClient(serverURL, token), nil
+}
+
+// mustAPIClient is newAPIClient for command handlers: it exits on error.
+func mustAPIClient(cmd *cobra.Command) *api.Client {
+	client, err := newAPIClient(cmd)
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	return client
+}
+
+// addOutputFlag registers --output/-o for commands that can print JSON.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+}
+
+// outputFormat returns the validated --output value, exiting on an unknown
+// format.
+func outputFormat(cmd *cobra.Command) string {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		color.Red("Unknown output format: %s (use text or json)", output)
+		os.Exit(1)
+	}
+	return output
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+// confirm asks a yes/no question and reports whether the answer was yes.
+func confirm(prompt string) bool {
+	fmt.Printf("%s (y/N): ", prompt)
+	var response string
+	fmt.Scanln(&response)
+	return response == "y" || response == "Y"
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/logs"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/server"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/service"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -23,24 +27,98 @@ var serverCmd = &cobra.Command{
 var serverStartCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start the server",
-	Long:  "Start the Napp Trapp server in the background.",
+	Long: `Start the Napp Trapp server in the background.
+
+With --wait, the command blocks until the server answers /health and exits
+non-zero (printing the end of the server log) if it crashes or does not
+become healthy within --wait-timeout.
+
+With --supervise, nappctl stays in the foreground as a supervisor and
+restarts the server with exponential backoff if it crashes, giving up after
+--max-restarts crashes within --restart-window. SIGINT/SIGTERM stop the
+server; SIGHUP restarts it.
+
+If the systemd user service is installed ('nappctl service install'), the
+server is started through systemd instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		port, _ := cmd.Flags().GetInt("port")
 		detach, _ := cmd.Flags().GetBool("detach")
+		supervise, _ := cmd.Flags().GetBool("supervise")
 
-		if err := startServer(port, detach); err != nil {
+		if serviceManaged() {
+			if supervise || !detach {
+				color.Red("Error: the server is managed by the %s systemd unit", service.UnitName)
+				fmt.Println("Foreground modes are unavailable until you run: nappctl service uninstall")
+				os.Exit(1)
+			}
+			runServiceAction(cmd, "start", service.Start)
+			return
+		}
+
+		sup, err := newSupervisor(cmd)
+		if err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
+
+		if supervise {
+			policy := server.DefaultRestartPolicy()
+			policy.MaxRestarts, _ = cmd.Flags().GetInt("max-restarts")
+			policy.Window, _ = cmd.Flags().GetDuration("restart-window")
+			policy.InitialBackoff, _ = cmd.Flags().GetDuration("backoff")
+			policy.MaxBackoff, _ = cmd.Flags().GetDuration("max-backoff")
+
+			color.Green("Supervising server on port %d...", sup.Port())
+			if err := sup.Supervise(policy); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if !detach {
+			color.Green("Starting server on port %d...", sup.Port())
+			if err := sup.Run(); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		info, err := sup.Start()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		printStarted(sup, info)
+		waitIfRequested(cmd, sup, info)
 	},
 }
 
 var serverStopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop the server",
-	Long:  "Stop the running Napp Trapp server.",
+	Long: `Stop the running Napp Trapp server.
+
+The server is sent SIGINT and given --timeout to shut down gracefully.
+With --force it is killed with SIGKILL if it has not exited by then.
+If the systemd user service is installed, the unit is stopped instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := stopServer(); err != nil {
+		if serviceManaged() {
+			if err := service.Stop(); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			color.Green("✓ Server stopped (%s)", service.UnitName)
+			return
+		}
+
+		sup, err := newSupervisor(cmd)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if err := stopServer(sup); err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
@@ -52,51 +130,87 @@ var serverRestartCmd = &cobra.Command{
 	Short: "Restart the server",
 	Long:  "Stop and start the Napp Trapp server.",
 	Run: func(cmd *cobra.Command, args []string) {
-		port, _ := cmd.Flags().GetInt("port")
+		if serviceManaged() {
+			runServiceAction(cmd, "restart", service.Restart)
+			return
+		}
 
-		// Try to stop existing server
-		stopServer() // Ignore error if not running
+		sup, err := newSupervisor(cmd)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
 
-		// Wait a bit for clean shutdown
-		time.Sleep(1 * time.Second)
+		// Try to stop existing server
+		if err := stopServer(sup); err != nil && !errors.Is(err, server.ErrNotRunning) {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
 
-		// Start server
-		if err := startServer(port, true); err != nil {
+		info, err := sup.Start()
+		if err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
+		printStarted(sup, info)
+		waitIfRequested(cmd, sup, info)
 	},
 }
 
 var serverStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Check server status",
-	Long:  "Check if the Napp Trapp server is running.",
+	Long: `Check if the Napp Trapp server is running and healthy.
+
+The server's /health and /discover endpoints are probed on the port recorded
+in the PID file, and process memory/CPU usage is read from /proc when available.
+
+Exit codes:
+  0  running and healthy
+  2  running but not answering /health
+  3  not running`,
 	Run: func(cmd *cobra.Command, args []string) {
-		dataDir, err := config.ResolveDataDir()
+		output, _ := cmd.Flags().GetString("output")
+
+		sup, err := newSupervisor(cmd)
 		if err != nil {
-			color.Red("Error resolving data directory: %v", err)
+			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
-		pidPath := config.GetPidPath(dataDir)
 
-		pid, err := pidfile.GetRunningPID(pidPath)
+		st, err := inspectService(sup)
 		if err != nil {
 			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
+		if st == nil {
+			st, err = sup.Inspect()
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+		}
 
-		if pid == 0 {
-			color.Yellow("Server is not running")
+		switch output {
+		case "json":
+			data, err := json.MarshalIndent(st, "", "  ")
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		case "text":
+			printStatus(st)
+		default:
+			color.Red("Unknown output format: %s (use text or json)", output)
 			os.Exit(1)
 		}
 
-		color.Green("Server is running (PID: %d)", pid)
-
-		// Try to get config for URL
-		cfg, err := config.Load()
-		if err == nil {
-			fmt.Printf("URL: %s\n", cfg.GetServerURL())
+		switch st.State {
+		case server.StateStopped:
+			os.Exit(3)
+		case server.StateUnhealthy:
+			os.Exit(2)
 		}
 	},
 }
@@ -104,54 +218,110 @@ var serverStatusCmd = &cobra.Command{
 var serverLogsCmd = &cobra.Command{
 	Use:   "logs",
 	Short: "View server logs",
-	Long:  "Display the server log file.",
+	Long: `Display the server's structured logs.
+
+The server writes JSON log entries to daily server-YYYY-MM-DD.log files;
+these are read across days, filtered and printed with colored levels (or as
+raw JSON with --json). With --follow, new entries are streamed as they are
+written, continuing into the next day's file after rotation.
+
+Use --stdout to read the captured console output of a server started by
+nappctl (logs/server.log, or --log-file) instead.
+
+--since and --until accept an RFC 3339 timestamp, a YYYY-MM-DD date or a
+duration relative to now (e.g. 30m, 2h).`,
 	Run: func(cmd *cobra.Command, args []string) {
 		follow, _ := cmd.Flags().GetBool("follow")
 		lines, _ := cmd.Flags().GetInt("lines")
+		stdout, _ := cmd.Flags().GetBool("stdout")
+		dir, _ := cmd.Flags().GetString("dir")
+		jsonOut, _ := cmd.Flags().GetBool("json")
 
-		dataDir, err := config.ResolveDataDir()
+		sup, err := newSupervisor(cmd)
 		if err != nil {
-			color.Red("Error resolving data directory: %v", err)
+			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
-		logsPath := config.GetLogsPath(dataDir)
 
-		logFile := filepath.Join(logsPath, "server.log")
-		if _, err := os.Stat(logFile); os.IsNotExist(err) {
-			color.Yellow("No log file found at %s", logFile)
+		filter, err := logFilterFromFlags(cmd)
+		if err != nil {
+			color.Red("Error: %v", err)
 			os.Exit(1)
 		}
 
+		var src logs.Source
+		switch {
+		case stdout:
+			src.File = sup.LogPath()
+		case dir != "":
+			src.Dir = dir
+		default:
+			src.Dir = logs.ResolveDir(sup.DataDir())
+		}
+
+		entries, err := logs.Tail(src, lines, filter)
+		if err != nil {
+			color.Red("Error reading logs: %v", err)
+			os.Exit(1)
+		}
+		if len(entries) == 0 && !follow {
+			color.Yellow("No matching log entries in %s", src)
+			return
+		}
+		for _, entry := range entries {
+			printLogEntry(entry, jsonOut)
+		}
+
 		if follow {
-			// Use tail -f for following
-			cmd := exec.Command("tail", "-f", "-n", fmt.Sprintf("%d", lines), logFile)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				color.Red("Error following logs: %v", err)
-				os.Exit(1)
-			}
-		} else {
-			// Just show last N lines
-			cmd := exec.Command("tail", "-n", fmt.Sprintf("%d", lines), logFile)
-			output, err := cmd.Output()
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			err := logs.Follow(ctx, src, filter, func(entry *logs.Entry) {
+				printLogEntry(entry, jsonOut)
+			})
 			if err != nil {
-				color.Red("Error reading logs: %v", err)
+				color.Red("Error following logs: %v", err)
 				os.Exit(1)
 			}
-			fmt.Print(string(output))
 		}
 	},
 }
 
 func init() {
-	serverStartCmd.Flags().IntP("port", "p", 3847, "Server port")
+	for _, c := range []*cobra.Command{serverStartCmd, serverRestartCmd} {
+		c.Flags().IntP("port", "p", server.DefaultPort, "Server port")
+		c.Flags().String("token", "", "Auth token to inject as AUTH_TOKEN (overrides auth.json)")
+	}
 	serverStartCmd.Flags().BoolP("detach", "D", true, "Run in background")
 
-	serverRestartCmd.Flags().IntP("port", "p", 3847, "Server port")
+	defaultPolicy := server.DefaultRestartPolicy()
+	serverStartCmd.Flags().Bool("supervise", false, "Run in the foreground and restart the server if it crashes")
+	serverStartCmd.Flags().Int("max-restarts", defaultPolicy.MaxRestarts, "Crash restarts allowed within --restart-window (with --supervise)")
+	serverStartCmd.Flags().Duration("restart-window", defaultPolicy.Window, "Window for counting crash restarts (with --supervise)")
+	serverStartCmd.Flags().Duration("backoff", defaultPolicy.InitialBackoff, "Initial delay before restarting a crashed server (with --supervise)")
+	serverStartCmd.Flags().Duration("max-backoff", defaultPolicy.MaxBackoff, "Maximum delay between restarts (with --supervise)")
+
+	for _, c := range []*cobra.Command{serverStartCmd, serverRestartCmd} {
+		c.Flags().BoolP("wait", "w", false, "Wait until the server answers /health before returning")
+		c.Flags().Duration("wait-timeout", server.DefaultReadyTimeout, "How long --wait waits for the server to become healthy")
+	}
+
+	for _, c := range []*cobra.Command{serverStopCmd, serverRestartCmd} {
+		c.Flags().Duration("timeout", server.DefaultStopTimeout, "How long to wait for a graceful shutdown")
+		c.Flags().Bool("force", false, "Send SIGKILL if the server does not stop within --timeout")
+	}
+
+	for _, c := range []*cobra.Command{serverStartCmd, serverRestartCmd, serverLogsCmd} {
+		c.Flags().String("log-file", "", "Server log file (default: <data-dir>/logs/server.log)")
+	}
+
+	serverStatusCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
 
 	serverLogsCmd.Flags().BoolP("follow", "f", false, "Follow log output")
-	serverLogsCmd.Flags().IntP("lines", "n", 50, "Number of lines to show")
+	serverLogsCmd.Flags().IntP("lines", "n", 50, "Number of entries to show (0 for all)")
+	serverLogsCmd.Flags().Bool("stdout", false, "Read the captured console output (server.log) instead of structured logs")
+	serverLogsCmd.Flags().String("dir", "", "Directory containing server-YYYY-MM-DD.log files")
+	addLogFilterFlags(serverLogsCmd)
 
 	serverCmd.AddCommand(serverStartCmd)
 	serverCmd.AddCommand(serverStopCmd)
@@ -160,137 +330,122 @@ func init() {
 	serverCmd.AddCommand(serverLogsCmd)
 }
 
-func startServer(port int, detach bool) error {
-	// Check if already running
-	dataDir, err := config.ResolveDataDir()
-	if err != nil {
-		return err
-	}
-	pidPath := config.GetPidPath(dataDir)
+// newSupervisor builds a server.Supervisor from whichever lifecycle flags
+// the command defines.
+func newSupervisor(cmd *cobra.Command) (*server.Supervisor, error) {
+	var opts server.Options
 
-	existingPID, err := pidfile.GetRunningPID(pidPath)
-	if err != nil {
-		return err
+	if f := cmd.Flags().Lookup("port"); f != nil {
+		opts.Port, _ = cmd.Flags().GetInt("port")
 	}
-
-	if existingPID != 0 {
-		return fmt.Errorf("server is already running (PID: %d)", existingPID)
+	if f := cmd.Flags().Lookup("token"); f != nil {
+		opts.Token, _ = cmd.Flags().GetString("token")
 	}
-
-	// Find Node.js
-	nodePath, err := server.FindNodeJS()
-	if err != nil {
-		return fmt.Errorf("Node.js not found: %w", err)
+	if f := cmd.Flags().Lookup("log-file"); f != nil {
+		opts.LogPath, _ = cmd.Flags().GetString("log-file")
 	}
-
-	// Find server script
-	serverPath, err := server.FindNappTrappServer()
-	if err != nil {
-		return fmt.Errorf("server script not found: %w", err)
+	if f := cmd.Flags().Lookup("timeout"); f != nil {
+		opts.StopTimeout, _ = cmd.Flags().GetDuration("timeout")
 	}
-
-	// Check dependencies
-	if err := server.CheckServerDependencies(serverPath); err != nil {
-		return err
+	if f := cmd.Flags().Lookup("force"); f != nil {
+		opts.Escalate, _ = cmd.Flags().GetBool("force")
 	}
 
-	// Prepare environment and log files
-	env := os.Environ()
-	env = append(env, fmt.Sprintf("PORT=%d", port))
-	env = append(env, fmt.Sprintf("NAPPTRAPP_DATA_DIR=%s", dataDir))
-
-	// Prepare log files
-	logsPath := config.GetLogsPath(dataDir)
-	os.MkdirAll(logsPath, 0755)
-	logFile := filepath.Join(logsPath, "server.log")
-
-	// Start server
-	cmd := exec.Command(nodePath, serverPath)
-	cmd.Env = env
-
-	if detach {
-		// Redirect output to log file
-		outFile, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to open log file: %w", err)
-		}
-		defer outFile.Close()
+	return server.NewSupervisor(opts)
+}
 
-		cmd.Stdout = outFile
-		cmd.Stderr = outFile
+// stopServer stops the server through the supervisor and reports the outcome.
+func stopServer(sup *server.Supervisor) error {
+	killed, err := sup.Stop()
+	if err != nil {
+		return err
+	}
 
-		// Start in background
-		if err := cmd.Start(); err != nil {
-			return fmt.Errorf("failed to start server: %w", err)
-		}
+	if killed {
+		color.Yellow("⚠ Server did not stop gracefully, killed")
+	} else {
+		color.Green("✓ Server stopped")
+	}
+	return nil
+}
 
-		// Write PID file
-		if err := pidfile.Write(pidPath, cmd.Process.Pid, port, dataDir); err != nil {
-			return fmt.Errorf("failed to write PID file: %w", err)
-		}
+// waitIfRequested blocks until the server is healthy when --wait is set.
+// If the server dies or never becomes healthy, the tail of its log is
+// printed and nappctl exits non-zero.
+func waitIfRequested(cmd *cobra.Command, sup *server.Supervisor, info *pidfile.PIDInfo) {
+	wait, _ := cmd.Flags().GetBool("wait")
+	if !wait {
+		return
+	}
+	timeout, _ := cmd.Flags().GetDuration("wait-timeout")
 
-		color.Green("✓ Server started (PID: %d)", cmd.Process.Pid)
-		fmt.Printf("Port: %d\n", port)
-		fmt.Printf("Logs: %s\n", logFile)
-	} else {
-		// Run in foreground
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	fmt.Printf("Waiting for server on port %d to become healthy...\n", info.Port)
+	if err := sup.WaitReady(info.PID, timeout); err != nil {
+		color.Red("Error: %v", err)
 
-		color.Green("Starting server on port %d...", port)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("server exited with error: %w", err)
+		src := logs.Source{File: sup.LogPath()}
+		if entries, tailErr := logs.Tail(src, 20, &logs.Filter{}); tailErr == nil && len(entries) > 0 {
+			fmt.Printf("\nLast %d log lines (%s):\n", len(entries), sup.LogPath())
+			for _, entry := range entries {
+				fmt.Println("  " + entry.Raw)
+			}
 		}
+		os.Exit(1)
 	}
-
-	return nil
+	color.Green("✓ Server is healthy")
 }
 
-func stopServer() error {
-	dataDir, err := config.ResolveDataDir()
-	if err != nil {
-		return err
+// printStatus renders a server.Status as human-readable text.
+func printStatus(st *server.Status) {
+	if st.State == server.StateStopped {
+		color.Yellow("Server is not running")
+		return
 	}
-	pidPath := config.GetPidPath(dataDir)
 
-	pid, err := pidfile.GetRunningPID(pidPath)
-	if err != nil {
-		return err
+	if st.State == server.StateHealthy {
+		color.Green("Server is running and healthy (PID: %d)", st.PID)
+	} else {
+		color.Yellow("Server is running but not healthy (PID: %d)", st.PID)
 	}
 
-	if pid == 0 {
-		return fmt.Errorf("server is not running")
+	if st.ManagedBy != "" {
+		fmt.Printf("Managed by: %s\n", st.ManagedBy)
 	}
-
-	// Find process
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return fmt.Errorf("failed to find process: %w", err)
+	fmt.Printf("URL: %s\n", st.URL)
+	fmt.Printf("Port: %d\n", st.Port)
+	fmt.Printf("Data directory: %s\n", st.DataDir)
+	if st.StartedAt != nil {
+		fmt.Printf("Uptime: %s (since %s)\n", st.Uptime(), st.StartedAt.Format(time.RFC3339))
 	}
 
-	// Send SIGTERM
-	if err := process.Signal(os.Interrupt); err != nil {
-		return fmt.Errorf("failed to stop server: %w", err)
+	if st.Health != nil {
+		if st.Health.Reachable {
+			fmt.Printf("Health: %s (%.1f ms)\n", color.GreenString("ok"), st.Health.LatencyMs)
+		} else {
+			fmt.Printf("Health: %s (%s)\n", color.RedString("unreachable"), st.Health.Error)
+		}
+	}
+	if st.Discover != nil {
+		fmt.Printf("Hostname: %s\n", st.Discover.Hostname)
+		fmt.Printf("Version: %s\n", st.Discover.Version)
+	}
+	if st.Process != nil {
+		fmt.Printf("Memory: %s (%d threads)\n", formatBytes(st.Process.RSSBytes), st.Process.Threads)
+		fmt.Printf("CPU: %.1f%% (average)\n", st.Process.CPUPercent)
 	}
 
-	// Wait for process to exit (with timeout)
-	timeout := time.After(5 * time.Second)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-timeout:
-			// Force kill if still running
-			process.Kill()
-			pidfile.Remove(pidPath)
-			return fmt.Errorf("server did not stop gracefully, killed")
-		case <-ticker.C:
-			if !pidfile.IsProcessRunning(pid) {
-				pidfile.Remove(pidPath)
-				color.Green("✓ Server stopped")
-				return nil
-			}
+	if st.SupervisorPID != 0 {
+		fmt.Printf("Supervisor PID: %d\n", st.SupervisorPID)
+		fmt.Printf("Restarts: %d\n", st.Restarts)
+		if st.LastExitAt != nil {
+			fmt.Printf("Last exit: code %d at %s\n", st.LastExitCode, st.LastExitAt.Format(time.RFC3339))
 		}
 	}
 }
+
+// printStarted reports a successful background start.
+func printStarted(sup *server.Supervisor, info *pidfile.PIDInfo) {
+	color.Green("✓ Server started (PID: %d)", info.PID)
+	fmt.Printf("Port: %d\n", info.Port)
+	fmt.Printf("Logs: %s\n", sup.LogPath())
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/auth"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/server"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/service"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run the server as a login service",
+	Long: `Install the Napp Trapp server as a systemd user service so it starts at login
+without keeping a terminal open.
+
+While the service is installed, 'nappctl server start|stop|restart|status'
+defer to systemd instead of managing server.pid themselves.`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install and enable the systemd user service",
+	Long:  "Render a systemd user unit for the server, install it, enable it at login and start it.",
+	Run: func(cmd *cobra.Command, args []string) {
+		port, _ := cmd.Flags().GetInt("port")
+		noStart, _ := cmd.Flags().GetBool("no-start")
+
+		if runtime.GOOS != "linux" || !service.SystemdAvailable() {
+			color.Red("Error: systemd user services are not available on this system")
+			if runtime.GOOS == "darwin" {
+				fmt.Println("Render a launchd agent instead with: nappctl service render --launchd")
+			}
+			os.Exit(1)
+		}
+
+		sup, err := server.NewSupervisor(server.Options{})
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if _, running, err := sup.Status(); err == nil && running {
+			color.Red("Error: server is already running outside of systemd")
+			fmt.Println("Stop it first with: nappctl server stop")
+			os.Exit(1)
+		}
+
+		cfg, err := service.ResolveUnitConfig(port)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if err := server.CheckServerDependencies(cfg.ScriptPath); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		path, err := service.Install(cfg, !noStart)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Service installed")
+		fmt.Printf("Unit: %s\n", path)
+		fmt.Printf("Port: %d\n", cfg.Port)
+		fmt.Printf("Logs: %s\n", cfg.LogPath)
+		if backend, err := auth.CurrentBackend(config.GetAuthPath(cfg.DataDir)); err == nil && backend != auth.BackendFile {
+			color.Yellow("⚠ The master token is kept in the %s secret store, which the service cannot read:", backend)
+			color.Yellow("  it will use a temporary token. Use the file store for the service: nappctl auth store migrate file")
+		}
+		if noStart {
+			fmt.Println("Start it with: nappctl server start")
+		}
+		fmt.Println("\nTo keep the service running while you are logged out, run:")
+		fmt.Println("  loginctl enable-linger")
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop, disable and remove the systemd user service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := service.Uninstall(); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Service uninstalled")
+	},
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the systemd user service state",
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		status, err := service.Status()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			data, err := json.MarshalIndent(status, "", "  ")
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		} else {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Setting", "Value"})
+			table.SetBorder(false)
+			table.SetColumnSeparator("")
+
+			table.Append([]string{"Unit", service.UnitName})
+			table.Append([]string{"Unit File", status.UnitPath})
+			if !status.Installed {
+				table.Append([]string{"Installed", color.YellowString("No")})
+			} else {
+				table.Append([]string{"Installed", color.GreenString("Yes")})
+				table.Append([]string{"Enabled", fmt.Sprintf("%t", status.Enabled)})
+				table.Append([]string{"State", fmt.Sprintf("%s (%s)", status.ActiveState, status.SubState)})
+				if status.MainPID != 0 {
+					table.Append([]string{"PID", fmt.Sprintf("%d", status.MainPID)})
+				}
+			}
+
+			table.Render()
+		}
+
+		if !status.Installed {
+			os.Exit(3)
+		}
+	},
+}
+
+var serviceRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the service definition without installing it",
+	Long: `Render the systemd user unit (or, with --launchd, a macOS launchd agent plist)
+for the server and print it or write it to a file.
+
+The launchd plist is a text artifact only: copy it to ~/Library/LaunchAgents
+and load it with launchctl yourself.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		port, _ := cmd.Flags().GetInt("port")
+		launchd, _ := cmd.Flags().GetBool("launchd")
+		outputPath, _ := cmd.Flags().GetString("output")
+
+		cfg, err := service.ResolveUnitConfig(port)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		var content string
+		if launchd {
+			content, err = service.RenderLaunchdPlist(cfg)
+		} else {
+			content, err = service.RenderSystemdUnit(cfg)
+		}
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if outputPath == "" {
+			fmt.Print(content)
+			return
+		}
+
+		if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
+			color.Red("Error writing %s: %v", outputPath, err)
+			os.Exit(1)
+		}
+		color.Green("✓ Written to %s", outputPath)
+	},
+}
+
+func init() {
+	serviceInstallCmd.Flags().IntP("port", "p", server.DefaultPort, "Server port")
+	serviceInstallCmd.Flags().Bool("no-start", false, "Enable the service without starting it now")
+
+	serviceStatusCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+
+	serviceRenderCmd.Flags().IntP("port", "p", server.DefaultPort, "Server port")
+	serviceRenderCmd.Flags().Bool("launchd", false, "Render a macOS launchd plist instead of a systemd unit")
+	serviceRenderCmd.Flags().StringP("output", "o", "", "Write to file instead of stdout")
+
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+	serviceCmd.AddCommand(serviceStatusCmd)
+	serviceCmd.AddCommand(serviceRenderCmd)
+}
+
+// serviceManaged reports whether the server is managed by the systemd user
+// unit, in which case the server subcommands defer to systemctl.
+func serviceManaged() bool {
+	return runtime.GOOS == "linux" && service.IsInstalled()
+}
+
+// runServiceAction starts or restarts the systemd unit, then reports the
+// result and honours --wait like a directly supervised start.
+func runServiceAction(cmd *cobra.Command, verb string, action func() error) {
+	sup, err := newSupervisor(cmd)
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+	if verb == "start" {
+		if _, running, err := sup.Status(); err == nil && running {
+			color.Red("Error: server is already running outside of systemd")
+			fmt.Println("Stop it first with: nappctl server stop")
+			os.Exit(1)
+		}
+	}
+
+	if err := action(); err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+
+	info, err := serviceInfo()
+	if err != nil || info == nil {
+		color.Red("Error: %s did not start", service.UnitName)
+		fmt.Printf("Check: systemctl --user status %s\n", service.UnitName)
+		os.Exit(1)
+	}
+
+	// Probe the port the unit was installed with, not the --port flag
+	sup, err = server.NewSupervisor(server.Options{Port: info.Port})
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+
+	color.Green("✓ Server %sed via %s (PID: %d)", verb, service.UnitName, info.PID)
+	fmt.Printf("Port: %d\n", info.Port)
+	fmt.Printf("Logs: %s\n", sup.LogPath())
+	waitIfRequested(cmd, sup, info)
+}
+
+// serviceInfo describes the running systemd unit as PID file information.
+// It returns nil when the unit is not active.
+func serviceInfo() (*pidfile.PIDInfo, error) {
+	status, err := service.Status()
+	if err != nil {
+		return nil, err
+	}
+	if !status.Active() || status.MainPID == 0 {
+		return nil, nil
+	}
+
+	port, err := service.UnitPort()
+	if err != nil {
+		port = server.DefaultPort
+	}
+
+	info := &pidfile.PIDInfo{PID: status.MainPID, Port: port}
+	if startedAt, err := server.ProcessStartTime(status.MainPID); err == nil {
+		info.StartedAt = startedAt
+	}
+	return info, nil
+}
+
+// inspectService returns the detailed status of the server when it is
+// managed by an active systemd unit, or nil when it is not.
+func inspectService(sup *server.Supervisor) (*server.Status, error) {
+	if !serviceManaged() {
+		return nil, nil
+	}
+
+	info, err := serviceInfo()
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, nil
+	}
+
+	st := sup.InspectInfo(info, true)
+	st.ManagedBy = service.UnitName
+	return st, nil
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// defaultDetachKeys matches tmux's own detach binding.
+const defaultDetachKeys = "ctrl-b,d"
+
+var (
+	errDetached       = errors.New("detached")
+	errTerminalClosed = errors.New("terminal closed")
+)
+
+var termCmd = &cobra.Command{
+	Use:     "term",
+	Aliases: []string{"terminal"},
+	Short:   "Use terminals on the server",
+	Long: `Work with the server's terminals: PTYs (ids like pty-1) and tmux windows
+(ids like tmux-napp-myproject:0), including the ones created from the
+mobile app.`,
+}
+
+var termListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List terminals",
+	Long: `List the server's PTY terminals and, with --project, the project's tmux
+windows.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		ref, _ := cmd.Flags().GetString("project")
+		history, _ := cmd.Flags().GetBool("all")
+		source, _ := cmd.Flags().GetString("source")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		opts := api.TerminalListOptions{IncludeHistory: history}
+		switch source {
+		case "", "all":
+		case "pty":
+			opts.Source = api.TerminalSourcePTY
+		case "tmux":
+			opts.Source = api.TerminalSourceTmux
+		default:
+			color.Red("Error: unknown source %q (use pty or tmux)", source)
+			os.Exit(1)
+		}
+		if ref != "" {
+			opts.ProjectPath = mustResolveProject(ctx, client, ref).Path
+		}
+
+		terminals, err := client.ListTerminals(ctx, opts)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(terminals)
+			return
+		}
+
+		if len(terminals) == 0 {
+			color.Yellow("No terminals")
+		} else {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"ID", "Type", "Name", "Directory", "Status", "Created"})
+			table.SetBorder(false)
+			table.SetColumnSeparator("")
+
+			for _, t := range terminals {
+				kind, name := "pty", t.Name
+				if t.IsTmux() {
+					kind = "tmux"
+					if t.WindowName != "" {
+						name = t.WindowName
+					}
+				}
+				table.Append([]string{t.ID, kind, name, t.Cwd, terminalStatus(&t), formatTime(t.CreatedAt.Time())})
+			}
+
+			table.Render()
+		}
+
+		if ref == "" && opts.Source != api.TerminalSourcePTY {
+			fmt.Println()
+			fmt.Println("Use --project to include the project's tmux terminals")
+		}
+	},
+}
+
+var termNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Create a terminal",
+	Long: `Create a terminal on the server. With --project a tmux window is created
+in the project's session, otherwise a PTY. --shell applies to PTYs only;
+tmux windows use the session's default shell.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := outputFormat(cmd)
+		ref, _ := cmd.Flags().GetString("project")
+		pty, _ := cmd.Flags().GetBool("pty")
+		shell, _ := cmd.Flags().GetString("shell")
+		cwd, _ := cmd.Flags().GetString("cwd")
+		name, _ := cmd.Flags().GetString("name")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		req := api.CreateTerminalRequest{Type: "pty", Cwd: cwd, Shell: shell}
+		if ref != "" {
+			req.ProjectPath = mustResolveProject(ctx, client, ref).Path
+			if req.Cwd == "" {
+				req.Cwd = req.ProjectPath
+			}
+			if !pty {
+				req.Type = "tmux"
+				req.WindowName = name
+			}
+		}
+		if req.Type == "tmux" && shell != "" {
+			color.Yellow("Warning: --shell is ignored for tmux terminals")
+		}
+		if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil && req.Type == "pty" {
+			req.Cols, req.Rows = cols, rows
+		}
+
+		terminal, err := client.CreateTerminal(ctx, req)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if output == "json" {
+			printJSON(terminal)
+			return
+		}
+
+		color.Green("✓ Terminal created: %s", terminal.ID)
+		attach := "nappctl term attach " + terminal.ID
+		if terminal.IsTmux() {
+			attach += " --project " + ref
+		}
+		fmt.Printf("Attach with: %s\n", attach)
+	},
+}
+
+var termKillCmd = &cobra.Command{
+	Use:   "kill <terminal-id>...",
+	Short: "Kill terminals",
+	Long:  "Kill PTY terminals or tmux windows. A session's last window takes the session with it.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		if !force && !confirm(fmt.Sprintf("Kill %s?", strings.Join(args, ", "))) {
+			color.Yellow("Cancelled")
+			os.Exit(0)
+		}
+
+		failed := false
+		for _, id := range args {
+			if err := client.KillTerminal(ctx, id); err != nil {
+				color.Red("Error: %s: %v", id, err)
+				failed = true
+				continue
+			}
+			color.Green("✓ Killed %s", id)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var termScrollbackCmd = &cobra.Command{
+	Use:   "scrollback <terminal-id>",
+	Short: "Print a terminal's scrollback",
+	Long: `Print the history of a tmux window (the last --lines lines) or the
+buffered output of a PTY, to stdout or to --file.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		lines, _ := cmd.Flags().GetInt("lines")
+		file, _ := cmd.Flags().GetString("file")
+		client := mustAPIClient(cmd)
+
+		content, err := client.GetTerminalScrollback(context.Background(), args[0], lines)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if file == "" {
+			fmt.Print(content)
+			return
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Saved scrollback to %s (%s)", file, formatBytes(int64(len(content))))
+	},
+}
+
+var termAttachCmd = &cobra.Command{
+	Use:   "attach <terminal-id>",
+	Short: "Attach to a terminal interactively",
+	Long: `Attach the local terminal to a terminal on the server over the WebSocket
+API. Input and output are streamed and window size changes are forwarded.
+
+Press the detach keys (default ctrl-b then d, like tmux) to detach; the
+terminal keeps running on the server. tmux windows need --project.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ref, _ := cmd.Flags().GetString("project")
+		detachSpec, _ := cmd.Flags().GetString("detach-keys")
+		id := args[0]
+
+		detachKeys, err := parseDetachKeys(detachSpec)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+			color.Red("Error: attach needs an interactive terminal")
+			os.Exit(1)
+		}
+		if strings.HasPrefix(id, "tmux-") && ref == "" {
+			color.Red("Error: --project is required for tmux terminals")
+			os.Exit(1)
+		}
+
+		client := mustAPIClient(cmd)
+		ctx := context.Background()
+
+		var projectPath string
+		if ref != "" {
+			projectPath = mustResolveProject(ctx, client, ref).Path
+		}
+
+		socket, err := client.Connect(ctx)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		defer socket.Close()
+
+		if err := waitAttached(socket, id, projectPath); err != nil {
+			color.Red("Error: %v", err)
+			socket.Close()
+			os.Exit(1)
+		}
+
+		err = runAttached(socket, id, detachKeys)
+		switch {
+		case errors.Is(err, errDetached):
+			color.Yellow("[detached from %s]", id)
+		case errors.Is(err, errTerminalClosed):
+			color.Yellow("[terminal %s closed]", id)
+		default:
+			color.Red("Error: %v", err)
+			socket.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	addRemoteFlags(termCmd)
+	termCmd.PersistentFlags().StringP("project", "p", "", "Project of tmux terminals (ID, path or name)")
+
+	addOutputFlag(termListCmd)
+	termListCmd.Flags().BoolP("all", "a", false, "Include exited PTY terminals")
+	termListCmd.Flags().String("source", "", "Only pty or tmux terminals")
+
+	addOutputFlag(termNewCmd)
+	termNewCmd.Flags().Bool("pty", false, "Create a PTY even with --project")
+	termNewCmd.Flags().String("shell", "", "Shell for a PTY (default: the server user's shell)")
+	termNewCmd.Flags().String("cwd", "", "Working directory (default: the project, or the server user's home)")
+	termNewCmd.Flags().String("name", "", "tmux window name")
+
+	termKillCmd.Flags().BoolP("force", "f", false, "Skip confirmation")
+
+	termScrollbackCmd.Flags().IntP("lines", "n", 2000, "Lines of tmux history")
+	termScrollbackCmd.Flags().String("file", "", "Write to this file instead of stdout")
+
+	termAttachCmd.Flags().String("detach-keys", defaultDetachKeys, "Key sequence to detach, e.g. ctrl-b,d or ctrl-]")
+
+	termCmd.AddCommand(termListCmd)
+	termCmd.AddCommand(termNewCmd)
+	termCmd.AddCommand(termKillCmd)
+	termCmd.AddCommand(termScrollbackCmd)
+	termCmd.AddCommand(termAttachCmd)
+}
+
+// terminalStatus describes whether a terminal is running.
+func terminalStatus(t *api.Terminal) string {
+	switch {
+	case t.Active && t.IsTmux() && t.Attached:
+		return color.GreenString("attached")
+	case t.Active:
+		return color.GreenString("running")
+	case t.ExitCode != nil:
+		return color.HiBlackString("exited (%d)", *t.ExitCode)
+	default:
+		return color.HiBlackString("exited")
+	}
+}
+
+// waitAttached attaches the socket to a terminal and waits for the server
+// to confirm.
+func waitAttached(socket *api.Socket, id, projectPath string) error {
+	if err := socket.TerminalAttach(id, projectPath); err != nil {
+		return err
+	}
+	for {
+		msg, err := socket.Receive()
+		if err != nil {
+			return err
+		}
+		switch msg.Type {
+		case api.MsgTerminalAttached:
+			return nil
+		case api.MsgTerminalError, api.MsgError:
+			var body api.TerminalMessage
+			msg.Decode(&body)
+			return fmt.Errorf("failed to attach to %s: %s", id, body.Message)
+		}
+	}
+}
+
+// runAttached streams between the local terminal, in raw mode, and an
+// attached terminal until the user detaches, the terminal closes or the
+// connection fails.
+func runAttached(socket *api.Socket, id string, detachKeys []byte) error {
+	stdin := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return fmt.Errorf("failed to put the terminal in raw mode: %w", err)
+	}
+	defer func() {
+		term.Restore(stdin, state)
+		fmt.Println()
+	}()
+
+	resize := func() {
+		// The server rejects zero sizes, which some ptys report
+		if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil && cols > 0 && rows > 0 {
+			socket.TerminalResize(id, cols, rows)
+		}
+	}
+	resize()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	done := make(chan error, 2)
+
+	go func() {
+		matcher := &detachMatcher{keys: detachKeys}
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				done <- err
+				return
+			}
+			input, detach := matcher.feed(buf[:n])
+			if len(input) > 0 {
+				if err := socket.TerminalInput(id, string(input)); err != nil {
+					done <- err
+					return
+				}
+			}
+			if detach {
+				done <- errDetached
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			msg, err := socket.Receive()
+			if err != nil {
+				done <- err
+				return
+			}
+
+			var body api.TerminalMessage
+			switch msg.Type {
+			case api.MsgTerminalData:
+				if msg.Decode(&body) == nil && body.TerminalID == id {
+					os.Stdout.WriteString(body.Data)
+				}
+			case api.MsgTerminalClosed, api.MsgTerminalKilled:
+				if msg.Decode(&body) == nil && body.TerminalID == id {
+					done <- errTerminalClosed
+					return
+				}
+			case api.MsgTerminalError, api.MsgError:
+				msg.Decode(&body)
+				done <- errors.New(body.Message)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGWINCH {
+				resize()
+				continue
+			}
+			err = errDetached
+		case err = <-done:
+		}
+		if errors.Is(err, errDetached) {
+			socket.TerminalDetach(id)
+		}
+		return err
+	}
+}
+
+// parseDetachKeys parses a comma-separated key sequence such as
+// "ctrl-b,d" into the bytes the keys send.
+func parseDetachKeys(spec string) ([]byte, error) {
+	var keys []byte
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		lower := strings.ToLower(key)
+
+		switch {
+		case strings.HasPrefix(lower, "ctrl-") && len(key) == 6:
+			c := lower[5]
+			switch {
+			case c >= 'a' && c <= 'z':
+				keys = append(keys, c-'a'+1)
+			case c >= '@' && c <= '_':
+				keys = append(keys, c-'@')
+			default:
+				return nil, fmt.Errorf("invalid detach key %q", key)
+			}
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		default:
+			return nil, fmt.Errorf("invalid detach key %q (use e.g. ctrl-b,d)", key)
+		}
+	}
+	return keys, nil
+}
+
+// detachMatcher spots the detach key sequence in terminal input, holding
+// back a partial match until it is known not to be one.
+type detachMatcher struct {
+	keys    []byte
+	matched int
+}
+
+// feed returns the input to forward and whether the sequence completed.
+func (m *detachMatcher) feed(input []byte) ([]byte, bool) {
+	var out []byte
+	for _, b := range input {
+		if b != m.keys[m.matched] {
+			out = append(out, m.keys[:m.matched]...)
+			m.matched = 0
+			if b != m.keys[0] {
+				out = append(out, b)
+				continue
+			}
+		}
+		m.matched++
+		if m.matched == len(m.keys) {
+			return out, true
+		}
+	}
+	return out, false
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net/url"
+)
+
+// AuthReloadResult is the response of ReloadAuth.
+type AuthReloadResult struct {
+	Success bool `json:"success"`
+	// Changed reports whether the master token differed from the one the
+	// server was using.
+	Changed      bool `json:"changed"`
+	SessionCount int  `json:"sessionCount"`
+}
+
+// ReloadAuth makes the server re-read auth.json, so a rotated master token
+// takes effect without a restart. The client must authenticate with the
+// master token the server was using before the reload. masterToken, when
+// non-empty, is the new master token for a server whose auth.json names a
+// secret store it cannot read; it is ignored otherwise.
+func (c *Client) ReloadAuth(ctx context.Context, masterToken string) (*AuthReloadResult, error) {
+	var body interface{}
+	if masterToken != "" {
+		body = map[string]string{"masterToken": masterToken}
+	}
+	var result AuthReloadResult
+	if err := c.post(ctx, "/api/auth/reload", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Session is a paired device's session, as listed by ListSessions. The
+// session token itself is never returned; ID identifies it.
+type Session struct {
+	ID           string `json:"id"`
+	Device       string `json:"device,omitempty"`
+	UserAgent    string `json:"userAgent,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	CreatedAt    Millis `json:"createdAt"`
+	LastActivity Millis `json:"lastActivity"`
+}
+
+// ListSessions returns the server's sessions.
+func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
+	var resp struct {
+		Sessions []Session `json:"sessions"`
+	}
+	if err := c.get(ctx, "/api/auth/sessions", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+// RevokeSession revokes the session with the given ID.
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	return c.delete(ctx, "/api/auth/sessions/"+url.PathEscape(id), nil, nil)
+}
+
+// RevokeAllSessions revokes every session and returns how many there were.
+func (c *Client) RevokeAllSessions(ctx context.Context) (int, error) {
+	var resp struct {
+		Revoked int `json:"revoked"`
+	}
+	if err := c.delete(ctx, "/api/auth/sessions", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Revoked, nil
+}
+
+// Pairing states reported by GetPairing.
+const (
+	PairingPending = "pending"
+	PairingPaired  = "paired"
+	// PairingExpired also covers cancelled and unknown pairings.
+	PairingExpired = "expired"
+)
+
+// Pairing is a one-time pairing code minted by CreatePairing. A device
+// redeems the code for a session of its own.
+type Pairing struct {
+	Code string `json:"code"`
+	// ID is the ID the session will have once the code is redeemed.
+	ID        string `json:"id"`
+	ExpiresAt Millis `json:"expiresAt"`
+}
+
+// PairingStatus is the state of a pairing, as returned by GetPairing.
+type PairingStatus struct {
+	Status    string `json:"status"`
+	ExpiresAt Millis `json:"expiresAt,omitempty"`
+	// Device is the name the device gave when it paired.
+	Device string `json:"device,omitempty"`
+}
+
+// CreatePairing mints a pairing code. It requires the master token.
+func (c *Client) CreatePairing(ctx context.Context) (*Pairing, error) {
+	var pairing Pairing
+	if err := c.post(ctx, "/api/auth/pairings", nil, nil, &pairing); err != nil {
+		return nil, err
+	}
+	return &pairing, nil
+}
+
+// GetPairing returns the state of the pairing with the given ID.
+func (c *Client) GetPairing(ctx context.Context, id string) (*PairingStatus, error) {
+	var status PairingStatus
+	if err := c.get(ctx, "/api/auth/pairings/"+url.PathEscape(id), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// CancelPairing voids the pending pairing code with the given ID.
+func (c *Client) CancelPairing(ctx context.Context, id string) error {
+	return c.delete(ctx, "/api/auth/pairings/"+url.PathEscape(id), nil, nil)
+}
@@ -1,66 +1,209 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// DefaultTimeout bounds a request whose context has no deadline.
+const DefaultTimeout = 10 * time.Second
+
 // Client represents an API client for the Napp Trapp server
 type Client struct {
 	baseURL string
 	token   string
+	timeout time.Duration
 	client  *http.Client
 }
 
 // NewClient creates a new API client
 func NewClient(baseURL, token string) *Client {
 	return &Client{
-		baseURL: baseURL,
+		baseURL: strings.TrimRight(baseURL, "/"),
 		token:   token,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		timeout: DefaultTimeout,
+		client:  &http.Client{},
 	}
 }
 
+// BaseURL returns the server URL the client talks to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Token returns the token sent as the Bearer credential.
+func (c *Client) Token() string {
+	return c.token
+}
+
+// SetTimeout sets the timeout applied to requests whose context has no
+// deadline. Zero disables it.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// SetHTTPClient replaces the underlying HTTP client, e.g. to customise the
+// transport.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.client = hc
+}
+
+// HealthInfo is the response of the unauthenticated /health endpoint.
+type HealthInfo struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // HealthCheck checks if the server is responding
-func (c *Client) HealthCheck() error {
-	resp, err := c.client.Get(c.baseURL + "/health")
-	if err != nil {
-		return fmt.Errorf("health check failed: %w", err)
-	}
-	defer resp.Body.Close()
+func (c *Client) HealthCheck(ctx context.Context) error {
+	_, err := c.Health(ctx)
+	return err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("health check returned %d: %s", resp.StatusCode, string(body))
+// Health queries the server's /health endpoint
+func (c *Client) Health(ctx context.Context) (*HealthInfo, error) {
+	var info HealthInfo
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/health", noAuth: true}, &info); err != nil {
+		return nil, err
 	}
+	return &info, nil
+}
 
-	return nil
+// DiscoverInfo is the response of the unauthenticated /discover endpoint.
+type DiscoverInfo struct {
+	Service  string `json:"service"`
+	Version  string `json:"version"`
+	Hostname string `json:"hostname"`
+	Port     int    `json:"port"`
 }
 
-// GetSystemInfo retrieves system information from the server
-func (c *Client) GetSystemInfo() (map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", c.baseURL+"/api/system/info", nil)
-	if err != nil {
+// Discover queries the server's /discover endpoint
+func (c *Client) Discover(ctx context.Context) (*DiscoverInfo, error) {
+	var info DiscoverInfo
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/discover", noAuth: true}, &info); err != nil {
 		return nil, err
 	}
+	return &info, nil
+}
+
+// request describes a single API call.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	// body is encoded as JSON when set.
+	body interface{}
+	// raw is sent as-is with contentType, taking precedence over body.
+	raw         io.Reader
+	contentType string
+	// timeout overrides the client timeout when the context has no deadline.
+	timeout time.Duration
+	noAuth  bool
+}
+
+// do performs a request and decodes the JSON response into out (which may
+// be nil). Non-2xx responses are returned as *Error.
+func (c *Client) do(ctx context.Context, r *request, out interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.timeout
+		if r.timeout != 0 {
+			timeout = r.timeout
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	u := c.baseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+
+	body, contentType := r.raw, r.contentType
+	if body == nil && r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s request: %w", r.path, err)
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if !r.noAuth {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s %s failed: %w", r.method, r.path, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return newError(r.method, r.path, resp.StatusCode, data)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", r.path, err)
 	}
+	return nil
+}
+
+// get performs an authenticated GET.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return c.do(ctx, &request{method: http.MethodGet, path: path, query: query}, out)
+}
+
+// post performs an authenticated POST with a JSON body.
+func (c *Client) post(ctx context.Context, path string, query url.Values, body, out interface{}) error {
+	if body == nil {
+		body = struct{}{}
+	}
+	return c.do(ctx, &request{method: http.MethodPost, path: path, query: query, body: body}, out)
+}
+
+// delete performs an authenticated DELETE.
+func (c *Client) delete(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: path, query: query}, out)
+}
+
+// Millis is a Unix timestamp in milliseconds, as the server reports
+// Date.now() values.
+type Millis int64
+
+// Time converts the timestamp to a time.Time. Zero stays the zero time.
+func (m Millis) Time() time.Time {
+	if m == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(m))
+}
 
-	// Parse JSON response
-	// This is a placeholder - full implementation would unmarshal JSON
-	return make(map[string]interface{}), nil
+// Result is the common {success, message} response of mutating endpoints.
+type Result struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
 }
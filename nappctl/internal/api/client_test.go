@@ -0,0 +1,385 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a test server running handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL+"/", "test-token")
+}
+
+// respond returns a handler that answers status with body.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		io.WriteString(w, `{}`)
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		call        func() error
+		method      string
+		auth        string
+		contentType string
+		body        string
+	}{
+		{
+			name:   "authenticated GET",
+			call:   func() error { _, err := c.GetSystemInfo(ctx); return err },
+			method: http.MethodGet,
+			auth:   "Bearer test-token",
+		},
+		{
+			name:        "POST with a JSON body",
+			call:        func() error { return c.CreateFile(ctx, "/tmp/a.txt", "hi") },
+			method:      http.MethodPost,
+			auth:        "Bearer test-token",
+			contentType: "application/json",
+			body:        `{"content":"hi","filePath":"/tmp/a.txt"}`,
+		},
+		{
+			name:        "POST without a body",
+			call:        func() error { _, err := c.CreatePairing(ctx); return err },
+			method:      http.MethodPost,
+			auth:        "Bearer test-token",
+			contentType: "application/json",
+			body:        `{}`,
+		},
+		{
+			name:   "unauthenticated health check",
+			call:   func() error { return c.HealthCheck(ctx) },
+			method: http.MethodGet,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != nil {
+				t.Fatal(err)
+			}
+			if got.Method != tt.method {
+				t.Errorf("method = %s, want %s", got.Method, tt.method)
+			}
+			if auth := got.Header.Get("Authorization"); auth != tt.auth {
+				t.Errorf("Authorization = %q, want %q", auth, tt.auth)
+			}
+			if accept := got.Header.Get("Accept"); accept != "application/json" {
+				t.Errorf("Accept = %q, want application/json", accept)
+			}
+			if ct := got.Header.Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %s, want %s", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestClientDecodesResources(t *testing.T) {
+	ctx := context.Background()
+	lastOpened := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	exitCode := 2
+
+	tests := []struct {
+		name string
+		// path and query the client must request
+		path  string
+		query string
+		body  string
+		call  func(c *Client) (interface{}, error)
+		want  interface{}
+	}{
+		{
+			name: "projects",
+			path: "/api/projects",
+			body: `{"projects": [{"id": "p1", "name": "app", "path": "/src/app", "lastOpened": "2024-05-06T07:08:09Z"}]}`,
+			call: func(c *Client) (interface{}, error) { return c.ListProjects(ctx) },
+			want: []Project{{ID: "p1", Name: "app", Path: "/src/app", LastOpened: lastOpened}},
+		},
+		{
+			name: "project",
+			path: "/api/projects/p%201",
+			body: `{"project": {"id": "p 1", "name": "app", "path": "/src/app"}}`,
+			call: func(c *Client) (interface{}, error) { return c.GetProject(ctx, "p 1") },
+			want: &Project{ID: "p 1", Name: "app", Path: "/src/app"},
+		},
+		{
+			name:  "file",
+			path:  "/api/files/read",
+			query: "filePath=%2Fsrc%2Fmain.go",
+			body:  `{"path": "/src/main.go", "content": "package main", "size": 12, "extension": ".go", "isBinary": false, "mimeType": "text/x-go"}`,
+			call:  func(c *Client) (interface{}, error) { return c.ReadFile(ctx, "/src/main.go") },
+			want:  &FileContent{Path: "/src/main.go", Content: "package main", Size: 12, Extension: ".go", MimeType: "text/x-go"},
+		},
+		{
+			name:  "directory",
+			path:  "/api/files/list",
+			query: "dirPath=~",
+			body:  `{"items": [{"name": "src", "path": "/home/u/src", "isDirectory": true}], "resolvedPath": "/home/u"}`,
+			call:  func(c *Client) (interface{}, error) { return c.ListDir(ctx, "~") },
+			want:  &DirListing{Items: []FileInfo{{Name: "src", Path: "/home/u/src", IsDirectory: true}}, ResolvedPath: "/home/u"},
+		},
+		{
+			name:  "git status",
+			path:  "/api/git/p1/status",
+			query: "repoPath=lib",
+			body:  `{"branch": "main", "ahead": 1, "staged": [{"path": "a.go", "status": "modified"}], "unstaged": [], "untracked": ["b.go"], "lastCommitTimestamp": 1700000000000}`,
+			call:  func(c *Client) (interface{}, error) { return c.Git("p1", "lib").Status(ctx) },
+			want: &GitStatus{
+				Branch: "main", Ahead: 1,
+				Staged:              []GitFileChange{{Path: "a.go", Status: "modified"}},
+				Unstaged:            []GitFileChange{},
+				Untracked:           []string{"b.go"},
+				LastCommitTimestamp: 1700000000000,
+			},
+		},
+		{
+			name:  "chats",
+			path:  "/api/conversations",
+			query: "projectId=p1",
+			body:  `{"chats": [{"id": "c1", "tool": "claude", "topic": "fix", "projectPath": "/src/app", "status": "active", "createdAt": 1700000000000, "pid": 42}]}`,
+			call:  func(c *Client) (interface{}, error) { return c.ListChats(ctx, "", "p1") },
+			want:  []Chat{{ID: "c1", Tool: "claude", Topic: "fix", ProjectPath: "/src/app", Status: "active", CreatedAt: 1700000000000, PID: 42}},
+		},
+		{
+			name:  "terminals",
+			path:  "/api/terminals",
+			query: "source=tmux",
+			body:  `{"terminals": [{"id": "t1", "source": "tmux", "cwd": "/src", "cols": 80, "rows": 24, "createdAt": 1700000000000, "active": false, "exitCode": 2}]}`,
+			call:  func(c *Client) (interface{}, error) { return c.ListTerminals(ctx, TerminalListOptions{Source: "tmux"}) },
+			want:  []Terminal{{ID: "t1", Source: "tmux", Cwd: "/src", Cols: 80, Rows: 24, CreatedAt: 1700000000000, ExitCode: &exitCode}},
+		},
+		{
+			name: "system info",
+			path: "/api/system/info",
+			body: `{"hostname": "box", "platform": "linux", "arch": "x64", "cpus": 8, "memory": {"total": 16, "free": 4, "used": 12}, "uptime": 3600.5, "homeDir": "/home/u", "username": "u"}`,
+			call: func(c *Client) (interface{}, error) { return c.GetSystemInfo(ctx) },
+			want: func() *SystemInfo {
+				info := &SystemInfo{Hostname: "box", Platform: "linux", Arch: "x64", CPUs: 8, Uptime: 3600.5, HomeDir: "/home/u", Username: "u"}
+				info.Memory.Total, info.Memory.Free, info.Memory.Used = 16, 4, 12
+				return info
+			}(),
+		},
+		{
+			name:  "logs",
+			path:  "/api/logs",
+			query: "limit=5",
+			body:  `{"logs": [{"timestamp": "2024-05-06T07:08:09Z", "level": "info", "category": "Auth", "message": "ok", "data": {"ip": "::1"}}]}`,
+			call:  func(c *Client) (interface{}, error) { return c.GetLogs(ctx, LogQuery{Limit: 5}) },
+			want:  []LogEntry{{Timestamp: "2024-05-06T07:08:09Z", Level: "info", Category: "Auth", Message: "ok", Data: json.RawMessage(`{"ip": "::1"}`)}},
+		},
+		{
+			name:  "suggestions",
+			path:  "/api/suggestions/p1",
+			query: "query=rev&type=agent%2Ccommand",
+			body:  `{"suggestions": [{"id": "s1", "type": "agent", "name": "reviewer", "scope": "user"}]}`,
+			call: func(c *Client) (interface{}, error) {
+				return c.GetSuggestions(ctx, "p1", "rev", "agent", "command")
+			},
+			want: []Suggestion{{ID: "s1", Type: "agent", Name: "reviewer", Scope: "user"}},
+		},
+		{
+			name: "sessions",
+			path: "/api/auth/sessions",
+			body: `{"sessions": [{"id": "abc123", "device": "phone", "ip": "::1", "createdAt": 1700000000000, "lastActivity": 1700000060000}]}`,
+			call: func(c *Client) (interface{}, error) { return c.ListSessions(ctx) },
+			want: []Session{{ID: "abc123", Device: "phone", IP: "::1", CreatedAt: 1700000000000, LastActivity: 1700000060000}},
+		},
+		{
+			name: "pairing",
+			path: "/api/auth/pairings",
+			body: `{"success": true, "code": "K7QX-3MHA", "id": "abc123", "expiresAt": 1700000300000}`,
+			call: func(c *Client) (interface{}, error) { return c.CreatePairing(ctx) },
+			want: &Pairing{Code: "K7QX-3MHA", ID: "abc123", ExpiresAt: 1700000300000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.EscapedPath() != tt.path || r.URL.RawQuery != tt.query {
+					t.Errorf("requested %s?%s, want %s?%s", r.URL.EscapedPath(), r.URL.RawQuery, tt.path, tt.query)
+				}
+				io.WriteString(w, tt.body)
+			})
+
+			got, err := tt.call(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientMalformedResponse(t *testing.T) {
+	c := newTestClient(t, respond(http.StatusOK, `{"projects": [`))
+	if _, err := c.ListProjects(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to decode") {
+		t.Errorf("error = %v, want a decoding error", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		is      func(error) bool
+		message string
+		details string
+		hint    string
+		text    string
+	}{
+		{
+			name:    "not found",
+			status:  http.StatusNotFound,
+			body:    `{"error": "Project not found"}`,
+			is:      IsNotFound,
+			message: "Project not found",
+			text:    "GET /api/system/info returned 404: Project not found",
+		},
+		{
+			name:    "unauthorized",
+			status:  http.StatusUnauthorized,
+			body:    `{"error": "Unauthorized"}`,
+			is:      IsUnauthorized,
+			message: "Unauthorized",
+			text:    "GET /api/system/info returned 401: Unauthorized",
+		},
+		{
+			name:    "forbidden",
+			status:  http.StatusForbidden,
+			body:    `{"error": "Token 'ci' is not allowed to GET /system/info"}`,
+			is:      IsForbidden,
+			message: "Token 'ci' is not allowed to GET /system/info",
+			text:    "GET /api/system/info returned 403: Token 'ci' is not allowed to GET /system/info",
+		},
+		{
+			name:    "conflict with details and hint",
+			status:  http.StatusConflict,
+			body:    `{"error": "File exists", "details": "EEXIST", "hint": "use --force"}`,
+			is:      IsConflict,
+			message: "File exists",
+			details: "EEXIST",
+			hint:    "use --force",
+			text:    "GET /api/system/info returned 409: File exists (EEXIST)",
+		},
+		{
+			name:    "message as details",
+			status:  http.StatusInternalServerError,
+			body:    `{"message": "disk full"}`,
+			is:      func(err error) bool { return StatusCode(err) == http.StatusInternalServerError },
+			details: "disk full",
+			text:    "GET /api/system/info returned 500: disk full",
+		},
+		{
+			name:   "body that is not JSON",
+			status: http.StatusBadGateway,
+			body:   `<html>bad gateway</html>`,
+			is:     func(err error) bool { return StatusCode(err) == http.StatusBadGateway },
+			text:   "GET /api/system/info returned 502 Bad Gateway",
+		},
+	}
+	predicates := map[string]func(error) bool{
+		"IsNotFound": IsNotFound, "IsUnauthorized": IsUnauthorized,
+		"IsForbidden": IsForbidden, "IsConflict": IsConflict,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, respond(tt.status, tt.body))
+			_, err := c.GetSystemInfo(context.Background())
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v (%T), want *Error", err, err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Method != http.MethodGet || apiErr.Path != "/api/system/info" {
+				t.Errorf("error = %s %s %d", apiErr.Method, apiErr.Path, apiErr.StatusCode)
+			}
+			if apiErr.Message != tt.message || apiErr.Details != tt.details || apiErr.Hint != tt.hint {
+				t.Errorf("fields = %q, %q, %q; want %q, %q, %q",
+					apiErr.Message, apiErr.Details, apiErr.Hint, tt.message, tt.details, tt.hint)
+			}
+			if string(apiErr.Body) != tt.body {
+				t.Errorf("Body = %s, want %s", apiErr.Body, tt.body)
+			}
+			if err.Error() != tt.text {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.text)
+			}
+			if !tt.is(err) {
+				t.Errorf("predicate for %d is false", tt.status)
+			}
+			for name, is := range predicates {
+				if reflect.ValueOf(is).Pointer() != reflect.ValueOf(tt.is).Pointer() && is(err) {
+					t.Errorf("%s is true for %d", name, tt.status)
+				}
+			}
+		})
+	}
+
+	if StatusCode(errors.New("plain")) != 0 || IsNotFound(nil) {
+		t.Error("non-API errors have a status code")
+	}
+}
+
+func TestClientContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		_, err := c.ListProjects(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("client timeout", func(t *testing.T) {
+		c.SetTimeout(50 * time.Millisecond)
+		defer c.SetTimeout(DefaultTimeout)
+
+		start := time.Now()
+		_, err := c.ListProjects(context.Background())
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want context.DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("request took %v despite the timeout", elapsed)
+		}
+	})
+}
@@ -0,0 +1,487 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Chat is an AI chat session managed by the server.
+type Chat struct {
+	ID           string `json:"id"`
+	Tool         string `json:"tool"`
+	Topic        string `json:"topic"`
+	Model        string `json:"model,omitempty"`
+	Mode         string `json:"mode,omitempty"`
+	ProjectPath  string `json:"projectPath"`
+	Status       string `json:"status"`
+	CreatedAt    Millis `json:"createdAt"`
+	UpdatedAt    Millis `json:"updatedAt,omitempty"`
+	LastActivity Millis `json:"lastActivity,omitempty"`
+	// PID is the CLI process, only set for chats running in memory.
+	PID   int    `json:"pid,omitempty"`
+	Title string `json:"title"`
+}
+
+// ChatMessage is a stored chat message or content block. Fields the
+// server keeps in its metadata column (e.g. tool input, usage) are kept in
+// Extra.
+type ChatMessage struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversationId"`
+	Type           string `json:"type"`
+	Role           string `json:"role,omitempty"`
+	Content        string `json:"content,omitempty"`
+	Timestamp      Millis `json:"timestamp"`
+	IsPartial      bool   `json:"isPartial"`
+	ToolID         string `json:"toolId,omitempty"`
+	ToolName       string `json:"toolName,omitempty"`
+	IsError        bool   `json:"isError,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// chatMessageFields are the keys decoded into ChatMessage's typed fields.
+var chatMessageFields = map[string]bool{
+	"id": true, "conversationId": true, "type": true, "role": true, "content": true,
+	"timestamp": true, "isPartial": true, "toolId": true, "toolName": true, "isError": true,
+}
+
+// UnmarshalJSON decodes the typed fields and collects the rest into Extra.
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	type plain ChatMessage
+	if err := json.Unmarshal(data, (*plain)(m)); err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for key := range chatMessageFields {
+		delete(all, key)
+	}
+	if len(all) > 0 {
+		m.Extra = all
+	} else {
+		m.Extra = nil
+	}
+	return nil
+}
+
+// MarshalJSON encodes the message with its Extra fields inlined, matching
+// the server's shape.
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	type plain ChatMessage
+	data, err := json.Marshal(plain(m))
+	if err != nil || len(m.Extra) == 0 {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for key, value := range m.Extra {
+		if !chatMessageFields[key] {
+			all[key] = value
+		}
+	}
+	return json.Marshal(all)
+}
+
+// CreateChatRequest is the body of POST /api/conversations. Either
+// ProjectPath or ProjectID is required.
+type CreateChatRequest struct {
+	ProjectPath string `json:"projectPath,omitempty"`
+	ProjectID   string `json:"projectId,omitempty"`
+	// Tool is claude (server default), cursor-agent or gemini.
+	Tool  string `json:"tool,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	Model string `json:"model,omitempty"`
+	// Mode is agent (server default), plan or ask.
+	Mode           string `json:"mode,omitempty"`
+	PermissionMode string `json:"permissionMode,omitempty"`
+	// SessionID resumes an existing CLI session.
+	SessionID string `json:"sessionId,omitempty"`
+	// InitialPrompt is sent once the CLI has started.
+	InitialPrompt string `json:"initialPrompt,omitempty"`
+}
+
+// CreatedChat is the result of CreateChat.
+type CreatedChat struct {
+	ConversationID string `json:"conversationId"`
+	Tool           string `json:"tool"`
+	Topic          string `json:"topic"`
+	Model          string `json:"model,omitempty"`
+	Mode           string `json:"mode,omitempty"`
+	ProjectPath    string `json:"projectPath"`
+	ProjectName    string `json:"projectName,omitempty"`
+	Status         string `json:"status"`
+}
+
+// ForkedChat is the result of ForkChat.
+type ForkedChat struct {
+	ConversationID       string `json:"conversationId"`
+	SourceConversationID string `json:"sourceConversationId"`
+	Tool                 string `json:"tool"`
+	Topic                string `json:"topic"`
+	OriginalTopic        string `json:"originalTopic"`
+	ProjectPath          string `json:"projectPath"`
+}
+
+// ChatAttachment describes a file uploaded with UploadChatFiles.
+type ChatAttachment struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+}
+
+// PendingNotification is a chat event that fired while no client was
+// connected.
+type PendingNotification struct {
+	ConversationID string `json:"conversationId"`
+	Type           string `json:"type"`
+	Topic          string `json:"topic,omitempty"`
+	Content        string `json:"content,omitempty"`
+	Timestamp      Millis `json:"timestamp"`
+	IsTurnComplete bool   `json:"isTurnComplete"`
+}
+
+// PendingApproval is a tool call the chat's CLI denied and that waits for
+// the user to approve or reject it.
+type PendingApproval struct {
+	ConversationID string                 `json:"conversationId"`
+	ToolUseID      string                 `json:"toolUseId"`
+	ToolName       string                 `json:"toolName"`
+	Input          map[string]interface{} `json:"input"`
+	Tool           string                 `json:"tool,omitempty"`
+	Topic          string                 `json:"topic,omitempty"`
+	ProjectPath    string                 `json:"projectPath,omitempty"`
+	RequestedAt    Millis                 `json:"requestedAt,omitempty"`
+}
+
+// ToolAvailability describes whether an AI CLI is installed on the server.
+type ToolAvailability struct {
+	ID                  string `json:"id,omitempty"`
+	Available           bool   `json:"available"`
+	DisplayName         string `json:"displayName"`
+	InstallInstructions string `json:"installInstructions"`
+	Error               string `json:"error,omitempty"`
+}
+
+// ListChats returns the persisted chats, optionally limited to a project
+// given by path or ID (both may be empty).
+func (c *Client) ListChats(ctx context.Context, projectPath, projectID string) ([]Chat, error) {
+	query := url.Values{}
+	if projectPath != "" {
+		query.Set("projectPath", projectPath)
+	}
+	if projectID != "" {
+		query.Set("projectId", projectID)
+	}
+	var resp struct {
+		Chats []Chat `json:"chats"`
+	}
+	if err := c.get(ctx, "/api/conversations", query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Chats, nil
+}
+
+// GetChat returns a chat by conversation ID.
+func (c *Client) GetChat(ctx context.Context, conversationID string) (*Chat, error) {
+	var resp struct {
+		Chat *Chat `json:"chat"`
+	}
+	if err := c.get(ctx, "/api/conversations/"+url.PathEscape(conversationID), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Chat, nil
+}
+
+// CreateChat starts a new chat session.
+func (c *Client) CreateChat(ctx context.Context, req CreateChatRequest) (*CreatedChat, error) {
+	var chat CreatedChat
+	r := &request{method: http.MethodPost, path: "/api/conversations", body: req, timeout: time.Minute}
+	if err := c.do(ctx, r, &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// RenameChat sets a chat's topic.
+func (c *Client) RenameChat(ctx context.Context, conversationID, topic string) error {
+	r := &request{
+		method: http.MethodPatch,
+		path:   "/api/conversations/" + url.PathEscape(conversationID),
+		body:   map[string]string{"topic": topic},
+	}
+	return c.do(ctx, r, nil)
+}
+
+// DeleteChat stops a chat and deletes it with its messages.
+func (c *Client) DeleteChat(ctx context.Context, conversationID string) error {
+	return c.delete(ctx, "/api/conversations/"+url.PathEscape(conversationID), nil, nil)
+}
+
+// GetChatMessages returns a chat's messages, oldest first. limit > 0 keeps
+// only the most recent ones; partial (still streaming) messages are
+// included when includePartial is set.
+func (c *Client) GetChatMessages(ctx context.Context, conversationID string, limit int, includePartial bool) ([]ChatMessage, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if includePartial {
+		query.Set("includePartial", "true")
+	}
+	var resp struct {
+		Messages []ChatMessage `json:"messages"`
+	}
+	if err := c.get(ctx, "/api/conversations/"+url.PathEscape(conversationID)+"/messages", query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// ForkChat starts a new chat with the settings of a running one. An empty
+// topic lets the server derive one.
+func (c *Client) ForkChat(ctx context.Context, conversationID, topic string) (*ForkedChat, error) {
+	body := map[string]string{}
+	if topic != "" {
+		body["newTopic"] = topic
+	}
+	var chat ForkedChat
+	r := &request{
+		method:  http.MethodPost,
+		path:    "/api/conversations/" + url.PathEscape(conversationID) + "/fork",
+		body:    body,
+		timeout: time.Minute,
+	}
+	if err := c.do(ctx, r, &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// UploadChatFiles uploads up to 5 images or documents (10MB each) for a
+// chat.
+func (c *Client) UploadChatFiles(ctx context.Context, conversationID string, files []UploadFile) ([]ChatAttachment, error) {
+	var resp struct {
+		Attachments []ChatAttachment `json:"attachments"`
+	}
+	path := "/api/conversations/" + url.PathEscape(conversationID) + "/upload"
+	if err := c.upload(ctx, path, nil, files, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Attachments, nil
+}
+
+// PendingNotifications returns and clears the chat events that fired while
+// no client was connected.
+func (c *Client) PendingNotifications(ctx context.Context) ([]PendingNotification, error) {
+	var resp struct {
+		Notifications []PendingNotification `json:"notifications"`
+	}
+	if err := c.get(ctx, "/api/conversations/notifications/pending", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Notifications, nil
+}
+
+// PendingApprovals returns the tool calls awaiting approval in all chats.
+// Unlike PendingNotifications it does not clear them.
+func (c *Client) PendingApprovals(ctx context.Context) ([]PendingApproval, error) {
+	var resp struct {
+		Approvals []PendingApproval `json:"approvals"`
+	}
+	if err := c.get(ctx, "/api/conversations/approvals/pending", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Approvals, nil
+}
+
+// AnswerApproval approves or rejects a pending tool call. An approved call
+// is run by the server. A call that is no longer pending is a 404 *Error.
+func (c *Client) AnswerApproval(ctx context.Context, conversationID, toolUseID string, approved bool) error {
+	body := map[string]interface{}{"toolUseId": toolUseID, "approved": approved}
+	return c.post(ctx, "/api/conversations/"+url.PathEscape(conversationID)+"/approvals", nil, body, nil)
+}
+
+// ListChatTools returns the supported AI CLIs and whether each is installed.
+func (c *Client) ListChatTools(ctx context.Context) ([]ToolAvailability, error) {
+	var resp struct {
+		Tools []ToolAvailability `json:"tools"`
+	}
+	if err := c.get(ctx, "/api/conversations/tools", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tools, nil
+}
+
+// ChatToolAvailability returns the availability of each AI CLI by name.
+func (c *Client) ChatToolAvailability(ctx context.Context) (map[string]ToolAvailability, error) {
+	var resp struct {
+		Tools map[string]ToolAvailability `json:"tools"`
+	}
+	if err := c.get(ctx, "/api/conversations/tools/availability", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tools, nil
+}
+
+// Chat socket message types.
+const (
+	MsgChatAttach         = "chatAttach"
+	MsgChatAttached       = "chatAttached"
+	MsgChatDetach         = "chatDetach"
+	MsgChatHistory        = "chatHistory"
+	MsgChatEvent          = "chatEvent"
+	MsgChatMessage        = "chatMessage"
+	MsgChatMessageSent    = "chatMessageSent"
+	MsgChatCancel         = "chatCancel"
+	MsgChatCancelled      = "chatCancelled"
+	MsgChatApproval       = "chatApproval"
+	MsgChatQuestionAnswer = "chatQuestionAnswer"
+	MsgChatError          = "chatError"
+)
+
+// Chat event types, the Type of a ChatEvent.
+const (
+	ChatEventText             = "text"
+	ChatEventThinking         = "thinking"
+	ChatEventToolUse          = "tool_use_start"
+	ChatEventToolResult       = "tool_use_result"
+	ChatEventApprovalRequest  = "approval_request"
+	ChatEventQuestionPrompt   = "question_prompt"
+	ChatEventQuestionAnswered = "question_answered"
+	ChatEventSessionEnd       = "session_end"
+	ChatEventCancelled        = "cancelled"
+	ChatEventError            = "error"
+	ChatEventSystem           = "system"
+	ChatEventTopicUpdated     = "topic_updated"
+	ChatEventRaw              = "raw"
+)
+
+// ChatEvent is a content block streamed from a chat's CLI. Partial text,
+// thinking and tool_use_start events carry a delta in Content and share the
+// ID of the block they extend; the first tool_use_start of a block carries
+// ToolName.
+type ChatEvent struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	ConversationID string `json:"conversationId"`
+	Role           string `json:"role,omitempty"`
+	Content        string `json:"content,omitempty"`
+	Timestamp      Millis `json:"timestamp"`
+	IsPartial      bool   `json:"isPartial,omitempty"`
+	// IsTurnComplete is set on the session_end that ends a turn, as
+	// opposed to the end of one of its messages.
+	IsTurnComplete bool `json:"isTurnComplete,omitempty"`
+
+	ToolID   string          `json:"toolId,omitempty"`
+	ToolName string          `json:"toolName,omitempty"`
+	Input    json.RawMessage `json:"input,omitempty"`
+	IsError  bool            `json:"isError,omitempty"`
+
+	// approval_request
+	Prompt string `json:"prompt,omitempty"`
+	Action string `json:"action,omitempty"`
+
+	// question_prompt and question_answered
+	Questions []ChatQuestion      `json:"questions,omitempty"`
+	Answers   map[string][]string `json:"answers,omitempty"`
+
+	// topic_updated
+	Topic string `json:"topic,omitempty"`
+}
+
+// ChatQuestion is a question the agent asks the user.
+type ChatQuestion struct {
+	Question    string               `json:"question"`
+	Header      string               `json:"header"`
+	Options     []ChatQuestionOption `json:"options"`
+	MultiSelect bool                 `json:"multiSelect"`
+}
+
+// ChatQuestionOption is a suggested answer to a ChatQuestion.
+type ChatQuestionOption struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+}
+
+// ChatSocketMessage is the body of the chat* messages the server sends.
+// Event is set for chatEvent, Messages for chatHistory and Message for
+// chatAttached, chatCancelled and chatError.
+type ChatSocketMessage struct {
+	Type           string        `json:"type"`
+	ConversationID string        `json:"conversationId"`
+	Message        string        `json:"message,omitempty"`
+	Event          *ChatEvent    `json:"event,omitempty"`
+	Messages       []ChatMessage `json:"messages,omitempty"`
+	MessageID      string        `json:"messageId,omitempty"`
+
+	// chatAttached
+	Tool          string `json:"tool,omitempty"`
+	WorkspacePath string `json:"workspacePath,omitempty"`
+	Status        string `json:"status,omitempty"`
+}
+
+// ChatAttach subscribes to a chat's events, starting its CLI if needed. The
+// server answers with chatAttached, followed by chatHistory, or chatError.
+func (s *Socket) ChatAttach(conversationID, projectPath string) error {
+	msg := map[string]string{"type": MsgChatAttach, "conversationId": conversationID}
+	if projectPath != "" {
+		msg["workspaceId"] = projectPath
+	}
+	return s.Send(msg)
+}
+
+// ChatDetach unsubscribes from a chat. The chat keeps running and the
+// socket still gets its turn-complete session_end events.
+func (s *Socket) ChatDetach(conversationID string) error {
+	return s.Send(map[string]string{"type": MsgChatDetach, "conversationId": conversationID})
+}
+
+// ChatSend sends a user message to a chat, switching it to mode first
+// unless mode is empty. The server answers with chatMessageSent or
+// chatError.
+func (s *Socket) ChatSend(conversationID, content, mode string) error {
+	msg := map[string]string{"type": MsgChatMessage, "conversationId": conversationID, "content": content}
+	if mode != "" {
+		msg["mode"] = mode
+	}
+	return s.Send(msg)
+}
+
+// ChatCancel interrupts a chat's running turn; the server answers with
+// chatCancelled or chatError.
+func (s *Socket) ChatCancel(conversationID string) error {
+	return s.Send(map[string]string{"type": MsgChatCancel, "conversationId": conversationID})
+}
+
+// ChatApproval answers an approval_request; toolID is the request's ToolID.
+func (s *Socket) ChatApproval(conversationID, toolID string, approved bool) error {
+	return s.Send(map[string]interface{}{
+		"type":           MsgChatApproval,
+		"conversationId": conversationID,
+		"blockId":        toolID,
+		"approved":       approved,
+	})
+}
+
+// ChatQuestionAnswer answers a question_prompt. answers maps each question
+// to the chosen option labels or free-form text.
+func (s *Socket) ChatQuestionAnswer(conversationID, toolID string, answers map[string][]string) error {
+	return s.Send(map[string]interface{}{
+		"type":           MsgChatQuestionAnswer,
+		"conversationId": conversationID,
+		"toolUseId":      toolID,
+		"answers":        answers,
+	})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody bounds how much of an error response is kept.
+const maxErrorBody = 64 * 1024
+
+// Error is a non-2xx response from the server. Routes report failures as
+// {"error": ..., "details"|"message"|"hint": ...}; those fields are decoded
+// when present.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is the body's "error" field.
+	Message string
+	// Details is the body's "details" or "message" field, which routes use
+	// interchangeably for the underlying cause.
+	Details string
+	// Hint is the body's "hint" field, a suggested fix.
+	Hint string
+	// Body is the raw response body.
+	Body []byte
+}
+
+// newError builds an *Error from a response body.
+func newError(method, path string, status int, body []byte) *Error {
+	e := &Error{Method: method, Path: path, StatusCode: status, Body: body}
+
+	var fields struct {
+		Error   interface{} `json:"error"`
+		Details string      `json:"details"`
+		Message string      `json:"message"`
+		Hint    string      `json:"hint"`
+	}
+	if json.Unmarshal(body, &fields) == nil {
+		switch v := fields.Error.(type) {
+		case string:
+			e.Message = v
+		case nil:
+		default:
+			e.Message = fmt.Sprint(v)
+		}
+		e.Details = fields.Details
+		if e.Details == "" {
+			e.Details = fields.Message
+		}
+		e.Hint = fields.Hint
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s returned %d", e.Method, e.Path, e.StatusCode)
+	switch {
+	case e.Message != "":
+		b.WriteString(": " + e.Message)
+	case e.Details == "":
+		b.WriteString(" " + http.StatusText(e.StatusCode))
+	}
+	if e.Details != "" && e.Details != e.Message {
+		if e.Message != "" {
+			b.WriteString(" (" + e.Details + ")")
+		} else {
+			b.WriteString(": " + e.Details)
+		}
+	}
+	return b.String()
+}
+
+// StatusCode returns the HTTP status of an API error, or 0 when err is not
+// an *Error.
+func StatusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is a 404 from the server.
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized reports whether err is a 401 from the server, i.e. the
+// token was rejected.
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether err is a 403 from the server: the token is
+// valid but not allowed to make the request.
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsConflict reports whether err is a 409 from the server, e.g. a file that
+// already exists.
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
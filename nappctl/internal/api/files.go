@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// FileContent is a file read from the server.
+type FileContent struct {
+	Path      string    `json:"path"`
+	Content   string    `json:"content"`
+	Size      int64     `json:"size"`
+	Modified  time.Time `json:"modified"`
+	Extension string    `json:"extension"`
+	// IsBinary means Content is base64 encoded.
+	IsBinary bool   `json:"isBinary"`
+	MimeType string `json:"mimeType"`
+}
+
+// Bytes returns the file contents, decoding base64 for binary files.
+func (f *FileContent) Bytes() ([]byte, error) {
+	if !f.IsBinary {
+		return []byte(f.Content), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(f.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", f.Path, err)
+	}
+	return data, nil
+}
+
+// FileInfo is an entry of a directory listing.
+type FileInfo struct {
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	IsDirectory bool      `json:"isDirectory"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+}
+
+// DirListing is the result of ListDir.
+type DirListing struct {
+	Items []FileInfo `json:"items"`
+	// ResolvedPath is the absolute path after ~ expansion.
+	ResolvedPath string `json:"resolvedPath"`
+}
+
+// Limits the server's upload handler enforces.
+const (
+	MaxUploadSize  = 50 << 20 // bytes per file
+	MaxUploadFiles = 10       // files per request
+)
+
+// UploadFile is a file to upload. ContentType defaults to a guess from the
+// name's extension.
+type UploadFile struct {
+	Name        string
+	Content     io.Reader
+	ContentType string
+}
+
+// UploadedFile describes a file written by UploadFiles.
+type UploadedFile struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+// UploadError describes a file UploadFiles failed to write.
+type UploadError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// UploadResult is the result of UploadFiles.
+type UploadResult struct {
+	Uploaded      []UploadedFile `json:"uploaded"`
+	Errors        []UploadError  `json:"errors,omitempty"`
+	TotalUploaded int            `json:"totalUploaded"`
+	TotalFailed   int            `json:"totalFailed"`
+}
+
+// ReadFile reads a file on the server.
+func (c *Client) ReadFile(ctx context.Context, path string) (*FileContent, error) {
+	var file FileContent
+	if err := c.get(ctx, "/api/files/read", url.Values{"filePath": {path}}, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// WriteFile writes a text file on the server, creating parent directories,
+// and returns a unified diff against the previous contents.
+func (c *Client) WriteFile(ctx context.Context, path, content string) (string, error) {
+	var resp struct {
+		Diff string `json:"diff"`
+	}
+	body := map[string]string{"filePath": path, "content": content}
+	if err := c.post(ctx, "/api/files/write", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Diff, nil
+}
+
+// Diff asks the server for a unified diff between two versions of a file.
+// The contents travel in the query string, so this suits small files only.
+func (c *Client) Diff(ctx context.Context, path, original, modified string) (string, error) {
+	query := url.Values{"original": {original}, "modified": {modified}}
+	if path != "" {
+		query.Set("filePath", path)
+	}
+	var resp struct {
+		Diff string `json:"diff"`
+	}
+	if err := c.get(ctx, "/api/files/diff", query, &resp); err != nil {
+		return "", err
+	}
+	return resp.Diff, nil
+}
+
+// ListDir lists a directory on the server, directories first. Hidden entries
+// are omitted. ~ is expanded to the server user's home. It fails with a 404
+// (see IsNotFound) if the path does not exist and a 400 if it is not a
+// directory.
+func (c *Client) ListDir(ctx context.Context, path string) (*DirListing, error) {
+	var listing DirListing
+	if err := c.get(ctx, "/api/files/list", url.Values{"dirPath": {path}}, &listing); err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// CreateFile creates a new file. It fails with a 409 (see IsConflict) if the
+// file exists.
+func (c *Client) CreateFile(ctx context.Context, path, content string) error {
+	body := map[string]string{"filePath": path, "content": content}
+	return c.post(ctx, "/api/files/create", nil, body, nil)
+}
+
+// DeleteFile deletes a file.
+func (c *Client) DeleteFile(ctx context.Context, path string) error {
+	return c.delete(ctx, "/api/files/delete", url.Values{"filePath": {path}}, nil)
+}
+
+// RenameFile renames a file or directory within its directory and returns
+// the new path.
+func (c *Client) RenameFile(ctx context.Context, oldPath, newName string) (string, error) {
+	var resp struct {
+		NewPath string `json:"newPath"`
+	}
+	body := map[string]string{"oldPath": oldPath, "newName": newName}
+	if err := c.post(ctx, "/api/files/rename", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.NewPath, nil
+}
+
+// MoveFile moves a file or directory. The destination must not exist.
+func (c *Client) MoveFile(ctx context.Context, sourcePath, destinationPath string) error {
+	body := map[string]string{"sourcePath": sourcePath, "destinationPath": destinationPath}
+	return c.post(ctx, "/api/files/move", nil, body, nil)
+}
+
+// Mkdir creates a directory and its parents. It fails with a 409 if the
+// path exists.
+func (c *Client) Mkdir(ctx context.Context, path string) error {
+	return c.post(ctx, "/api/files/mkdir", nil, map[string]string{"dirPath": path}, nil)
+}
+
+// UploadFiles uploads files into a directory on the server, creating it if
+// needed and overwriting files of the same name. At most MaxUploadFiles of
+// up to MaxUploadSize each are accepted per call.
+func (c *Client) UploadFiles(ctx context.Context, destinationPath string, files []UploadFile) (*UploadResult, error) {
+	var result UploadResult
+	fields := map[string]string{"destinationPath": destinationPath}
+	if err := c.upload(ctx, "/api/files/upload", fields, files, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// upload streams a multipart/form-data request with the files under the
+// "files" field, which is what the server's multer handlers expect.
+func (c *Client) upload(ctx context.Context, path string, fields map[string]string, files []UploadFile, out interface{}) error {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, fields, files))
+	}()
+
+	r := &request{
+		method:      http.MethodPost,
+		path:        path,
+		raw:         pr,
+		contentType: mw.FormDataContentType(),
+		timeout:     5 * time.Minute,
+	}
+	err := c.do(ctx, r, out)
+	// Unblock the writer if the request ended before the body was consumed
+	pr.CloseWithError(io.ErrClosedPipe)
+	return err
+}
+
+func writeMultipart(mw *multipart.Writer, fields map[string]string, files []UploadFile) error {
+	// Fields go first so multer has them when it sees the files
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(f.Name))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, escapeQuotes(filepath.Base(f.Name))))
+		header.Set("Content-Type", contentType)
+
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, f.Content); err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+	}
+	return mw.Close()
+}
+
+// escapeQuotes escapes a filename for a Content-Disposition header, as
+// mime/multipart does for CreateFormFile.
+func escapeQuotes(s string) string {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r == '\\' || r == '"' {
+			out = append(out, '\\')
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// GitFileChange is a changed file in the index or working tree.
+type GitFileChange struct {
+	Path string `json:"path"`
+	// Status is modified, added, deleted, renamed, copied or unmerged.
+	Status  string `json:"status"`
+	OldPath string `json:"oldPath,omitempty"`
+}
+
+// GitStatus is the status of a repository.
+type GitStatus struct {
+	Branch              string          `json:"branch"`
+	Ahead               int             `json:"ahead"`
+	Behind              int             `json:"behind"`
+	Staged              []GitFileChange `json:"staged"`
+	Unstaged            []GitFileChange `json:"unstaged"`
+	Untracked           []string        `json:"untracked"`
+	LastCommitTimestamp Millis          `json:"lastCommitTimestamp,omitempty"`
+}
+
+// Clean reports whether there are no staged, unstaged or untracked changes.
+func (s *GitStatus) Clean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0
+}
+
+// GitBranch is a local or remote branch.
+type GitBranch struct {
+	Name      string `json:"name"`
+	IsRemote  bool   `json:"isRemote"`
+	IsCurrent bool   `json:"isCurrent"`
+}
+
+// GitAuthor is a commit author.
+type GitAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// GitCommit is a commit from the log.
+type GitCommit struct {
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"shortHash"`
+	Author    GitAuthor `json:"author"`
+	Timestamp Millis    `json:"timestamp"`
+	Subject   string    `json:"subject"`
+	Parents   []string  `json:"parents"`
+	Refs      []string  `json:"refs"`
+}
+
+// GitCommitFile is a file changed by a commit.
+type GitCommitFile struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Status    string `json:"status"`
+	OldPath   string `json:"oldPath,omitempty"`
+}
+
+// GitCommitDetail is a commit with its body and changed files.
+type GitCommitDetail struct {
+	GitCommit
+	Body  string          `json:"body"`
+	Files []GitCommitFile `json:"files"`
+}
+
+// GitDiff is a (possibly truncated) diff of one file.
+type GitDiff struct {
+	Diff       string `json:"diff"`
+	Truncated  bool   `json:"truncated"`
+	TotalLines int    `json:"totalLines"`
+}
+
+// GitDiffOptions selects the diff returned by GitDiff.
+type GitDiffOptions struct {
+	// Staged diffs the index instead of the working tree.
+	Staged bool
+	// CommitHash diffs the file as changed by that commit.
+	CommitHash string
+	// MaxLines truncates the diff (server default 2000).
+	MaxLines int
+}
+
+// GitRemote is a configured remote.
+type GitRemote struct {
+	Name     string `json:"name"`
+	FetchURL string `json:"fetchUrl"`
+	PushURL  string `json:"pushUrl"`
+}
+
+// GitRepository is a repository found in a project by ScanRepos. Path is
+// relative to the project, "." for the project itself.
+type GitRepository struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+// GitResult is the result of a git operation. Output is git's output where
+// the operation produces any.
+type GitResult struct {
+	Success bool   `json:"success"`
+	Output  string `json:"output,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// CommitMessageSuggestion is the result of GenerateCommitMessage.
+type CommitMessageSuggestion struct {
+	Message     string `json:"message"`
+	StagedFiles int    `json:"stagedFiles"`
+	Truncated   bool   `json:"truncated"`
+}
+
+// GitRepo scopes git calls to a project's repository, or to a
+// sub-repository at a path relative to the project.
+type GitRepo struct {
+	client    *Client
+	projectID string
+	repoPath  string
+}
+
+// Git returns the git API for a project. repoPath selects a
+// sub-repository relative to the project; empty means the project itself.
+func (c *Client) Git(projectID, repoPath string) *GitRepo {
+	return &GitRepo{client: c, projectID: projectID, repoPath: repoPath}
+}
+
+func (g *GitRepo) path(action string) string {
+	return "/api/git/" + url.PathEscape(g.projectID) + "/" + action
+}
+
+func (g *GitRepo) query() url.Values {
+	query := url.Values{}
+	if g.repoPath != "" {
+		query.Set("repoPath", g.repoPath)
+	}
+	return query
+}
+
+func (g *GitRepo) post(ctx context.Context, action string, body interface{}) (*GitResult, error) {
+	var result GitResult
+	if err := g.client.post(ctx, g.path(action), g.query(), body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Status returns the repository status. It fails with a 400 for a
+// directory that is not a git repository.
+func (g *GitRepo) Status(ctx context.Context) (*GitStatus, error) {
+	var status GitStatus
+	if err := g.client.get(ctx, g.path("status"), g.query(), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Branches returns local and remote branches.
+func (g *GitRepo) Branches(ctx context.Context) ([]GitBranch, error) {
+	var resp struct {
+		Branches []GitBranch `json:"branches"`
+	}
+	if err := g.client.get(ctx, g.path("branches"), g.query(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Branches, nil
+}
+
+// Stage stages files.
+func (g *GitRepo) Stage(ctx context.Context, files []string) error {
+	_, err := g.post(ctx, "stage", map[string][]string{"files": files})
+	return err
+}
+
+// Unstage unstages files.
+func (g *GitRepo) Unstage(ctx context.Context, files []string) error {
+	_, err := g.post(ctx, "unstage", map[string][]string{"files": files})
+	return err
+}
+
+// Discard discards working tree changes to tracked files.
+func (g *GitRepo) Discard(ctx context.Context, files []string) error {
+	_, err := g.post(ctx, "discard", map[string][]string{"files": files})
+	return err
+}
+
+// Clean deletes untracked files.
+func (g *GitRepo) Clean(ctx context.Context, files []string) error {
+	_, err := g.post(ctx, "clean", map[string][]string{"files": files})
+	return err
+}
+
+// Commit creates a commit, staging files first when given. The result
+// carries the new hash.
+func (g *GitRepo) Commit(ctx context.Context, message string, files []string) (*GitResult, error) {
+	body := map[string]interface{}{"message": message}
+	if len(files) > 0 {
+		body["files"] = files
+	}
+	return g.post(ctx, "commit", body)
+}
+
+// Push pushes to a remote (server default origin) and branch (default
+// current).
+func (g *GitRepo) Push(ctx context.Context, remote, branch string) (*GitResult, error) {
+	return g.postRemote(ctx, "push", remote, branch)
+}
+
+// Pull pulls from a remote (server default origin) and branch (default
+// current).
+func (g *GitRepo) Pull(ctx context.Context, remote, branch string) (*GitResult, error) {
+	return g.postRemote(ctx, "pull", remote, branch)
+}
+
+// Fetch fetches a remote (server default origin).
+func (g *GitRepo) Fetch(ctx context.Context, remote string) error {
+	_, err := g.postRemote(ctx, "fetch", remote, "")
+	return err
+}
+
+func (g *GitRepo) postRemote(ctx context.Context, action, remote, branch string) (*GitResult, error) {
+	body := map[string]string{}
+	if remote != "" {
+		body["remote"] = remote
+	}
+	if branch != "" {
+		body["branch"] = branch
+	}
+	var result GitResult
+	r := &request{method: http.MethodPost, path: g.path(action), query: g.query(), body: body, timeout: 2 * time.Minute}
+	if err := g.client.do(ctx, r, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Checkout checks out a branch. Remote branches get a local tracking
+// branch; the result carries the branch checked out.
+func (g *GitRepo) Checkout(ctx context.Context, branch string) (*GitResult, error) {
+	return g.post(ctx, "checkout", map[string]string{"branch": branch})
+}
+
+// CreateBranch creates a branch at startPoint (empty for HEAD) and
+// optionally checks it out.
+func (g *GitRepo) CreateBranch(ctx context.Context, name, startPoint string, checkout bool) (*GitResult, error) {
+	body := map[string]interface{}{"name": name, "checkout": checkout}
+	if startPoint != "" {
+		body["startPoint"] = startPoint
+	}
+	return g.post(ctx, "branch", body)
+}
+
+// Diff returns the diff of one file.
+func (g *GitRepo) Diff(ctx context.Context, file string, opts GitDiffOptions) (*GitDiff, error) {
+	query := g.query()
+	query.Set("file", file)
+	if opts.Staged {
+		query.Set("staged", "true")
+	}
+	if opts.CommitHash != "" {
+		query.Set("commitHash", opts.CommitHash)
+	}
+	if opts.MaxLines > 0 {
+		query.Set("maxLines", strconv.Itoa(opts.MaxLines))
+	}
+	var diff GitDiff
+	if err := g.client.get(ctx, g.path("diff"), query, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// Log returns commits, newest first. limit <= 0 uses the server default
+// of 10.
+func (g *GitRepo) Log(ctx context.Context, limit, skip int) ([]GitCommit, error) {
+	query := g.query()
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if skip > 0 {
+		query.Set("skip", strconv.Itoa(skip))
+	}
+	var resp struct {
+		Commits []GitCommit `json:"commits"`
+	}
+	if err := g.client.get(ctx, g.path("log"), query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Commits, nil
+}
+
+// Show returns a commit with its changed files.
+func (g *GitRepo) Show(ctx context.Context, hash string) (*GitCommitDetail, error) {
+	var detail GitCommitDetail
+	if err := g.client.get(ctx, g.path("commit/"+url.PathEscape(hash)), g.query(), &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// Remotes returns the configured remotes.
+func (g *GitRepo) Remotes(ctx context.Context) ([]GitRemote, error) {
+	var resp struct {
+		Remotes []GitRemote `json:"remotes"`
+	}
+	if err := g.client.get(ctx, g.path("remotes"), g.query(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Remotes, nil
+}
+
+// ScanRepos finds the git repositories in the project, including nested
+// ones, down to maxDepth (server default 5). It ignores the repo path.
+func (g *GitRepo) ScanRepos(ctx context.Context, maxDepth int) ([]GitRepository, error) {
+	query := url.Values{}
+	if maxDepth > 0 {
+		query.Set("maxDepth", strconv.Itoa(maxDepth))
+	}
+	var resp struct {
+		Repositories []GitRepository `json:"repositories"`
+	}
+	if err := g.client.get(ctx, g.path("scan-repos"), query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Repositories, nil
+}
+
+// CheckoutDetached checks out a commit with a detached HEAD.
+func (g *GitRepo) CheckoutDetached(ctx context.Context, hash string) (*GitResult, error) {
+	return g.post(ctx, "checkout-detached", map[string]string{"hash": hash})
+}
+
+// CherryPick cherry-picks a commit onto the current branch.
+func (g *GitRepo) CherryPick(ctx context.Context, hash string) (*GitResult, error) {
+	return g.post(ctx, "cherry-pick", map[string]string{"hash": hash})
+}
+
+// Revert creates a commit reverting hash.
+func (g *GitRepo) Revert(ctx context.Context, hash string) (*GitResult, error) {
+	return g.post(ctx, "revert-commit", map[string]string{"hash": hash})
+}
+
+// Tag tags hash (empty for HEAD). A message makes an annotated tag.
+func (g *GitRepo) Tag(ctx context.Context, name, hash, message string) (*GitResult, error) {
+	body := map[string]string{"name": name}
+	if hash != "" {
+		body["hash"] = hash
+	}
+	if message != "" {
+		body["message"] = message
+	}
+	return g.post(ctx, "tag", body)
+}
+
+// Reset resets the current branch to hash. mode is soft, mixed (server
+// default) or hard.
+func (g *GitRepo) Reset(ctx context.Context, hash, mode string) (*GitResult, error) {
+	body := map[string]string{"hash": hash}
+	if mode != "" {
+		body["mode"] = mode
+	}
+	return g.post(ctx, "reset", body)
+}
+
+// GenerateCommitMessage asks an AI CLI on the server for a commit message
+// describing the staged changes.
+func (g *GitRepo) GenerateCommitMessage(ctx context.Context) (*CommitMessageSuggestion, error) {
+	var suggestion CommitMessageSuggestion
+	r := &request{
+		method:  http.MethodPost,
+		path:    g.path("generate-commit-message"),
+		query:   g.query(),
+		body:    struct{}{},
+		timeout: 2 * time.Minute,
+	}
+	if err := g.client.do(ctx, r, &suggestion); err != nil {
+		return nil, err
+	}
+	return &suggestion, nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+)
+
+// LogEntry is a structured server log entry as returned by /api/logs.
+type LogEntry struct {
+	Timestamp string          `json:"timestamp"`
+	Level     string          `json:"level"`
+	Category  string          `json:"category"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// LogQuery filters log requests. Zero values are omitted.
+type LogQuery struct {
+	Limit    int
+	Level    string
+	Category string
+	// Since is an ISO timestamp; only honoured by GetLogs.
+	Since string
+}
+
+func (q LogQuery) values() url.Values {
+	v := url.Values{}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Level != "" {
+		v.Set("level", q.Level)
+	}
+	if q.Category != "" {
+		v.Set("category", q.Category)
+	}
+	if q.Since != "" {
+		v.Set("since", q.Since)
+	}
+	return v
+}
+
+// GetLogs retrieves recent log entries from the server's in-memory buffer,
+// newest first.
+func (c *Client) GetLogs(ctx context.Context, q LogQuery) ([]LogEntry, error) {
+	var resp struct {
+		Logs []LogEntry `json:"logs"`
+	}
+	if err := c.get(ctx, "/api/logs", q.values(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
+
+// GetLogDates lists the dates (YYYY-MM-DD) with log files, newest first.
+func (c *Client) GetLogDates(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Dates []string `json:"dates"`
+	}
+	if err := c.get(ctx, "/api/logs/dates", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Dates, nil
+}
+
+// GetLogsForDate retrieves log entries from a given day's log file,
+// newest first.
+func (c *Client) GetLogsForDate(ctx context.Context, date string, q LogQuery) ([]LogEntry, error) {
+	q.Since = ""
+	var resp struct {
+		Logs []LogEntry `json:"logs"`
+	}
+	if err := c.get(ctx, "/api/logs/date/"+url.PathEscape(date), q.values(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
+
+// ClearLogs deletes all of the server's log files and its in-memory buffer.
+func (c *Client) ClearLogs(ctx context.Context) error {
+	return c.delete(ctx, "/api/logs", nil, nil)
+}
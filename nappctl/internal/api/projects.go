@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Project is a project known to the server. The ID is the base64 encoding
+// of its path.
+type Project struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	LastOpened time.Time `json:"lastOpened"`
+}
+
+// TreeNode is an entry of a project file tree.
+type TreeNode struct {
+	Name      string     `json:"name"`
+	Path      string     `json:"path"`
+	Type      string     `json:"type"` // "file" or "directory"
+	Size      int64      `json:"size,omitempty"`
+	Extension string     `json:"extension,omitempty"`
+	Children  []TreeNode `json:"children,omitempty"`
+}
+
+// IsDir reports whether the node is a directory.
+func (n *TreeNode) IsDir() bool {
+	return n.Type == "directory"
+}
+
+// CreateProjectRequest is the body of POST /api/projects.
+type CreateProjectRequest struct {
+	Name string `json:"name"`
+	// Path is the parent directory; the server defaults to ~/Projects.
+	Path     string `json:"path,omitempty"`
+	Template string `json:"template,omitempty"`
+	// CreateGitRepo initialises git and creates a private GitHub repo with gh.
+	CreateGitRepo bool `json:"createGitRepo,omitempty"`
+}
+
+// CreatedProject is the project returned by CreateProject.
+type CreatedProject struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	CreatedAt  time.Time `json:"createdAt"`
+	GitRepoURL string    `json:"gitRepoUrl,omitempty"`
+	GitError   string    `json:"gitError,omitempty"`
+}
+
+// ProjectChat is a tmux chat window of a project.
+type ProjectChat struct {
+	ID          string `json:"id"`
+	TerminalID  string `json:"terminalId"`
+	WindowName  string `json:"windowName"`
+	Tool        string `json:"tool"`
+	Topic       string `json:"topic"`
+	SessionName string `json:"sessionName"`
+	WindowIndex int    `json:"windowIndex"`
+	ProjectPath string `json:"projectPath"`
+	Active      bool   `json:"active"`
+	Title       string `json:"title"`
+	Timestamp   Millis `json:"timestamp"`
+}
+
+// ListProjects returns the registered and recent projects, most recently
+// opened first.
+func (c *Client) ListProjects(ctx context.Context) ([]Project, error) {
+	var resp struct {
+		Projects []Project `json:"projects"`
+	}
+	if err := c.get(ctx, "/api/projects", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Projects, nil
+}
+
+// GetProject returns a project by ID.
+func (c *Client) GetProject(ctx context.Context, projectID string) (*Project, error) {
+	var resp struct {
+		Project *Project `json:"project"`
+	}
+	if err := c.get(ctx, "/api/projects/"+url.PathEscape(projectID), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Project, nil
+}
+
+// GetProjectTree returns the project's file tree down to depth levels
+// (server default 3 when depth <= 0).
+func (c *Client) GetProjectTree(ctx context.Context, projectID string, depth int) ([]TreeNode, error) {
+	query := url.Values{}
+	if depth > 0 {
+		query.Set("depth", strconv.Itoa(depth))
+	}
+	var resp struct {
+		Tree []TreeNode `json:"tree"`
+	}
+	if err := c.get(ctx, "/api/projects/"+url.PathEscape(projectID)+"/tree", query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tree, nil
+}
+
+// CreateProject creates a project directory on the server and registers it.
+func (c *Client) CreateProject(ctx context.Context, req CreateProjectRequest) (*CreatedProject, error) {
+	var resp struct {
+		Project CreatedProject `json:"project"`
+	}
+	// Creating the GitHub repo shells out to git and gh
+	r := &request{method: http.MethodPost, path: "/api/projects", body: req, timeout: 2 * time.Minute}
+	if err := c.do(ctx, r, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Project, nil
+}
+
+// OpenFolder registers an existing directory on the server as a project.
+func (c *Client) OpenFolder(ctx context.Context, folderPath string) (*Project, error) {
+	var resp struct {
+		Project Project `json:"project"`
+	}
+	body := map[string]string{"folderPath": folderPath}
+	if err := c.post(ctx, "/api/projects/open-folder", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Project, nil
+}
+
+// RemoveProject removes a project from the list. Files are not deleted.
+func (c *Client) RemoveProject(ctx context.Context, projectID string) error {
+	return c.delete(ctx, "/api/projects/"+url.PathEscape(projectID), nil, nil)
+}
+
+// ListProjectChats returns the project's tmux chat windows.
+func (c *Client) ListProjectChats(ctx context.Context, projectID string) ([]ProjectChat, error) {
+	var resp struct {
+		Chats []ProjectChat `json:"chats"`
+	}
+	if err := c.get(ctx, "/api/projects/"+url.PathEscape(projectID)+"/conversations", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Chats, nil
+}
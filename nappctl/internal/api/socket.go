@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/ws"
+)
+
+// Socket message types common to all features.
+const (
+	MsgConnection = "connection"
+	MsgAuth       = "auth"
+	MsgError      = "error"
+	MsgPing       = "ping"
+	MsgPong       = "pong"
+)
+
+// Message is a message received on a Socket. Decode unmarshals it into a
+// type-specific struct.
+type Message struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+// Decode unmarshals the message into v.
+func (m *Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Raw, v)
+}
+
+// ErrorMessage is the body of error messages.
+type ErrorMessage struct {
+	Message string `json:"message"`
+}
+
+// Socket is an authenticated WebSocket connection to the server, used for
+// terminals and chat sessions. Receive must be called from a single
+// goroutine; Send is safe for concurrent use.
+type Socket struct {
+	conn     *ws.Conn
+	clientID string
+}
+
+// Connect opens a WebSocket to the server and authenticates with the
+// client's token. A rejected token is returned as a 401 *Error. ctx bounds
+// connecting and authenticating only.
+func (c *Client) Connect(ctx context.Context) (*Socket, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	conn, err := ws.Dial(ctx, socketURL(c.baseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", c.baseURL, err)
+	}
+	s := &Socket{conn: conn}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	err = s.authenticate(c.token)
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// socketURL turns the server's http(s) URL into its ws(s) URL.
+func socketURL(baseURL string) string {
+	switch {
+	case strings.HasPrefix(baseURL, "https://"):
+		return "wss://" + strings.TrimPrefix(baseURL, "https://")
+	case strings.HasPrefix(baseURL, "http://"):
+		return "ws://" + strings.TrimPrefix(baseURL, "http://")
+	}
+	return baseURL
+}
+
+func (s *Socket) authenticate(token string) error {
+	msg, err := s.Receive()
+	if err != nil {
+		return err
+	}
+	if msg.Type == MsgConnection {
+		var hello struct {
+			ClientID string `json:"clientId"`
+		}
+		msg.Decode(&hello)
+		s.clientID = hello.ClientID
+	}
+
+	if err := s.Send(map[string]string{"type": MsgAuth, "token": token}); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := s.Receive()
+		if err != nil {
+			return err
+		}
+		if msg.Type != MsgAuth {
+			continue
+		}
+		var result struct {
+			Success bool   `json:"success"`
+			Message string `json:"message"`
+		}
+		if err := msg.Decode(&result); err != nil {
+			return err
+		}
+		if !result.Success {
+			return &Error{Method: "WS", Path: "auth", StatusCode: http.StatusUnauthorized, Message: result.Message}
+		}
+		return nil
+	}
+}
+
+// ClientID returns the ID the server assigned to the connection.
+func (s *Socket) ClientID() string {
+	return s.clientID
+}
+
+// Send sends v as a JSON message.
+func (s *Socket) Send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.conn.WriteMessage(ws.TextMessage, data)
+}
+
+// Receive waits for the next message.
+func (s *Socket) Receive() (*Message, error) {
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("invalid message from server: %w", err)
+	}
+	return &Message{Type: head.Type, Raw: data}, nil
+}
+
+// Close closes the connection.
+func (s *Socket) Close() error {
+	return s.conn.Close()
+}
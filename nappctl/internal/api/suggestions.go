@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Suggestion is an autocomplete entry for @ and / triggers in chats: a
+// rule, agent, command, skill or file.
+type Suggestion struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Path        string `json:"path,omitempty"`
+	// Scope is project or user for agents and commands.
+	Scope  string `json:"scope,omitempty"`
+	Source string `json:"source,omitempty"`
+
+	// Rules
+	AlwaysApply bool   `json:"alwaysApply,omitempty"`
+	Globs       string `json:"globs,omitempty"`
+
+	// Agents
+	Model    string `json:"model,omitempty"`
+	Readonly bool   `json:"readonly,omitempty"`
+
+	// Files
+	RelativePath string `json:"relativePath,omitempty"`
+}
+
+// GetSuggestions returns a project's suggestions of the given types (rules,
+// agents, commands, skills, files; none for all) matching query.
+func (c *Client) GetSuggestions(ctx context.Context, projectID, query string, types ...string) ([]Suggestion, error) {
+	values := url.Values{}
+	if query != "" {
+		values.Set("query", query)
+	}
+	if len(types) > 0 {
+		values.Set("type", strings.Join(types, ","))
+	}
+	return c.getSuggestions(ctx, "/api/suggestions/"+url.PathEscape(projectID), values)
+}
+
+// GetRules returns the project's rules.
+func (c *Client) GetRules(ctx context.Context, projectID string) ([]Suggestion, error) {
+	return c.getSuggestions(ctx, "/api/suggestions/"+url.PathEscape(projectID)+"/rules", nil)
+}
+
+// GetAgents returns the project and user agents.
+func (c *Client) GetAgents(ctx context.Context, projectID string) ([]Suggestion, error) {
+	return c.getSuggestions(ctx, "/api/suggestions/"+url.PathEscape(projectID)+"/agents", nil)
+}
+
+// GetCommands returns the project and user slash commands.
+func (c *Client) GetCommands(ctx context.Context, projectID string) ([]Suggestion, error) {
+	return c.getSuggestions(ctx, "/api/suggestions/"+url.PathEscape(projectID)+"/commands", nil)
+}
+
+// GetSkills returns the user's skills.
+//
+// The server registers this route after /:projectId, which captures it and
+// answers 404 "Project not found"; GetSuggestions with type "skills" works
+// for any project.
+func (c *Client) GetSkills(ctx context.Context) ([]Suggestion, error) {
+	return c.getSuggestions(ctx, "/api/suggestions/skills", nil)
+}
+
+// ClearSuggestionsCache drops the server's cached suggestions.
+func (c *Client) ClearSuggestionsCache(ctx context.Context) error {
+	return c.post(ctx, "/api/suggestions/clear-cache", nil, nil, nil)
+}
+
+func (c *Client) getSuggestions(ctx context.Context, path string, query url.Values) ([]Suggestion, error) {
+	var resp struct {
+		Suggestions []Suggestion `json:"suggestions"`
+	}
+	if err := c.get(ctx, path, query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Suggestions, nil
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// SystemInfo describes the server host.
+type SystemInfo struct {
+	Hostname string `json:"hostname"`
+	Platform string `json:"platform"`
+	Arch     string `json:"arch"`
+	CPUs     int    `json:"cpus"`
+	Memory   struct {
+		Total int64 `json:"total"`
+		Free  int64 `json:"free"`
+		Used  int64 `json:"used"`
+	} `json:"memory"`
+	// Uptime is the host uptime in seconds.
+	Uptime   float64 `json:"uptime"`
+	HomeDir  string  `json:"homeDir"`
+	Username string  `json:"username"`
+}
+
+// NetworkAddress is a non-internal IPv4 address of the server host.
+type NetworkAddress struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Netmask string `json:"netmask"`
+}
+
+// Model is an AI model offered for chats.
+type Model struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	IsDefault bool   `json:"isDefault"`
+	IsCurrent bool   `json:"isCurrent"`
+	IsBedrock bool   `json:"isBedrock,omitempty"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+// ModelList is the result of ListModels.
+type ModelList struct {
+	Models      []Model `json:"models"`
+	UsesBedrock bool    `json:"usesBedrock"`
+	// Source is bedrock+api, api or fallback.
+	Source string `json:"source"`
+}
+
+// IOSBuildRequest is the body of POST /api/system/ios-build-run.
+type IOSBuildRequest struct {
+	Configuration    string `json:"configuration,omitempty"`
+	DeviceName       string `json:"deviceName,omitempty"`
+	DeviceID         string `json:"deviceId,omitempty"`
+	IsPhysicalDevice bool   `json:"isPhysicalDevice,omitempty"`
+	Clean            bool   `json:"clean,omitempty"`
+}
+
+// IOSBuildResult is the result of a successful IOSBuildRun.
+type IOSBuildResult struct {
+	Message          string `json:"message"`
+	Configuration    string `json:"configuration"`
+	DeviceName       string `json:"deviceName"`
+	IsPhysicalDevice bool   `json:"isPhysicalDevice"`
+}
+
+// IOSDevice is a simulator or connected device.
+type IOSDevice struct {
+	Name             string `json:"name"`
+	UDID             string `json:"udid"`
+	State            string `json:"state"`
+	IOSVersion       string `json:"iosVersion,omitempty"`
+	IsBooted         bool   `json:"isBooted"`
+	IsPhysicalDevice bool   `json:"isPhysicalDevice"`
+	DeviceType       string `json:"deviceType,omitempty"`
+	ConnectionType   string `json:"connectionType,omitempty"`
+}
+
+// ExecResult is the output of Exec.
+type ExecResult struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+// TailscalePeer is an online peer on the server's tailnet.
+type TailscalePeer struct {
+	Hostname string `json:"hostname"`
+	DNSName  string `json:"dnsName"`
+	IP       string `json:"ip"`
+	OS       string `json:"os"`
+	Online   bool   `json:"online"`
+	Active   bool   `json:"active"`
+}
+
+// TailscaleStatus describes the server's Tailscale connection.
+type TailscaleStatus struct {
+	Available        bool            `json:"available"`
+	Connected        bool            `json:"connected"`
+	Message          string          `json:"message,omitempty"`
+	IP               string          `json:"ip,omitempty"`
+	IPv6             string          `json:"ipv6,omitempty"`
+	Hostname         string          `json:"hostname,omitempty"`
+	MagicDNSHostname string          `json:"magicDNSHostname,omitempty"`
+	TailnetName      string          `json:"tailnetName,omitempty"`
+	ConnectionURL    string          `json:"connectionUrl,omitempty"`
+	MagicDNSURL      string          `json:"magicDNSUrl,omitempty"`
+	Port             int             `json:"port,omitempty"`
+	Peers            []TailscalePeer `json:"peers,omitempty"`
+	BackendState     string          `json:"backendState,omitempty"`
+	MagicDNSEnabled  bool            `json:"magicDNSEnabled,omitempty"`
+}
+
+// GetSystemInfo retrieves system information from the server
+func (c *Client) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
+	var info SystemInfo
+	if err := c.get(ctx, "/api/system/info", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetNetworkAddresses returns the server host's external IPv4 addresses.
+func (c *Client) GetNetworkAddresses(ctx context.Context) ([]NetworkAddress, error) {
+	var resp struct {
+		Addresses []NetworkAddress `json:"addresses"`
+	}
+	if err := c.get(ctx, "/api/system/network", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Addresses, nil
+}
+
+// GetToolsStatus returns the availability of each AI CLI by name.
+func (c *Client) GetToolsStatus(ctx context.Context) (map[string]ToolAvailability, error) {
+	var resp struct {
+		Tools map[string]ToolAvailability `json:"tools"`
+	}
+	if err := c.get(ctx, "/api/system/tools-status", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tools, nil
+}
+
+// ListModels returns the AI models available for chats.
+func (c *Client) ListModels(ctx context.Context) (*ModelList, error) {
+	var list ModelList
+	if err := c.get(ctx, "/api/system/models", nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// IOSBuildRun builds the iOS app with Xcode and runs it on a simulator or
+// device. It only works on macOS servers and can take several minutes.
+func (c *Client) IOSBuildRun(ctx context.Context, req IOSBuildRequest) (*IOSBuildResult, error) {
+	var result IOSBuildResult
+	r := &request{method: http.MethodPost, path: "/api/system/ios-build-run", body: req, timeout: 10 * time.Minute}
+	if err := c.do(ctx, r, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListIOSDevices returns simulators and connected devices (macOS only).
+func (c *Client) ListIOSDevices(ctx context.Context) ([]IOSDevice, error) {
+	var resp struct {
+		Devices []IOSDevice `json:"devices"`
+	}
+	r := &request{method: http.MethodGet, path: "/api/system/ios-devices", timeout: 2 * time.Minute}
+	if err := c.do(ctx, r, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Devices, nil
+}
+
+// ListIOSSimulators returns the available iOS simulators (macOS only).
+func (c *Client) ListIOSSimulators(ctx context.Context) ([]IOSDevice, error) {
+	var resp struct {
+		Simulators []IOSDevice `json:"simulators"`
+	}
+	r := &request{method: http.MethodGet, path: "/api/system/ios-simulators", timeout: time.Minute}
+	if err := c.do(ctx, r, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Simulators, nil
+}
+
+// Exec runs a shell command on the server (30s limit; some destructive
+// patterns are refused with a 403). A failing command is returned as an
+// *Error whose Message is the failure and whose Body carries stderr.
+func (c *Client) Exec(ctx context.Context, command, cwd string) (*ExecResult, error) {
+	body := map[string]string{"command": command}
+	if cwd != "" {
+		body["cwd"] = cwd
+	}
+	var result ExecResult
+	r := &request{method: http.MethodPost, path: "/api/system/exec", body: body, timeout: time.Minute}
+	if err := c.do(ctx, r, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RestartServer asks the server to restart itself after delay (whole
+// seconds; the server defaults to 2s when zero).
+func (c *Client) RestartServer(ctx context.Context, delay time.Duration) error {
+	body := map[string]int{}
+	if delay > 0 {
+		body["delay"] = int(delay.Seconds())
+	}
+	return c.post(ctx, "/api/system/restart", nil, body, nil)
+}
+
+// GetTailscaleStatus returns the server's Tailscale connection details.
+func (c *Client) GetTailscaleStatus(ctx context.Context) (*TailscaleStatus, error) {
+	var status TailscaleStatus
+	if err := c.get(ctx, "/api/system/tailscale", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
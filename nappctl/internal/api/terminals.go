@@ -0,0 +1,281 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Terminal sources.
+const (
+	TerminalSourcePTY  = "mobile-pty"
+	TerminalSourceTmux = "tmux"
+)
+
+// Terminal is a PTY (id "pty-N") or tmux window (id
+// "tmux-{session}:{window}") terminal.
+type Terminal struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Source      string `json:"source"`
+	PID         int    `json:"pid,omitempty"`
+	Shell       string `json:"shell,omitempty"`
+	Cwd         string `json:"cwd"`
+	Cols        int    `json:"cols"`
+	Rows        int    `json:"rows"`
+	CreatedAt   Millis `json:"createdAt"`
+	Active      bool   `json:"active"`
+	ExitCode    *int   `json:"exitCode,omitempty"`
+	ProjectPath string `json:"projectPath,omitempty"`
+	ProjectName string `json:"projectName,omitempty"`
+
+	// tmux only
+	SessionName string `json:"sessionName,omitempty"`
+	WindowIndex int    `json:"windowIndex,omitempty"`
+	WindowName  string `json:"windowName,omitempty"`
+	WindowCount int    `json:"windowCount,omitempty"`
+	// Attached reports whether the server has a PTY attached to the window.
+	Attached bool `json:"attached,omitempty"`
+}
+
+// IsTmux reports whether the terminal is a tmux window.
+func (t *Terminal) IsTmux() bool {
+	return t.Source == TerminalSourceTmux
+}
+
+// TerminalListOptions filters ListTerminals.
+type TerminalListOptions struct {
+	// ProjectPath is required to list tmux terminals.
+	ProjectPath string
+	// IncludeHistory includes exited PTY terminals.
+	IncludeHistory bool
+	// Source is mobile-pty, tmux or empty for both.
+	Source string
+}
+
+// CreateTerminalRequest is the body of POST /api/terminals.
+type CreateTerminalRequest struct {
+	// Type is pty (server default) or tmux.
+	Type  string `json:"type,omitempty"`
+	Cwd   string `json:"cwd,omitempty"`
+	Shell string `json:"shell,omitempty"`
+	Cols  int    `json:"cols,omitempty"`
+	Rows  int    `json:"rows,omitempty"`
+	// ProjectPath is required for tmux terminals.
+	ProjectPath string `json:"projectPath,omitempty"`
+	WindowName  string `json:"windowName,omitempty"`
+}
+
+// TmuxSession is a tmux session created by the server.
+type TmuxSession struct {
+	Name        string `json:"name"`
+	WindowCount int    `json:"windowCount"`
+	Attached    bool   `json:"attached"`
+	ProjectName string `json:"projectName"`
+}
+
+// TmuxStatus describes tmux on the server.
+type TmuxStatus struct {
+	Available    bool          `json:"available"`
+	Version      string        `json:"version,omitempty"`
+	SessionCount int           `json:"sessionCount"`
+	Sessions     []TmuxSession `json:"sessions"`
+}
+
+// TmuxWindow is a window of a tmux session.
+type TmuxWindow struct {
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	Active      bool   `json:"active"`
+	CurrentPath string `json:"currentPath,omitempty"`
+}
+
+// ListTerminals returns the server's terminals.
+func (c *Client) ListTerminals(ctx context.Context, opts TerminalListOptions) ([]Terminal, error) {
+	query := url.Values{}
+	if opts.ProjectPath != "" {
+		query.Set("projectPath", opts.ProjectPath)
+	}
+	if opts.IncludeHistory {
+		query.Set("includeHistory", "true")
+	}
+	if opts.Source != "" {
+		query.Set("source", opts.Source)
+	}
+	var resp struct {
+		Terminals []Terminal `json:"terminals"`
+	}
+	if err := c.get(ctx, "/api/terminals", query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Terminals, nil
+}
+
+// CreateTerminal spawns a PTY or creates a tmux window.
+func (c *Client) CreateTerminal(ctx context.Context, req CreateTerminalRequest) (*Terminal, error) {
+	var resp struct {
+		Terminal Terminal `json:"terminal"`
+	}
+	if err := c.post(ctx, "/api/terminals", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Terminal, nil
+}
+
+// GetTerminal returns a terminal. projectPath is required for tmux
+// terminals. For attached tmux windows the buffered output is returned as
+// well when includeContent is set.
+func (c *Client) GetTerminal(ctx context.Context, id, projectPath string, includeContent bool) (*Terminal, string, error) {
+	query := url.Values{"includeContent": {strconv.FormatBool(includeContent)}}
+	if projectPath != "" {
+		query.Set("projectPath", projectPath)
+	}
+	var resp struct {
+		Terminal Terminal `json:"terminal"`
+		Content  string   `json:"content"`
+	}
+	if err := c.get(ctx, "/api/terminals/"+url.PathEscape(id), query, &resp); err != nil {
+		return nil, "", err
+	}
+	return &resp.Terminal, resp.Content, nil
+}
+
+// WriteTerminal sends input to a terminal. tmux windows must be attached.
+func (c *Client) WriteTerminal(ctx context.Context, id, data string) error {
+	return c.post(ctx, "/api/terminals/"+url.PathEscape(id)+"/input", nil, map[string]string{"data": data}, nil)
+}
+
+// ResizeTerminal resizes a terminal. tmux windows must be attached.
+func (c *Client) ResizeTerminal(ctx context.Context, id string, cols, rows int) error {
+	body := map[string]int{"cols": cols, "rows": rows}
+	return c.post(ctx, "/api/terminals/"+url.PathEscape(id)+"/resize", nil, body, nil)
+}
+
+// GetTerminalScrollback returns a terminal's scrollback: the last lines of
+// a tmux window's history (lines <= 0 uses the server default of 2000), or
+// a PTY's buffered output.
+func (c *Client) GetTerminalScrollback(ctx context.Context, id string, lines int) (string, error) {
+	query := url.Values{}
+	if lines > 0 {
+		query.Set("lines", strconv.Itoa(lines))
+	}
+	var resp struct {
+		Content string `json:"content"`
+	}
+	if err := c.get(ctx, "/api/terminals/"+url.PathEscape(id)+"/scrollback", query, &resp); err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// KillTerminal kills a PTY or tmux window.
+func (c *Client) KillTerminal(ctx context.Context, id string) error {
+	return c.delete(ctx, "/api/terminals/"+url.PathEscape(id), nil, nil)
+}
+
+// GetTmuxStatus reports tmux availability and the server's sessions.
+func (c *Client) GetTmuxStatus(ctx context.Context) (*TmuxStatus, error) {
+	var status TmuxStatus
+	if err := c.get(ctx, "/api/terminals/tmux/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListTmuxWindows returns the windows of a tmux session.
+func (c *Client) ListTmuxWindows(ctx context.Context, sessionName string) ([]TmuxWindow, error) {
+	var resp struct {
+		Windows []TmuxWindow `json:"windows"`
+	}
+	if err := c.get(ctx, "/api/terminals/tmux/sessions/"+url.PathEscape(sessionName)+"/windows", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Windows, nil
+}
+
+// KillTmuxSession kills a tmux session and all of its windows.
+func (c *Client) KillTmuxSession(ctx context.Context, sessionName string) error {
+	return c.delete(ctx, "/api/terminals/tmux/sessions/"+url.PathEscape(sessionName), nil, nil)
+}
+
+// Terminal socket message types.
+const (
+	MsgTerminalCreate   = "terminalCreate"
+	MsgTerminalCreated  = "terminalCreated"
+	MsgTerminalAttach   = "terminalAttach"
+	MsgTerminalAttached = "terminalAttached"
+	MsgTerminalDetach   = "terminalDetach"
+	MsgTerminalInput    = "terminalInput"
+	MsgTerminalResize   = "terminalResize"
+	MsgTerminalKill     = "terminalKill"
+	MsgTerminalKilled   = "terminalKilled"
+	MsgTerminalData     = "terminalData"
+	MsgTerminalClosed   = "terminalClosed"
+	MsgTerminalError    = "terminalError"
+)
+
+// TerminalMessage is the body of the terminal* messages the server sends.
+// Data is set for terminalData, Message for terminalError and Terminal for
+// terminalCreated and terminalAttached.
+type TerminalMessage struct {
+	Type       string    `json:"type"`
+	TerminalID string    `json:"terminalId"`
+	Data       string    `json:"data,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Terminal   *Terminal `json:"terminal,omitempty"`
+	ReadOnly   bool      `json:"readOnly,omitempty"`
+}
+
+// TerminalCreate asks the server to create a terminal; it answers with
+// terminalCreated or terminalError.
+func (s *Socket) TerminalCreate(req CreateTerminalRequest) error {
+	msg := map[string]interface{}{"type": MsgTerminalCreate, "terminalType": req.Type}
+	if req.Cwd != "" {
+		msg["cwd"] = req.Cwd
+	}
+	if req.Shell != "" {
+		msg["shell"] = req.Shell
+	}
+	if req.Cols > 0 && req.Rows > 0 {
+		msg["cols"], msg["rows"] = req.Cols, req.Rows
+	}
+	if req.ProjectPath != "" {
+		msg["projectPath"] = req.ProjectPath
+	}
+	if req.WindowName != "" {
+		msg["windowName"] = req.WindowName
+	}
+	return s.Send(msg)
+}
+
+// TerminalAttach subscribes to a terminal's output. projectPath is required
+// for tmux windows. The server answers with terminalAttached, followed by
+// the buffered output as terminalData, or terminalError.
+func (s *Socket) TerminalAttach(id, projectPath string) error {
+	msg := map[string]string{"type": MsgTerminalAttach, "terminalId": id}
+	if projectPath != "" {
+		msg["projectPath"] = projectPath
+	}
+	return s.Send(msg)
+}
+
+// TerminalDetach unsubscribes from a terminal. The terminal keeps running.
+func (s *Socket) TerminalDetach(id string) error {
+	return s.Send(map[string]string{"type": MsgTerminalDetach, "terminalId": id})
+}
+
+// TerminalInput sends input to an attached terminal.
+func (s *Socket) TerminalInput(id, data string) error {
+	return s.Send(map[string]string{"type": MsgTerminalInput, "terminalId": id, "data": data})
+}
+
+// TerminalResize resizes an attached terminal.
+func (s *Socket) TerminalResize(id string, cols, rows int) error {
+	return s.Send(map[string]interface{}{"type": MsgTerminalResize, "terminalId": id, "cols": cols, "rows": rows})
+}
+
+// TerminalKill kills a terminal; subscribers get terminalClosed and the
+// caller terminalKilled.
+func (s *Socket) TerminalKill(id string) error {
+	return s.Send(map[string]string{"type": MsgTerminalKill, "terminalId": id})
+}
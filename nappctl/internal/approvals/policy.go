@@ -0,0 +1,284 @@
+package approvals
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy actions.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+	Ask   = "ask"
+)
+
+// DefaultPolicy is used when no policy file exists: reads inside the
+// project are allowed, recursive forced deletes are denied and everything
+// else is asked.
+const DefaultPolicy = `# nappctl approvals policy. Rules are checked in order and the first one
+# that matches a tool call decides it; calls no rule matches get the
+# default. Actions: allow, deny or ask.
+default: ask
+rules:
+  - name: deny-rm-rf
+    action: deny
+    tools: [Bash]
+    # Regular expressions matched against the command; any may match.
+    # rm -rf and rm -fr, then separate flags in either order: rm -r -f,
+    # rm -f -r, rm --recursive --force.
+    commands:
+      - '\brm(\s+-\S+)*\s+-[a-zA-Z]*([rR][a-zA-Z]*f|f[a-zA-Z]*[rR])'
+      - '\brm(\s+-\S+)*\s+(-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)(\s+-\S+)*\s+(-[a-zA-Z]*f[a-zA-Z]*|--force)\b'
+      - '\brm(\s+-\S+)*\s+(-[a-zA-Z]*f[a-zA-Z]*|--force)(\s+-\S+)*\s+(-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\b'
+  - name: allow-project-reads
+    action: allow
+    tools: [Read, Glob, Grep, LS, NotebookRead]
+    # The call's file_path, path or notebook_path must be in the project
+    within_project: true
+`
+
+// Policy decides tool calls awaiting approval.
+type Policy struct {
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Rule matches tool calls by tool name, command and path. All of the
+// conditions given must match; a rule without conditions matches every
+// call.
+type Rule struct {
+	Name   string `yaml:"name"`
+	Action string `yaml:"action"`
+	// Tools are tool names or glob patterns such as mcp__*.
+	Tools []string `yaml:"tools"`
+	// Commands are regular expressions matched against a command input.
+	Commands []string `yaml:"commands"`
+	// Paths are glob patterns matched against a path input and its parent
+	// directories; patterns without a slash match the base name.
+	Paths []string `yaml:"paths"`
+	// WithinProject requires a path input inside the chat's project.
+	WithinProject bool `yaml:"within_project"`
+
+	commands []*regexp.Regexp
+}
+
+// Request is a tool call to decide.
+type Request struct {
+	ToolName    string
+	Input       map[string]interface{}
+	ProjectPath string
+}
+
+// Decision is the outcome of evaluating a Request.
+type Decision struct {
+	Action string
+	// Rule names the rule that matched, or is "default".
+	Rule string
+}
+
+// pathKeys are the tool inputs that name a file or directory.
+var pathKeys = []string{"file_path", "path", "notebook_path"}
+
+// Load reads and validates a policy file.
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return policy, nil
+}
+
+// Default returns the parsed DefaultPolicy.
+func Default() *Policy {
+	policy, err := Parse([]byte(DefaultPolicy))
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+// Parse parses and validates a YAML policy.
+func Parse(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	if policy.Default == "" {
+		policy.Default = Ask
+	}
+	if !validAction(policy.Default) {
+		return nil, fmt.Errorf("invalid default action %q (use allow, deny or ask)", policy.Default)
+	}
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if !validAction(rule.Action) {
+			return nil, fmt.Errorf("%s: invalid action %q (use allow, deny or ask)", rule.Name, rule.Action)
+		}
+		for _, pattern := range append(append([]string{}, rule.Tools...), rule.Paths...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q", rule.Name, pattern)
+			}
+		}
+		for _, expr := range rule.Commands {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid command expression: %w", rule.Name, err)
+			}
+			rule.commands = append(rule.commands, re)
+		}
+	}
+	return &policy, nil
+}
+
+func validAction(action string) bool {
+	return action == Allow || action == Deny || action == Ask
+}
+
+// Evaluate returns the decision of the first rule matching req, or the
+// default.
+func (p *Policy) Evaluate(req Request) Decision {
+	for i := range p.Rules {
+		if p.Rules[i].matches(req) {
+			return Decision{Action: p.Rules[i].Action, Rule: p.Rules[i].Name}
+		}
+	}
+	return Decision{Action: p.Default, Rule: "default"}
+}
+
+func (r *Rule) matches(req Request) bool {
+	if len(r.Tools) > 0 && !matchAny(r.Tools, req.ToolName) {
+		return false
+	}
+
+	if len(r.commands) > 0 {
+		command, _ := req.Input["command"].(string)
+		if command == "" || !matchRegexp(r.commands, command) {
+			return false
+		}
+	}
+
+	if len(r.Paths) > 0 || r.WithinProject {
+		file := InputPath(req.Input)
+		if file == "" {
+			return false
+		}
+		if !filepath.IsAbs(file) && req.ProjectPath != "" {
+			file = filepath.Join(req.ProjectPath, file)
+		}
+		file = filepath.Clean(file)
+
+		if r.WithinProject && !withinDir(file, req.ProjectPath) {
+			return false
+		}
+		if len(r.Paths) > 0 && !matchPath(r.Paths, file) {
+			return false
+		}
+	}
+	return true
+}
+
+// InputPath returns the file or directory a tool call's input names, if
+// any.
+func InputPath(input map[string]interface{}) string {
+	for _, key := range pathKeys {
+		if value, ok := input[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRegexp(exprs []*regexp.Regexp, s string) bool {
+	for _, re := range exprs {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches file and its parent directories against the patterns.
+func matchPath(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, filepath.Base(file)); ok {
+				return true
+			}
+			continue
+		}
+		pattern = filepath.Clean(pattern)
+		for dir := file; ; dir = filepath.Dir(dir) {
+			if ok, _ := path.Match(pattern, dir); ok {
+				return true
+			}
+			if parent := filepath.Dir(dir); parent == dir {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// withinDir reports whether file is dir or inside it once symbolic links
+// are resolved, so that a link in dir to a file elsewhere is not inside.
+// A path that cannot be resolved is not inside.
+func withinDir(file, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	file, err := resolvePath(file)
+	if err != nil {
+		return false
+	}
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, file)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath resolves the symbolic links in file. A file that does not
+// exist yet, such as one a tool call is about to create, resolves within
+// its resolved parent directory; a dangling link does not resolve.
+func resolvePath(file string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(file)
+	if err == nil || !os.IsNotExist(err) {
+		return resolved, err
+	}
+	if _, lerr := os.Lstat(file); !os.IsNotExist(lerr) {
+		return "", err
+	}
+	parent := filepath.Dir(file)
+	if parent == file {
+		return "", err
+	}
+	dir, err := resolvePath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(file)), nil
+}
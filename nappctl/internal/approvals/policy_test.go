@@ -0,0 +1,149 @@
+package approvals
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func bash(command string) Request {
+	return Request{ToolName: "Bash", Input: map[string]interface{}{"command": command}}
+}
+
+func TestDefaultPolicyDeniesRecursiveForcedDeletes(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"rm -rf /", Deny},
+		{"rm -fr build", Deny},
+		{"rm -Rf build", Deny},
+		{"rm -rfv build", Deny},
+		{"rm -rf", Deny},
+		{"rm -r -f build", Deny},
+		{"rm -f -r build", Deny},
+		{"rm -R -f build", Deny},
+		{"rm -v -r -i -f build", Deny},
+		{"rm --recursive --force build", Deny},
+		{"rm --force --recursive build", Deny},
+		{"rm -r --force build", Deny},
+		{"rm --recursive -f build", Deny},
+		{"rm --no-preserve-root -r -f /", Deny},
+		{"sudo rm -r -f /", Deny},
+		{"cd /tmp && rm -f -r build", Deny},
+
+		{"rm file", Ask},
+		{"rm -f file", Ask},
+		{"rm --force file", Ask},
+		{"rm -r build", Ask},
+		{"rm -ri build", Ask},
+		{"rm --recursive build", Ask},
+		{"rm -r build && ls -f", Ask},
+		{"perform -r -f", Ask},
+		{"echo rm", Ask},
+	}
+	policy := Default()
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := policy.Evaluate(bash(tt.command)); got.Action != tt.want {
+				t.Errorf("Evaluate(%q) = %s by %s, want %s", tt.command, got.Action, got.Rule, tt.want)
+			}
+		})
+	}
+
+	other := Request{ToolName: "Task", Input: map[string]interface{}{"command": "rm -rf /"}}
+	if got := policy.Evaluate(other); got.Action != Ask {
+		t.Errorf("deny-rm-rf matched a %s call", other.ToolName)
+	}
+}
+
+func TestParseRejectsInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name, policy, want string
+	}{
+		{"not YAML", "rules: [", "invalid policy"},
+		{"default action", "default: maybe", `invalid default action "maybe"`},
+		{"rule action", "rules:\n  - name: r\n    action: block", `r: invalid action "block"`},
+		{"unnamed rule", "rules:\n  - action: allow\n  - action: nope", `rule 2: invalid action "nope"`},
+		{"command expression", "rules:\n  - action: deny\n    commands: ['rm (']", "invalid command expression"},
+		{"tool pattern", "rules:\n  - action: deny\n    tools: ['[']", `invalid pattern "["`},
+		{"path pattern", "rules:\n  - action: deny\n    paths: ['a/[']", `invalid pattern "a/["`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.policy)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	policy, err := Parse([]byte("rules: []"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Default != Ask {
+		t.Errorf("default action = %q, want ask", policy.Default)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	project := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(project, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := Parse([]byte(`default: deny
+rules:
+  - name: ask-env
+    action: ask
+    paths: ['.env*', '/etc/*']
+  - name: allow-project-reads
+    action: allow
+    tools: [Read, Grep]
+    within_project: true
+  - name: allow-mcp
+    action: allow
+    tools: ['mcp__*']
+  - name: allow-git-status
+    action: allow
+    tools: [Bash]
+    commands: ['^git status\b']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(path string) Request {
+		return Request{ToolName: "Read", Input: map[string]interface{}{"file_path": path}, ProjectPath: project}
+	}
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"read in the project", read(filepath.Join(project, "main.go")), "allow-project-reads"},
+		{"relative to the project", read("src/main.go"), "allow-project-reads"},
+		{"a file about to be created", read(filepath.Join(project, "new", "file.go")), "allow-project-reads"},
+		{"escaping the project", read(filepath.Join(project, "..", "other")), "default"},
+		{"link out of the project", read(filepath.Join(project, "link")), "default"},
+		{"earlier rule wins", read(filepath.Join(project, ".env.local")), "ask-env"},
+		{"parent directory pattern", read("/etc/ssh/sshd_config"), "ask-env"},
+		{"no path", Request{ToolName: "Grep", Input: map[string]interface{}{"pattern": "x"}, ProjectPath: project}, "default"},
+		{"tool glob", Request{ToolName: "mcp__github__search"}, "allow-mcp"},
+		{"command", bash("git status --short"), "allow-git-status"},
+		{"command anchored", bash("echo; git status"), "default"},
+		{"no command", Request{ToolName: "Bash", Input: map[string]interface{}{}}, "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Evaluate(tt.req); got.Rule != tt.want {
+				t.Errorf("Evaluate = %s by %s, want %s", got.Action, got.Rule, tt.want)
+			}
+		})
+	}
+}
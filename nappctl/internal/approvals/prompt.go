@@ -0,0 +1,86 @@
+package approvals
+
+import (
+	"context"
+	"errors"
+	"html"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoPrompt is returned by Prompt when no desktop dialog is available.
+var ErrNoPrompt = errors.New("no desktop dialog available")
+
+// dialogTool returns the program Prompt uses: osascript on macOS, zenity or
+// kdialog on Linux with a display. It is empty when there is none.
+func dialogTool() string {
+	var candidates []string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = []string{"osascript"}
+	case "linux":
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return ""
+		}
+		candidates = []string{"zenity", "kdialog"}
+	}
+	for _, name := range candidates {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// PromptAvailable reports whether Prompt can show a dialog.
+func PromptAvailable() bool {
+	return dialogTool() != ""
+}
+
+// Prompt shows a desktop dialog asking to allow or deny a tool call and
+// reports whether it was allowed. Cancelling ctx closes the dialog.
+func Prompt(ctx context.Context, title, message string) (bool, error) {
+	var cmd *exec.Cmd
+	switch dialogTool() {
+	case "osascript":
+		script := `display dialog "` + appleScriptEscape(message) + `" with title "` + appleScriptEscape(title) +
+			`" buttons {"Deny", "Allow"} default button "Deny" with icon caution`
+		out, err := exec.CommandContext(ctx, "osascript", "-e", script).Output()
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if err != nil {
+			return false, err
+		}
+		return strings.Contains(string(out), "button returned:Allow"), nil
+	case "zenity":
+		// zenity renders Pango markup
+		cmd = exec.CommandContext(ctx, "zenity", "--question", "--title", title, "--text", html.EscapeString(message),
+			"--ok-label", "Allow", "--cancel-label", "Deny", "--default-cancel")
+	case "kdialog":
+		cmd = exec.CommandContext(ctx, "kdialog", "--title", title, "--yesno", message,
+			"--yes-label", "Allow", "--no-label", "Deny")
+	default:
+		return false, ErrNoPrompt
+	}
+
+	// zenity and kdialog exit with 1 for Deny
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return false, nil
+	}
+	return false, err
+}
+
+func appleScriptEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,408 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// Secret store backends for the master token.
+const (
+	// BackendFile keeps the master token in auth.json, where the server
+	// reads it. The file is only protected by its permissions.
+	BackendFile = "file"
+	// BackendEncrypted keeps the master token in an age file encrypted with
+	// a passphrase (scrypt) beside auth.json.
+	BackendEncrypted = "encrypted"
+	// BackendKeyring keeps the master token in the OS keyring: the Secret
+	// Service over D-Bus on Linux.
+	BackendKeyring = "keyring"
+)
+
+// Backends lists the secret store backends.
+var Backends = []string{BackendFile, BackendEncrypted, BackendKeyring}
+
+// PassphraseEnv is the environment variable the encrypted backend reads its
+// passphrase from before prompting for it.
+const PassphraseEnv = "NAPPTRAPP_AUTH_PASSPHRASE"
+
+// ErrIncorrectPassphrase is returned when the encrypted master token
+// cannot be decrypted with the given passphrase.
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase for the encrypted master token")
+
+// keyringService is the service name of the keyring entry. Its user is the
+// auth.json path, so that each data directory has its own entry.
+const keyringService = "napptrapp"
+
+// SecretStore holds the master token.
+type SecretStore interface {
+	// Backend returns the name of the backend, one of Backends.
+	Backend() string
+	// Location describes where the token is kept.
+	Location() string
+	// Get returns the stored token, or "" if there is none.
+	Get() (string, error)
+	// Set stores the token, replacing any previous one.
+	Set(token string) error
+	// Delete removes the token. Deleting a missing token is not an error.
+	Delete() error
+}
+
+// ValidateBackend checks that backend names a secret store backend.
+func ValidateBackend(backend string) error {
+	for _, b := range Backends {
+		if b == backend {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown secret store %q (use %s)", backend, strings.Join(Backends, ", "))
+}
+
+// OpenSecretStore returns the secret store of the given backend for the
+// auth file at authPath. An empty backend is the file backend.
+func OpenSecretStore(backend, authPath string) (SecretStore, error) {
+	switch backend {
+	case "", BackendFile:
+		return &fileStore{authPath: authPath}, nil
+	case BackendEncrypted:
+		return &encryptedStore{path: EncryptedTokenPath(authPath)}, nil
+	case BackendKeyring:
+		return &keyringStore{user: authPath}, nil
+	}
+	return nil, ValidateBackend(backend)
+}
+
+// EncryptedTokenPath returns the path of the encrypted backend's file for
+// the auth file at authPath.
+func EncryptedTokenPath(authPath string) string {
+	return filepath.Join(filepath.Dir(authPath), "auth-token.age")
+}
+
+// CurrentBackend returns the backend holding the master token of the auth
+// file. A missing auth file uses the file backend.
+func CurrentBackend(authPath string) (string, error) {
+	authData, err := ReadAuthFile(authPath)
+	if err != nil {
+		return "", err
+	}
+	if authData == nil {
+		return BackendFile, nil
+	}
+	return authData.backend(), nil
+}
+
+// MigrateSecretStore moves the master token to the given backend, keeping
+// the token, and returns the backend it was moved from.
+func MigrateSecretStore(authPath, backend string) (string, error) {
+	from, err := CurrentBackend(authPath)
+	if err != nil {
+		return "", err
+	}
+	if backend == "" {
+		backend = BackendFile
+	}
+	if from == backend {
+		return from, nil
+	}
+
+	token, err := GetToken(authPath)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("no master token found; generate one with: nappctl auth generate")
+	}
+	if err := saveToken(authPath, backend, token); err != nil {
+		return "", err
+	}
+	return from, nil
+}
+
+// ExternalToken returns the master token when it is kept outside auth.json,
+// where the server cannot read it and must be given it as AUTH_TOKEN. It
+// returns "" for the file backend.
+func ExternalToken(authPath string) (string, error) {
+	backend, err := CurrentBackend(authPath)
+	if err != nil || backend == BackendFile {
+		return "", err
+	}
+	return GetToken(authPath)
+}
+
+// saveToken stores token in the given backend and records the backend in
+// auth.json, removing the token from the backend that held it before.
+func saveToken(authPath, backend, token string) error {
+	from, err := CurrentBackend(authPath)
+	if err != nil {
+		return err
+	}
+	store, err := OpenSecretStore(backend, authPath)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Set(token); err != nil {
+		return err
+	}
+	if store.Backend() != BackendFile {
+		if err := setBackend(authPath, store.Backend()); err != nil {
+			return err
+		}
+	}
+
+	if from != store.Backend() {
+		previous, err := OpenSecretStore(from, authPath)
+		if err != nil {
+			return err
+		}
+		if err := previous.Delete(); err != nil {
+			return fmt.Errorf("token moved to %s, but removing it from %s failed: %w", store.Backend(), from, err)
+		}
+	}
+	return nil
+}
+
+// setBackend records a backend other than the file backend in auth.json,
+// which then holds no master token.
+func setBackend(authPath, backend string) error {
+	authData, err := ReadAuthFile(authPath)
+	if err != nil {
+		return err
+	}
+	if authData == nil {
+		authData = &AuthData{}
+	}
+
+	authData.MasterToken = ""
+	authData.SecretStore = backend
+	if err := WriteAuthFile(authPath, authData); err != nil {
+		return fmt.Errorf("failed to save auth file: %w", err)
+	}
+	return nil
+}
+
+// fileStore keeps the master token in auth.json.
+type fileStore struct {
+	authPath string
+}
+
+func (s *fileStore) Backend() string  { return BackendFile }
+func (s *fileStore) Location() string { return s.authPath }
+
+func (s *fileStore) Get() (string, error) {
+	authData, err := ReadAuthFile(s.authPath)
+	if err != nil || authData == nil {
+		return "", err
+	}
+	return authData.MasterToken, nil
+}
+
+func (s *fileStore) Set(token string) error {
+	authData, err := ReadAuthFile(s.authPath)
+	if err != nil {
+		return err
+	}
+	if authData == nil {
+		authData = &AuthData{}
+	}
+
+	authData.MasterToken = token
+	authData.SecretStore = ""
+	if err := WriteAuthFile(s.authPath, authData); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+	return nil
+}
+
+func (s *fileStore) Delete() error {
+	authData, err := ReadAuthFile(s.authPath)
+	if err != nil || authData == nil || authData.MasterToken == "" {
+		return err
+	}
+
+	authData.MasterToken = ""
+	if err := WriteAuthFile(s.authPath, authData); err != nil {
+		return fmt.Errorf("failed to save auth file: %w", err)
+	}
+	return nil
+}
+
+// encryptedStore keeps the master token in an age file encrypted to a
+// passphrase.
+type encryptedStore struct {
+	path string
+}
+
+func (s *encryptedStore) Backend() string  { return BackendEncrypted }
+func (s *encryptedStore) Location() string { return s.path }
+
+func (s *encryptedStore) Get() (string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read encrypted token: %w", err)
+	}
+
+	passphrase, err := readPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			cachedPassphrase = ""
+			return "", ErrIncorrectPassphrase
+		}
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	token, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(token), nil
+}
+
+func (s *encryptedStore) Set(token string) error {
+	// Re-encrypting with a mistyped passphrase would lock the token away,
+	// so an existing file must decrypt with it first
+	_, err := os.Stat(s.path)
+	exists := err == nil
+	if exists {
+		if _, err := s.Get(); err != nil {
+			return err
+		}
+	}
+
+	passphrase, err := readPassphrase(!exists)
+	if err != nil {
+		return err
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	if _, err := io.WriteString(w, token); err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write encrypted token: %w", err)
+	}
+	return nil
+}
+
+func (s *encryptedStore) Delete() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete encrypted token: %w", err)
+	}
+	return nil
+}
+
+// cachedPassphrase is the passphrase entered for the encrypted backend, so
+// a command that reads and writes the token asks for it once.
+var cachedPassphrase string
+
+// readPassphrase returns the encrypted backend's passphrase from
+// PassphraseEnv or, failing that, asks for it on the terminal; confirm
+// asks twice, for a new file.
+func readPassphrase(confirm bool) (string, error) {
+	if cachedPassphrase != "" {
+		return cachedPassphrase, nil
+	}
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		cachedPassphrase = passphrase
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("the master token is encrypted: set %s or run nappctl in a terminal", PassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase for the master token: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("passphrase is empty")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if !bytes.Equal(passphrase, again) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+
+	cachedPassphrase = string(passphrase)
+	return cachedPassphrase, nil
+}
+
+// keyringStore keeps the master token in the OS keyring.
+type keyringStore struct {
+	user string
+}
+
+func (s *keyringStore) Backend() string { return BackendKeyring }
+
+func (s *keyringStore) Location() string {
+	return fmt.Sprintf("keyring entry %s (%s)", keyringService, s.user)
+}
+
+func (s *keyringStore) Get() (string, error) {
+	token, err := keyring.Get(keyringService, s.user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read token from the keyring: %w", err)
+	}
+	return token, nil
+}
+
+func (s *keyringStore) Set(token string) error {
+	if err := keyring.Set(keyringService, s.user, token); err != nil {
+		return fmt.Errorf("failed to save token in the keyring: %w", err)
+	}
+	return nil
+}
+
+func (s *keyringStore) Delete() error {
+	err := keyring.Delete(keyringService, s.user)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to delete token from the keyring: %w", err)
+	}
+	return nil
+}
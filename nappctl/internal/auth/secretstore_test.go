@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// usePassphrase sets the encrypted backend's passphrase for the test,
+// forgetting one cached by an earlier call.
+func usePassphrase(t *testing.T, passphrase string) {
+	t.Helper()
+	t.Setenv(PassphraseEnv, passphrase)
+	cachedPassphrase = ""
+	t.Cleanup(func() { cachedPassphrase = "" })
+}
+
+func TestMigrateSecretStoreEncryptedRoundTrip(t *testing.T) {
+	usePassphrase(t, "correct horse")
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+	writeRaw(t, authPath, `{"masterToken": "the-token", "sessions": {"s": {}}, "lastSaved": 1}`)
+
+	from, err := MigrateSecretStore(authPath, BackendEncrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != BackendFile {
+		t.Errorf("migrated from %q, want %q", from, BackendFile)
+	}
+
+	authData, err := ReadAuthFile(authPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authData.MasterToken != "" || authData.SecretStore != BackendEncrypted {
+		t.Errorf("auth.json = masterToken %q, secretStore %q; want no token, %q",
+			authData.MasterToken, authData.SecretStore, BackendEncrypted)
+	}
+	if _, ok := authData.Sessions["s"]; !ok {
+		t.Error("session was dropped")
+	}
+	data, err := os.ReadFile(EncryptedTokenPath(authPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("the-token")) {
+		t.Error("encrypted file holds the token in the clear")
+	}
+
+	// Read back with the passphrase from the environment, not the cache
+	usePassphrase(t, "correct horse")
+	if token, err := GetToken(authPath); err != nil || token != "the-token" {
+		t.Fatalf("GetToken = %q, %v; want the-token", token, err)
+	}
+	if token, err := ExternalToken(authPath); err != nil || token != "the-token" {
+		t.Errorf("ExternalToken = %q, %v; want the-token", token, err)
+	}
+
+	from, err = MigrateSecretStore(authPath, BackendFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != BackendEncrypted {
+		t.Errorf("migrated from %q, want %q", from, BackendEncrypted)
+	}
+
+	file, raw := readServerFile(t, authPath)
+	if file.MasterToken != "the-token" {
+		t.Errorf("masterToken = %q, want the-token", file.MasterToken)
+	}
+	if _, ok := raw["secretStore"]; ok {
+		t.Error("auth.json still names the encrypted secret store")
+	}
+	if _, err := os.Stat(EncryptedTokenPath(authPath)); !os.IsNotExist(err) {
+		t.Errorf("encrypted file was not removed: %v", err)
+	}
+	if token, err := ExternalToken(authPath); err != nil || token != "" {
+		t.Errorf("ExternalToken = %q, %v; want none for the file backend", token, err)
+	}
+}
+
+func TestEncryptedStoreWrongPassphrase(t *testing.T) {
+	usePassphrase(t, "right")
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+	if _, err := CreateAndSaveToken(authPath, BackendEncrypted); err != nil {
+		t.Fatal(err)
+	}
+	agePath := EncryptedTokenPath(authPath)
+	before, err := os.ReadFile(agePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usePassphrase(t, "wrong")
+	if _, err := GetToken(authPath); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("GetToken error = %v, want ErrIncorrectPassphrase", err)
+	}
+	if cachedPassphrase != "" {
+		t.Error("the wrong passphrase stayed cached")
+	}
+
+	// Rotating must not re-encrypt the token with the wrong passphrase
+	usePassphrase(t, "wrong")
+	if _, err := RotateToken(authPath, BackendEncrypted); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("RotateToken error = %v, want ErrIncorrectPassphrase", err)
+	}
+	usePassphrase(t, "wrong")
+	if _, err := MigrateSecretStore(authPath, BackendFile); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("MigrateSecretStore error = %v, want ErrIncorrectPassphrase", err)
+	}
+
+	after, err := os.ReadFile(agePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("age file changed after using a wrong passphrase")
+	}
+	if backend, err := CurrentBackend(authPath); err != nil || backend != BackendEncrypted {
+		t.Errorf("CurrentBackend = %q, %v; want %q", backend, err, BackendEncrypted)
+	}
+}
+
+func TestDeleteAuthFileRemovesStoredToken(t *testing.T) {
+	usePassphrase(t, "passphrase")
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+	if _, err := CreateAndSaveToken(authPath, BackendEncrypted); err != nil {
+		t.Fatal(err)
+	}
+	agePath := EncryptedTokenPath(authPath)
+	if _, err := os.Stat(agePath); err != nil {
+		t.Fatalf("no encrypted token after CreateAndSaveToken: %v", err)
+	}
+
+	if err := DeleteAuthFile(authPath); err != nil {
+		t.Fatal(err)
+	}
+	if AuthFileExists(authPath) {
+		t.Error("auth.json was not removed")
+	}
+	if _, err := os.Stat(agePath); !os.IsNotExist(err) {
+		t.Errorf("encrypted token was not removed: %v", err)
+	}
+	if token, err := GetToken(authPath); err != nil || token != "" {
+		t.Errorf("GetToken after delete = %q, %v; want none", token, err)
+	}
+}
+
+func TestOpenSecretStore(t *testing.T) {
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+
+	for _, backend := range Backends {
+		store, err := OpenSecretStore(backend, authPath)
+		if err != nil {
+			t.Fatalf("OpenSecretStore(%q): %v", backend, err)
+		}
+		if store.Backend() != backend {
+			t.Errorf("OpenSecretStore(%q).Backend() = %q", backend, store.Backend())
+		}
+	}
+	if store, err := OpenSecretStore("", authPath); err != nil || store.Backend() != BackendFile {
+		t.Errorf("OpenSecretStore(\"\") = %v, %v; want the file backend", store, err)
+	}
+	if _, err := OpenSecretStore("vault", authPath); err == nil {
+		t.Error("OpenSecretStore accepted an unknown backend")
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrSessionNotFound is returned for an unknown session ID.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a paired device's session stored in auth.json. The server
+// keys sessions by their token; ID is derived from it so sessions can be
+// listed and revoked without revealing tokens. Times are Unix
+// milliseconds.
+type Session struct {
+	ID           string `json:"id"`
+	Device       string `json:"device,omitempty"`
+	UserAgent    string `json:"userAgent,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	CreatedAt    int64  `json:"createdAt"`
+	LastActivity int64  `json:"lastActivity"`
+}
+
+// SessionID returns the public ID of the session with the given token,
+// matching the server's.
+func SessionID(token string) string {
+	return HashToken(token)[:12]
+}
+
+// ListSessions returns the sessions in the auth file, most recently active
+// first.
+func ListSessions(authPath string) ([]Session, error) {
+	authData, err := ReadAuthFile(authPath)
+	if err != nil || authData == nil {
+		return nil, err
+	}
+	return authData.sessions()
+}
+
+// sessions returns the sessions in d, most recently active first.
+func (d *AuthData) sessions() ([]Session, error) {
+	sessions := make([]Session, 0, len(d.Sessions))
+	for token, raw := range d.Sessions {
+		var s Session
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("invalid session in auth file: %w", err)
+		}
+		s.ID = SessionID(token)
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastActivity > sessions[j].LastActivity })
+	return sessions, nil
+}
+
+// FindSession returns the session with the given ID, or the only one whose
+// ID starts with it.
+func FindSession(sessions []Session, id string) (*Session, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: empty ID", ErrSessionNotFound)
+	}
+	var match *Session
+	for i := range sessions {
+		if sessions[i].ID == id {
+			return &sessions[i], nil
+		}
+		if strings.HasPrefix(sessions[i].ID, id) {
+			if match != nil {
+				return nil, fmt.Errorf("session ID %q is ambiguous", id)
+			}
+			match = &sessions[i]
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	return match, nil
+}
+
+// RevokeSession removes the session with the given ID (or unique ID
+// prefix) from the auth file and returns it.
+func RevokeSession(authPath, id string) (*Session, error) {
+	authData, err := ReadAuthFile(authPath)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	if authData != nil {
+		if sessions, err = authData.sessions(); err != nil {
+			return nil, err
+		}
+	}
+	session, err := FindSession(sessions, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for token := range authData.Sessions {
+		if SessionID(token) == session.ID {
+			delete(authData.Sessions, token)
+		}
+	}
+	if err := WriteAuthFile(authPath, authData); err != nil {
+		return nil, fmt.Errorf("failed to save auth file: %w", err)
+	}
+	return session, nil
+}
+
+// RevokeAllSessions removes every session from the auth file and returns
+// how many there were.
+func RevokeAllSessions(authPath string) (int, error) {
+	authData, err := ReadAuthFile(authPath)
+	if err != nil || authData == nil {
+		return 0, err
+	}
+
+	count := len(authData.Sessions)
+	authData.Sessions = nil
+	if err := WriteAuthFile(authPath, authData); err != nil {
+		return 0, fmt.Errorf("failed to save auth file: %w", err)
+	}
+	return count, nil
+}
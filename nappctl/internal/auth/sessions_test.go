@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSessions writes an auth file holding a session per token, each
+// named after its token and last active at its index.
+func writeSessions(t *testing.T, tokens ...string) string {
+	t.Helper()
+	sessions := map[string]map[string]interface{}{}
+	for i, token := range tokens {
+		sessions[token] = map[string]interface{}{"device": "device " + token, "createdAt": 1, "lastActivity": i + 1}
+	}
+	data, err := json.Marshal(map[string]interface{}{"masterToken": "master-token", "sessions": sessions, "lastSaved": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+	writeRaw(t, authPath, string(data))
+	return authPath
+}
+
+// clashingTokens returns two tokens whose session IDs share their first
+// character.
+func clashingTokens() (string, string) {
+	seen := map[byte]string{}
+	for i := 0; ; i++ {
+		token := fmt.Sprintf("session-%d", i)
+		if other, ok := seen[SessionID(token)[0]]; ok {
+			return other, token
+		}
+		seen[SessionID(token)[0]] = token
+	}
+}
+
+func TestFindSession(t *testing.T) {
+	sessions := []Session{{ID: "abc123def456"}, {ID: "abd000000000"}, {ID: "ffff00000000"}}
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr string
+	}{
+		{name: "exact", id: "abd000000000", want: "abd000000000"},
+		{name: "unique prefix", id: "abc", want: "abc123def456"},
+		{name: "one character", id: "f", want: "ffff00000000"},
+		{name: "ambiguous prefix", id: "ab", wantErr: `session ID "ab" is ambiguous`},
+		{name: "unknown", id: "0", wantErr: "session not found: 0"},
+		{name: "longer than any ID", id: "abc123def4567", wantErr: "session not found"},
+		{name: "empty", id: "", wantErr: "session not found: empty ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := FindSession(sessions, tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FindSession(%q) error = %v, want %q", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.ID != tt.want {
+				t.Errorf("FindSession(%q) = %s, want %s", tt.id, s.ID, tt.want)
+			}
+		})
+	}
+
+	if _, err := FindSession(nil, "abc"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("FindSession without sessions: error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	authPath := writeSessions(t, "phone", "tablet")
+	sessions, err := ListSessions(authPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Session{
+		{ID: SessionID("tablet"), Device: "device tablet", CreatedAt: 1, LastActivity: 2},
+		{ID: SessionID("phone"), Device: "device phone", CreatedAt: 1, LastActivity: 1},
+	}
+	if !reflect.DeepEqual(sessions, want) {
+		t.Errorf("ListSessions = %+v, want %+v", sessions, want)
+	}
+
+	if sessions, err := ListSessions(filepath.Join(t.TempDir(), "auth.json")); err != nil || len(sessions) != 0 {
+		t.Errorf("ListSessions without an auth file = %v, %v; want none", sessions, err)
+	}
+}
+
+func TestRevokeSessionByPrefix(t *testing.T) {
+	authPath := writeSessions(t, "phone", "tablet")
+
+	session, err := RevokeSession(authPath, SessionID("phone")[:6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID != SessionID("phone") || session.Device != "device phone" {
+		t.Errorf("RevokeSession = %+v, want the phone session", session)
+	}
+
+	file, _ := readServerFile(t, authPath)
+	if _, ok := file.Sessions["phone"]; ok {
+		t.Error("revoked session is still in auth.json")
+	}
+	if _, ok := file.Sessions["tablet"]; !ok || file.MasterToken != "master-token" {
+		t.Errorf("revoking one session changed the rest of auth.json: %+v", file)
+	}
+
+	if _, err := RevokeSession(authPath, SessionID("phone")); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking twice: error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestRevokeSessionRejects(t *testing.T) {
+	a, b := clashingTokens()
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"ambiguous prefix", SessionID(a)[:1], "is ambiguous"},
+		{"empty ID", "", "empty ID"},
+		{"unknown ID", "zzz", "session not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authPath := writeSessions(t, a, b)
+			if _, err := RevokeSession(authPath, tt.id); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("RevokeSession(%q) error = %v, want %q", tt.id, err, tt.want)
+			}
+			if file, _ := readServerFile(t, authPath); len(file.Sessions) != 2 {
+				t.Errorf("a rejected revoke changed the sessions: %v", file.Sessions)
+			}
+		})
+	}
+
+	missing := filepath.Join(t.TempDir(), "auth.json")
+	if _, err := RevokeSession(missing, "abc"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession without an auth file: error = %v, want ErrSessionNotFound", err)
+	}
+}
@@ -5,52 +5,111 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
-// AuthData represents the structure of auth.json.
+// AuthData is the content of auth.json, in the format the server's
+// AuthManager reads and rewrites. Sessions are kept verbatim so that
+// nappctl never drops sessions the server created.
 type AuthData struct {
+	// MasterToken is empty when SecretStore keeps it elsewhere.
+	MasterToken string `json:"masterToken,omitempty"`
+	// SecretStore is the backend holding the master token, when it is not
+	// the file backend.
+	SecretStore string                     `json:"secretStore,omitempty"`
+	Sessions    map[string]json.RawMessage `json:"sessions"`
+	// Tokens are the named, scoped tokens, keyed by name.
+	Tokens map[string]*NamedToken `json:"tokens,omitempty"`
+	// LastSaved is the time of the last write, in Unix milliseconds.
+	LastSaved int64 `json:"lastSaved"`
+}
+
+// backend returns the secret store backend holding the master token.
+func (d *AuthData) backend() string {
+	if d.SecretStore == "" {
+		return BackendFile
+	}
+	return d.SecretStore
+}
+
+// legacyAuthData is the format earlier versions of nappctl wrote. The
+// server does not understand it and replaces it with a new token.
+type legacyAuthData struct {
 	Token     string `json:"token"`
 	CreatedAt string `json:"createdAt,omitempty"`
 }
 
-// ServerAuthData represents the server's auth.json format (for backward compatibility)
-type ServerAuthData struct {
-	MasterToken string                 `json:"masterToken"`
-	Sessions    map[string]interface{} `json:"sessions"`
-	LastSaved   int64                  `json:"lastSaved"`
+// ReadAuthFile reads the auth.json file and returns the auth data, or nil
+// if the file does not exist or holds no token (nor names the secret store
+// holding it). A file in the legacy
+// nappctl format is migrated to the server format in place.
+func ReadAuthFile(authPath string) (*AuthData, error) {
+	authData, legacy, err := readAuthFile(authPath)
+	if err != nil || !legacy {
+		return authData, err
+	}
+
+	if err := WriteAuthFile(authPath, authData); err != nil {
+		return nil, fmt.Errorf("failed to migrate auth file: %w", err)
+	}
+	return authData, nil
+}
+
+// MigrateAuthFile rewrites a legacy nappctl-format auth.json in the
+// server format, keeping its token. It reports whether the file was
+// migrated; missing and server-format files are left alone.
+func MigrateAuthFile(authPath string) (bool, error) {
+	authData, legacy, err := readAuthFile(authPath)
+	if err != nil || !legacy {
+		return false, err
+	}
+
+	if err := WriteAuthFile(authPath, authData); err != nil {
+		return false, fmt.Errorf("failed to migrate auth file: %w", err)
+	}
+	return true, nil
 }
 
-// ReadAuthFile reads the auth.json file and returns the auth data.
-// Supports both nappctl format (token field) and server format (masterToken field).
-func ReadAuthFile(authPath string) (*AuthData, error) {
+// readAuthFile parses auth.json in either format, reporting whether it was
+// in the legacy format.
+func readAuthFile(authPath string) (*AuthData, bool, error) {
 	data, err := os.ReadFile(authPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil // File doesn't exist, return nil
+			return nil, false, nil
 		}
-		return nil, fmt.Errorf("failed to read auth file: %w", err)
+		return nil, false, fmt.Errorf("failed to read auth file: %w", err)
 	}
 
-	// Try nappctl format first
 	var authData AuthData
-	if err := json.Unmarshal(data, &authData); err == nil && authData.Token != "" {
-		return &authData, nil
+	if err := json.Unmarshal(data, &authData); err != nil {
+		return nil, false, fmt.Errorf("failed to parse auth file: %w", err)
+	}
+	if authData.SecretStore == BackendFile {
+		authData.SecretStore = ""
+	}
+	if authData.MasterToken != "" || authData.SecretStore != "" {
+		if authData.Sessions == nil {
+			authData.Sessions = map[string]json.RawMessage{}
+		}
+		return &authData, false, nil
 	}
 
-	// Try server format (backward compatibility)
-	var serverAuth ServerAuthData
-	if err := json.Unmarshal(data, &serverAuth); err == nil && serverAuth.MasterToken != "" {
+	var legacy legacyAuthData
+	if err := json.Unmarshal(data, &legacy); err == nil && legacy.Token != "" {
 		return &AuthData{
-			Token:     serverAuth.MasterToken,
-			CreatedAt: "", // Server format doesn't track creation time
-		}, nil
+			MasterToken: legacy.Token,
+			Sessions:    map[string]json.RawMessage{},
+		}, true, nil
 	}
 
 	// File exists but has no valid token
-	return nil, nil
+	return nil, false, nil
 }
 
-// WriteAuthFile writes auth data to the auth.json file.
+// WriteAuthFile writes auth data to the auth.json file, setting LastSaved.
+// The file is replaced atomically so the server never reads a partial
+// write.
 func WriteAuthFile(authPath string, authData *AuthData) error {
 	// Ensure parent directory exists
 	dir := filepath.Dir(authPath)
@@ -58,22 +117,55 @@ func WriteAuthFile(authPath string, authData *AuthData) error {
 		return fmt.Errorf("failed to create auth directory: %w", err)
 	}
 
-	// Marshal with indentation for readability
+	if authData.Sessions == nil {
+		authData.Sessions = map[string]json.RawMessage{}
+	}
+	authData.LastSaved = time.Now().UnixMilli()
+
+	// Marshal with indentation, as the server does
 	data, err := json.MarshalIndent(authData, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal auth data: %w", err)
 	}
 
-	// Write with restricted permissions (only owner can read/write)
-	if err := os.WriteFile(authPath, data, 0600); err != nil {
+	if err := writeFileAtomic(authPath, data); err != nil {
 		return fmt.Errorf("failed to write auth file: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteAuthFile removes the auth.json file.
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory.
+func writeFileAtomic(path string, data []byte) error {
+	// Write with restricted permissions (only owner can read/write)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// DeleteAuthFile removes the auth.json file, and the master token from the
+// secret store holding it.
 func DeleteAuthFile(authPath string) error {
+	if authData, _, err := readAuthFile(authPath); err == nil && authData != nil && authData.SecretStore != "" {
+		store, err := OpenSecretStore(authData.SecretStore, authPath)
+		if err != nil {
+			return err
+		}
+		if err := store.Delete(); err != nil {
+			return err
+		}
+	}
 	if err := os.Remove(authPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete auth file: %w", err)
 	}
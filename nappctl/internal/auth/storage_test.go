@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// serverAuthFile is auth.json as the server's AuthManager reads it.
+type serverAuthFile struct {
+	MasterToken string                     `json:"masterToken"`
+	Sessions    map[string]json.RawMessage `json:"sessions"`
+	LastSaved   int64                      `json:"lastSaved"`
+}
+
+func writeRaw(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readServerFile parses auth.json the way the server does, failing the
+// test unless it has the fields the server requires.
+func readServerFile(t *testing.T, path string) (serverAuthFile, map[string]json.RawMessage) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("auth.json is not a JSON object: %v", err)
+	}
+	for _, key := range []string{"masterToken", "sessions", "lastSaved"} {
+		if _, ok := raw[key]; !ok {
+			t.Fatalf("auth.json has no %q: %s", key, data)
+		}
+	}
+
+	var file serverAuthFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("auth.json is not in the server's format: %v", err)
+	}
+	if file.MasterToken == "" || file.Sessions == nil || file.LastSaved == 0 {
+		t.Fatalf("auth.json is missing server fields: %s", data)
+	}
+	return file, raw
+}
+
+func TestReadAuthFileMigratesLegacyFormat(t *testing.T) {
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+	writeRaw(t, authPath, `{"token": "legacy-token", "createdAt": "2024-01-02T03:04:05Z"}`)
+
+	authData, err := ReadAuthFile(authPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authData == nil || authData.MasterToken != "legacy-token" {
+		t.Fatalf("ReadAuthFile = %+v, want master token legacy-token", authData)
+	}
+
+	file, raw := readServerFile(t, authPath)
+	if file.MasterToken != "legacy-token" {
+		t.Errorf("migrated masterToken = %q, want legacy-token", file.MasterToken)
+	}
+	if _, ok := raw["token"]; ok {
+		t.Error("migrated file still has the legacy token field")
+	}
+
+	token, err := GetToken(authPath)
+	if err != nil || token != "legacy-token" {
+		t.Errorf("GetToken = %q, %v; want legacy-token", token, err)
+	}
+}
+
+func TestMigrateAuthFile(t *testing.T) {
+	dir := t.TempDir()
+
+	legacyPath := filepath.Join(dir, "legacy.json")
+	writeRaw(t, legacyPath, `{"token": "legacy-token"}`)
+	migrated, err := MigrateAuthFile(legacyPath)
+	if err != nil || !migrated {
+		t.Fatalf("MigrateAuthFile(legacy) = %t, %v; want true", migrated, err)
+	}
+	if file, _ := readServerFile(t, legacyPath); file.MasterToken != "legacy-token" {
+		t.Errorf("migrated masterToken = %q, want legacy-token", file.MasterToken)
+	}
+
+	serverPath := filepath.Join(dir, "server.json")
+	content := `{"masterToken": "server-token", "sessions": {}, "lastSaved": 1}`
+	writeRaw(t, serverPath, content)
+	migrated, err = MigrateAuthFile(serverPath)
+	if err != nil || migrated {
+		t.Fatalf("MigrateAuthFile(server) = %t, %v; want false", migrated, err)
+	}
+	if data, _ := os.ReadFile(serverPath); string(data) != content {
+		t.Errorf("server-format file was rewritten: %s", data)
+	}
+
+	migrated, err = MigrateAuthFile(filepath.Join(dir, "missing.json"))
+	if err != nil || migrated {
+		t.Errorf("MigrateAuthFile(missing) = %t, %v; want false, nil", migrated, err)
+	}
+}
+
+func TestTokenChangesKeepSessions(t *testing.T) {
+	const session = `{"device":"phone","createdAt":1700000000000,"lastActivity":1700000000000}`
+
+	for _, tc := range []struct {
+		name   string
+		change func(authPath string) (string, error)
+	}{
+		{"CreateAndSaveToken", func(authPath string) (string, error) { return CreateAndSaveToken(authPath, "") }},
+		{"RotateToken", func(authPath string) (string, error) { return RotateToken(authPath, BackendFile) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			authPath := filepath.Join(t.TempDir(), "auth.json")
+			writeRaw(t, authPath, `{
+  "masterToken": "old-token",
+  "sessions": {"session-token": `+session+`},
+  "lastSaved": 1
+}`)
+
+			token, err := tc.change(authPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token == "" || token == "old-token" {
+				t.Fatalf("new token = %q", token)
+			}
+
+			file, _ := readServerFile(t, authPath)
+			if file.MasterToken != token {
+				t.Errorf("masterToken = %q, want %q", file.MasterToken, token)
+			}
+			if file.LastSaved <= 1 {
+				t.Errorf("lastSaved = %d, want the time of the write", file.LastSaved)
+			}
+			var got, want map[string]interface{}
+			if err := json.Unmarshal(file.Sessions["session-token"], &got); err != nil {
+				t.Fatalf("session was not kept: %v", err)
+			}
+			json.Unmarshal([]byte(session), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("session = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteAuthFileServerFormat(t *testing.T) {
+	authPath := filepath.Join(t.TempDir(), "data", "auth.json")
+
+	if err := WriteAuthFile(authPath, &AuthData{MasterToken: "token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, raw := readServerFile(t, authPath)
+	if file.MasterToken != "token" || len(file.Sessions) != 0 {
+		t.Errorf("auth.json = %+v", file)
+	}
+	if _, ok := raw["secretStore"]; ok {
+		t.Error("file backend wrote secretStore")
+	}
+
+	info, err := os.Stat(authPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("auth.json mode = %v, want no group or other access", perm)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"net"
 	"os"
 	"os/exec"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/mdp/qrterminal/v3"
@@ -16,22 +15,27 @@ func GenerateToken() string {
 	return uuid.New().String()
 }
 
-// CreateAndSaveToken generates a new token and saves it to the auth file.
-func CreateAndSaveToken(authPath string) (string, error) {
-	token := GenerateToken()
-	authData := &AuthData{
-		Token:     token,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+// CreateAndSaveToken generates a new master token and saves it in the
+// given secret store backend, keeping any sessions already stored in the
+// auth file. An empty backend keeps the token where it is; a different one
+// moves it there.
+func CreateAndSaveToken(authPath, backend string) (string, error) {
+	if backend == "" {
+		var err error
+		if backend, err = CurrentBackend(authPath); err != nil {
+			return "", err
+		}
 	}
 
-	if err := WriteAuthFile(authPath, authData); err != nil {
-		return "", fmt.Errorf("failed to save token: %w", err)
+	token := GenerateToken()
+	if err := saveToken(authPath, backend, token); err != nil {
+		return "", err
 	}
 
 	return token, nil
 }
 
-// GetToken retrieves the current token from the auth file.
+// GetToken retrieves the current token from the auth file's secret store.
 // Returns empty string if no token exists.
 func GetToken(authPath string) (string, error) {
 	authData, err := ReadAuthFile(authPath)
@@ -42,19 +46,22 @@ func GetToken(authPath string) (string, error) {
 	if authData == nil {
 		return "", nil
 	}
+	if authData.SecretStore == "" {
+		return authData.MasterToken, nil
+	}
 
-	return authData.Token, nil
-}
-
-// RotateToken generates a new token and replaces the existing one.
-func RotateToken(authPath string) (string, error) {
-	// Delete existing token
-	if err := DeleteAuthFile(authPath); err != nil {
-		return "", fmt.Errorf("failed to remove old token: %w", err)
+	store, err := OpenSecretStore(authData.SecretStore, authPath)
+	if err != nil {
+		return "", err
 	}
+	return store.Get()
+}
 
-	// Create new token
-	return CreateAndSaveToken(authPath)
+// RotateToken replaces the master token with a new one, saved in the given
+// backend as for CreateAndSaveToken. Sessions are kept: they are revoked
+// individually.
+func RotateToken(authPath, backend string) (string, error) {
+	return CreateAndSaveToken(authPath, backend)
 }
 
 // PrintQRCode displays a QR code for the given text (typically a URL with token).
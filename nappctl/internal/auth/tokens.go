@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Token scopes. A named token may only do what its scopes allow; the
+// master token and paired sessions are not scoped.
+const (
+	// ScopeReadOnly allows GET requests anywhere in the API and file watching.
+	ScopeReadOnly = "read-only"
+	// ScopeFilesWrite allows changing files and running git operations.
+	ScopeFilesWrite = "files-write"
+	// ScopeTerminal allows the terminals API and terminal sessions.
+	ScopeTerminal = "terminal"
+	// ScopeChat allows the conversations API and chat sessions.
+	ScopeChat = "chat"
+)
+
+// Scopes lists the scopes a named token can be granted.
+var Scopes = []string{ScopeReadOnly, ScopeFilesWrite, ScopeTerminal, ScopeChat}
+
+var (
+	// ErrTokenNotFound is returned for an unknown named token.
+	ErrTokenNotFound = errors.New("token not found")
+
+	// ErrTokenExists is returned when creating a named token whose name is
+	// taken.
+	ErrTokenExists = errors.New("a token with this name already exists")
+)
+
+var tokenNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// NamedToken is a scoped, optionally expiring token stored in auth.json.
+// Only a hash of the token is stored; the token itself is shown once, when
+// it is created. Times are Unix milliseconds, as the server writes them.
+type NamedToken struct {
+	Name      string   `json:"-"`
+	TokenHash string   `json:"tokenHash"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"createdAt"`
+	// ExpiresAt is zero for a token that does not expire.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+	// LastUsed is recorded by the server.
+	LastUsed int64 `json:"lastUsed,omitempty"`
+}
+
+// Expired reports whether the token has expired at now.
+func (t *NamedToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != 0 && now.UnixMilli() >= t.ExpiresAt
+}
+
+// HashToken returns the hash under which a named token is stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseScopes validates a list of scopes, accepting comma-separated values.
+func ParseScopes(values []string) ([]string, error) {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, scope := range strings.Split(value, ",") {
+			scope = strings.TrimSpace(scope)
+			if scope == "" || seen[scope] {
+				continue
+			}
+			if !validScope(scope) {
+				return nil, fmt.Errorf("unknown scope %q (use %s)", scope, strings.Join(Scopes, ", "))
+			}
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required (%s)", strings.Join(Scopes, ", "))
+	}
+	return scopes, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateNamedToken adds a named token with the given scopes to the auth
+// file and returns the token. A zero ttl means it never expires.
+func CreateNamedToken(authPath, name string, scopes []string, ttl time.Duration) (string, *NamedToken, error) {
+	if !tokenNamePattern.MatchString(name) {
+		return "", nil, fmt.Errorf("invalid token name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	scopes, err := ParseScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	authData, err := ReadAuthFile(authPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if authData == nil {
+		return "", nil, fmt.Errorf("no master token found; generate one with: nappctl auth generate")
+	}
+	if _, ok := authData.Tokens[name]; ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrTokenExists, name)
+	}
+
+	token := GenerateToken()
+	now := time.Now()
+	named := &NamedToken{
+		Name:      name,
+		TokenHash: HashToken(token),
+		Scopes:    scopes,
+		CreatedAt: now.UnixMilli(),
+	}
+	if ttl > 0 {
+		named.ExpiresAt = now.Add(ttl).UnixMilli()
+	}
+
+	if authData.Tokens == nil {
+		authData.Tokens = map[string]*NamedToken{}
+	}
+	authData.Tokens[name] = named
+	if err := WriteAuthFile(authPath, authData); err != nil {
+		return "", nil, fmt.Errorf("failed to save token: %w", err)
+	}
+
+	return token, named, nil
+}
+
+// ListNamedTokens returns the named tokens in the auth file, sorted by name.
+func ListNamedTokens(authPath string) ([]*NamedToken, error) {
+	authData, err := ReadAuthFile(authPath)
+	if err != nil || authData == nil {
+		return nil, err
+	}
+
+	tokens := make([]*NamedToken, 0, len(authData.Tokens))
+	for name, t := range authData.Tokens {
+		t.Name = name
+		tokens = append(tokens, t)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+	return tokens, nil
+}
+
+// RevokeNamedToken removes a named token from the auth file.
+func RevokeNamedToken(authPath, name string) error {
+	authData, err := ReadAuthFile(authPath)
+	if err != nil {
+		return err
+	}
+	if authData == nil {
+		return fmt.Errorf("%w: %s", ErrTokenNotFound, name)
+	}
+	if _, ok := authData.Tokens[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrTokenNotFound, name)
+	}
+
+	delete(authData.Tokens, name)
+	if err := WriteAuthFile(authPath, authData); err != nil {
+		return fmt.Errorf("failed to save auth file: %w", err)
+	}
+	return nil
+}
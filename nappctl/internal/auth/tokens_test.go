@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []string
+		wantErr string
+	}{
+		{"one scope", []string{"read-only"}, []string{"read-only"}, ""},
+		{"repeated flags", []string{"terminal", "chat"}, []string{"terminal", "chat"}, ""},
+		{"comma-separated", []string{"files-write, chat"}, []string{"files-write", "chat"}, ""},
+		{"duplicates dropped", []string{"chat,chat", "chat"}, []string{"chat"}, ""},
+		{"empty entries skipped", []string{",read-only,,"}, []string{"read-only"}, ""},
+		{"every scope", []string{strings.Join(Scopes, ",")}, Scopes, ""},
+		{"unknown scope", []string{"read-only", "admin"}, nil, `unknown scope "admin"`},
+		{"scopes are case-sensitive", []string{"Read-Only"}, nil, "unknown scope"},
+		{"none", nil, nil, "at least one scope is required"},
+		{"only separators", []string{" , "}, nil, "at least one scope is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScopes(tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseScopes(%q) error = %v, want %q", tt.values, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseScopes(%q) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamedTokenExpired(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	tests := []struct {
+		name      string
+		expiresAt int64
+		want      bool
+	}{
+		{"never expires", 0, false},
+		{"a millisecond before", now.UnixMilli() + 1, false},
+		{"at the expiry", now.UnixMilli(), true},
+		{"after the expiry", now.UnixMilli() - 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &NamedToken{ExpiresAt: tt.expiresAt}
+			if got := token.Expired(now); got != tt.want {
+				t.Errorf("Expired = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// newAuthFile creates an auth file with a master token.
+func newAuthFile(t *testing.T) string {
+	t.Helper()
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+	if _, err := CreateAndSaveToken(authPath, ""); err != nil {
+		t.Fatal(err)
+	}
+	return authPath
+}
+
+func TestCreateNamedToken(t *testing.T) {
+	authPath := newAuthFile(t)
+
+	before := time.Now()
+	token, named, err := CreateNamedToken(authPath, "ci", []string{"read-only,chat"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "" || named.TokenHash != HashToken(token) {
+		t.Errorf("token hash = %q, want the hash of %q", named.TokenHash, token)
+	}
+	if want := []string{"read-only", "chat"}; !reflect.DeepEqual(named.Scopes, want) {
+		t.Errorf("scopes = %q, want %q", named.Scopes, want)
+	}
+	if earliest := before.Add(time.Hour).UnixMilli(); named.ExpiresAt < earliest || named.ExpiresAt > time.Now().Add(time.Hour).UnixMilli() {
+		t.Errorf("expiresAt = %d, want an hour from now", named.ExpiresAt)
+	}
+
+	data, err := os.ReadFile(authPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Error("auth.json holds the token in the clear")
+	}
+
+	tokens, err := ListNamedTokens(authPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].Name != "ci" || tokens[0].TokenHash != named.TokenHash {
+		t.Fatalf("ListNamedTokens = %+v, want the ci token", tokens)
+	}
+
+	if _, _, err := CreateNamedToken(authPath, "ci", []string{"chat"}, 0); !errors.Is(err, ErrTokenExists) {
+		t.Errorf("creating a duplicate name: error = %v, want ErrTokenExists", err)
+	}
+
+	_, forever, err := CreateNamedToken(authPath, "forever", []string{"terminal"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forever.ExpiresAt != 0 || forever.Expired(time.Now().AddDate(100, 0, 0)) {
+		t.Errorf("token without a ttl expires at %d", forever.ExpiresAt)
+	}
+
+	if err := RevokeNamedToken(authPath, "ci"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeNamedToken(authPath, "ci"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("revoking twice: error = %v, want ErrTokenNotFound", err)
+	}
+	if tokens, _ := ListNamedTokens(authPath); len(tokens) != 1 || tokens[0].Name != "forever" {
+		t.Errorf("ListNamedTokens after revoke = %+v, want only forever", tokens)
+	}
+}
+
+func TestCreateNamedTokenNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"ci", true},
+		{"alex-phone", true},
+		{"build_2.bot", true},
+		{"0day", true},
+		{strings.Repeat("a", 64), true},
+		{"", false},
+		{strings.Repeat("a", 65), false},
+		{"-ci", false},
+		{".hidden", false},
+		{"has space", false},
+		{"a/b", false},
+		{"é", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authPath := newAuthFile(t)
+			_, _, err := CreateNamedToken(authPath, tt.name, []string{"read-only"}, 0)
+			if tt.valid && err != nil {
+				t.Errorf("CreateNamedToken(%q) = %v, want success", tt.name, err)
+			}
+			if !tt.valid && (err == nil || !strings.Contains(err.Error(), "invalid token name")) {
+				t.Errorf("CreateNamedToken(%q) error = %v, want an invalid name", tt.name, err)
+			}
+		})
+	}
+}
+
+func TestCreateNamedTokenRejects(t *testing.T) {
+	authPath := newAuthFile(t)
+	if _, _, err := CreateNamedToken(authPath, "ci", []string{"admin"}, 0); err == nil {
+		t.Error("CreateNamedToken accepted an unknown scope")
+	}
+
+	missing := filepath.Join(t.TempDir(), "auth.json")
+	if _, _, err := CreateNamedToken(missing, "ci", []string{"chat"}, 0); err == nil {
+		t.Error("CreateNamedToken succeeded without a master token")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("CreateNamedToken created an auth file without a master token: %v", err)
+	}
+}
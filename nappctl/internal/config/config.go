@@ -14,6 +14,9 @@ type Config struct {
 	AuthToken  string `mapstructure:"auth_token"`
 	DataDir    string `mapstructure:"data_dir"`
 	ServerPath string `mapstructure:"server_path"`
+	// SecretStore is the backend new master tokens are kept in: file,
+	// encrypted or keyring.
+	SecretStore string `mapstructure:"secret_store"`
 }
 
 // Load reads configuration from file and environment variables.
@@ -42,6 +45,7 @@ func Load() (*Config, error) {
 	viper.SetDefault("port", 3847)
 	viper.SetDefault("host", "localhost")
 	viper.SetDefault("data_dir", dataDir)
+	viper.SetDefault("secret_store", "file")
 
 	// Read config file if it exists (ignore error if not found)
 	if err := viper.ReadInConfig(); err != nil {
@@ -76,6 +80,9 @@ func Save(cfg *Config) error {
 	if cfg.ServerPath != "" {
 		viper.Set("server_path", cfg.ServerPath)
 	}
+	if cfg.SecretStore != "" {
+		viper.Set("secret_store", cfg.SecretStore)
+	}
 
 	if err := viper.WriteConfigAs(configPath); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
@@ -27,11 +27,21 @@ func GetAuthPath(dataDir string) string {
 	return filepath.Join(dataDir, "auth.json")
 }
 
+// GetApprovalPolicyPath returns the path to the approvals watch policy
+func GetApprovalPolicyPath(dataDir string) string {
+	return filepath.Join(dataDir, "approvals.yaml")
+}
+
 // GetDBPath returns the path to chat persistence database
 func GetDBPath(dataDir string) string {
 	return filepath.Join(dataDir, "chat-persistence.db")
 }
 
+// GetSearchIndexPath returns the path to nappctl's chat history search index
+func GetSearchIndexPath(dataDir string) string {
+	return filepath.Join(dataDir, "history-search.db")
+}
+
 // GetPIDPath returns the path to server PID file
 func GetPIDPath(dataDir string) string {
 	return filepath.Join(dataDir, "server.pid")
@@ -0,0 +1,290 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatJSONL    = "jsonl"
+	FormatHTML     = "html"
+)
+
+// ExportFormats lists the formats accepted by Export.
+var ExportFormats = []string{FormatMarkdown, FormatJSON, FormatJSONL, FormatHTML}
+
+// ValidExportFormat reports whether format is one of ExportFormats.
+func ValidExportFormat(format string) bool {
+	for _, f := range ExportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Export writes a conversation and its messages to w in the given format.
+// json writes one document; jsonl writes one message per line.
+func Export(w io.Writer, format string, c *Conversation, messages []Message) error {
+	switch format {
+	case FormatJSON:
+		if messages == nil {
+			messages = []Message{}
+		}
+		data, err := json.MarshalIndent(struct {
+			Conversation *Conversation `json:"conversation"`
+			Messages     []Message     `json:"messages"`
+		}{c, messages}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for i := range messages {
+			if err := enc.Encode(&messages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatMarkdown:
+		return exportMarkdown(w, c, transcript(messages))
+	case FormatHTML:
+		return htmlTemplate.Execute(w, struct {
+			Conversation *Conversation
+			Entries      []entry
+		}{c, transcript(messages)})
+	}
+	return fmt.Errorf("unknown format %q (use %s)", format, strings.Join(ExportFormats, ", "))
+}
+
+// entry is a message prepared for the Markdown and HTML transcripts.
+type entry struct {
+	// Kind is user, assistant, thinking, tool_call, tool_result, error
+	// or event.
+	Kind string
+	// Heading is set on the first entry of each user or assistant turn.
+	Heading  string
+	Time     time.Time
+	ToolName string
+	ToolID   string
+	Body     string
+	IsError  bool
+}
+
+// transcript turns messages into entries, naming tool results after their
+// calls and starting a new heading whenever the speaker changes.
+func transcript(messages []Message) []entry {
+	var entries []entry
+	toolNames := map[string]string{}
+	speaker := ""
+
+	for i := range messages {
+		m := &messages[i]
+		e := entry{Time: m.Timestamp, ToolID: m.ToolID, ToolName: m.ToolName, Body: m.Content, IsError: m.IsError}
+
+		switch m.Type {
+		case "text":
+			e.Kind = "assistant"
+			if m.Role == "user" {
+				e.Kind = "user"
+			}
+		case "thinking":
+			e.Kind = "thinking"
+		case "tool_use_start":
+			e.Kind = "tool_call"
+			e.Body = toolInput(m)
+			if m.ToolID != "" {
+				toolNames[m.ToolID] = m.ToolName
+			}
+		case "tool_use_result":
+			e.Kind = "tool_result"
+			if e.ToolName == "" {
+				e.ToolName = toolNames[m.ToolID]
+			}
+		case "error":
+			e.Kind = "error"
+			e.IsError = true
+		default:
+			e.Kind = "event"
+			e.ToolName = m.Type
+			if prompt, ok := m.Metadata["prompt"].(string); ok && prompt != "" {
+				e.Body = prompt
+			} else if topic, ok := m.Metadata["topic"].(string); ok && topic != "" {
+				e.Body = topic
+			}
+		}
+		e.Body = strings.TrimRight(e.Body, "\n")
+
+		turn := "Assistant"
+		if e.Kind == "user" {
+			turn = "User"
+		}
+		if turn != speaker {
+			e.Heading = turn
+			speaker = turn
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// toolInput returns a tool call's input as indented JSON. Stored calls
+// keep it in Content; live ones in the input metadata.
+func toolInput(m *Message) string {
+	raw := []byte(m.Content)
+	if input, ok := m.Metadata["input"]; ok {
+		if data, err := json.Marshal(input); err == nil && string(data) != "{}" {
+			raw = data
+		}
+	}
+	var buf bytes.Buffer
+	if json.Indent(&buf, raw, "", "  ") != nil {
+		return m.Content
+	}
+	return buf.String()
+}
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func exportMarkdown(w io.Writer, c *Conversation, entries []entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", c.Topic)
+	fmt.Fprintf(&b, "- Conversation: `%s`\n", c.ID)
+	fmt.Fprintf(&b, "- Project: `%s`\n", c.ProjectPath)
+	tool := c.Tool
+	if c.Model != "" {
+		tool += " (" + c.Model + ")"
+	}
+	fmt.Fprintf(&b, "- Tool: %s, %s mode\n", tool, c.Mode)
+	fmt.Fprintf(&b, "- Started: %s\n", c.CreatedAt.Local().Format(timeLayout))
+	fmt.Fprintf(&b, "- Last active: %s (%s)\n", c.LastActivity.Local().Format(timeLayout), c.Status)
+
+	for _, e := range entries {
+		if e.Heading != "" {
+			fmt.Fprintf(&b, "\n## %s · %s\n", e.Heading, e.Time.Local().Format(timeLayout))
+		}
+		b.WriteString("\n")
+
+		switch e.Kind {
+		case "user", "assistant":
+			b.WriteString(e.Body + "\n")
+		case "thinking":
+			fmt.Fprintf(&b, "<details><summary>Thinking</summary>\n\n%s\n\n</details>\n", e.Body)
+		case "tool_call":
+			fmt.Fprintf(&b, "**Tool call:** `%s`%s\n\n", e.ToolName, toolIDSuffix(e.ToolID))
+			b.WriteString(fence(e.Body, "json"))
+		case "tool_result":
+			label := "Result"
+			if e.IsError {
+				label = "Error result"
+			}
+			name := ""
+			if e.ToolName != "" {
+				name = " `" + e.ToolName + "`"
+			}
+			fmt.Fprintf(&b, "**%s:**%s%s\n\n", label, name, toolIDSuffix(e.ToolID))
+			b.WriteString(fence(e.Body, ""))
+		case "error":
+			fmt.Fprintf(&b, "> **Error** (%s)\n>\n", e.Time.Local().Format(timeLayout))
+			for _, line := range strings.Split(e.Body, "\n") {
+				b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+			}
+		case "event":
+			fmt.Fprintf(&b, "_%s_", e.ToolName)
+			if e.Body != "" {
+				fmt.Fprintf(&b, ": %s", strings.ReplaceAll(e.Body, "\n", " "))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func toolIDSuffix(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " (`" + id + "`)"
+}
+
+// fence wraps s in a code fence longer than any backtick run inside it.
+func fence(s, lang string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + lang + "\n" + s + "\n" + marker + "\n"
+}
+
+var htmlTemplate = template.Must(template.New("conversation").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Local().Format(timeLayout) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Conversation.Topic}}</title>
+<style>
+body { font: 15px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 860px; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+header dl { display: grid; grid-template-columns: max-content auto; gap: .2em 1em; color: #59636e; }
+header dd { margin: 0; }
+h2 { font-size: 1.1em; margin: 1.6em 0 .6em; padding-bottom: .2em; border-bottom: 1px solid #d1d9e0; }
+h2 time, .meta time { font-weight: normal; color: #59636e; font-size: .85em; margin-left: .5em; }
+.user { background: #f6f8fa; border-radius: 6px; padding: .5em 1em; }
+.text { white-space: pre-wrap; }
+.meta { font-size: .9em; color: #59636e; margin: .8em 0 .2em; }
+.meta code { color: #1f2328; }
+pre { background: #f6f8fa; border: 1px solid #d1d9e0; border-radius: 6px; padding: .6em .8em; overflow-x: auto; font-size: .85em; margin: 0; }
+.failed pre, .error { border-color: #ff818266; background: #ffebe9; }
+.failed .meta, .error { color: #d1242f; }
+.error { border: 1px solid; border-radius: 6px; padding: .5em 1em; white-space: pre-wrap; margin: .8em 0; }
+.event { color: #59636e; font-style: italic; font-size: .9em; }
+details { color: #59636e; margin: .8em 0; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Conversation.Topic}}</h1>
+<dl>
+<dt>Conversation</dt><dd><code>{{.Conversation.ID}}</code></dd>
+<dt>Project</dt><dd><code>{{.Conversation.ProjectPath}}</code></dd>
+<dt>Tool</dt><dd>{{.Conversation.Tool}}{{with .Conversation.Model}} ({{.}}){{end}}, {{.Conversation.Mode}} mode</dd>
+<dt>Started</dt><dd>{{time .Conversation.CreatedAt}}</dd>
+<dt>Last active</dt><dd>{{time .Conversation.LastActivity}} ({{.Conversation.Status}})</dd>
+</dl>
+</header>
+{{range .Entries}}
+{{- if .Heading}}<h2>{{.Heading}}<time>{{time .Time}}</time></h2>
+{{end}}
+{{- if eq .Kind "user"}}<div class="user text">{{.Body}}</div>
+{{- else if eq .Kind "assistant"}}<div class="text">{{.Body}}</div>
+{{- else if eq .Kind "thinking"}}<details><summary>Thinking</summary><div class="text">{{.Body}}</div></details>
+{{- else if eq .Kind "tool_call"}}<div class="tool"><div class="meta">Tool call <code>{{.ToolName}}</code>{{with .ToolID}} <code>{{.}}</code>{{end}}<time>{{time .Time}}</time></div><pre>{{.Body}}</pre></div>
+{{- else if eq .Kind "tool_result"}}<div class="tool{{if .IsError}} failed{{end}}"><div class="meta">{{if .IsError}}Error result{{else}}Result{{end}}{{with .ToolName}} <code>{{.}}</code>{{end}}{{with .ToolID}} <code>{{.}}</code>{{end}}</div><pre>{{.Body}}</pre></div>
+{{- else if eq .Kind "error"}}<div class="error"><strong>Error</strong> <time>{{time .Time}}</time>
+{{.Body}}</div>
+{{- else}}<p class="event">{{.ToolName}}{{with .Body}}: {{.}}{{end}}</p>
+{{- end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,208 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exportSample loads conversation abc123 with sampleMessages and extra
+// from a temp database, as nappctl chat export does.
+func exportSample(t *testing.T, extra ...testMessage) (*Conversation, []Message) {
+	t.Helper()
+	path, db := newChatDB(t)
+	addConversations(t, db, testConversation{"abc123", "/src/app", "active", 300})
+	addSampleMessages(t, db, "abc123")
+	for i := range extra {
+		extra[i].ConversationID = "abc123"
+	}
+	addMessages(t, db, extra...)
+
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	c, err := h.Conversation("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := h.Messages(c.ID, MessageQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, messages
+}
+
+func export(t *testing.T, format string, c *Conversation, messages []Message) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Export(&buf, format, c, messages); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestExportMarkdown(t *testing.T) {
+	c, messages := exportSample(t)
+	out := export(t, FormatMarkdown, c, messages)
+
+	for _, want := range []string{
+		"# Topic of abc123\n",
+		"- Conversation: `abc123`\n",
+		"- Project: `/src/app`\n",
+		"- Tool: claude, agent mode\n",
+		"\nlist the files\n",
+		"\nSure, listing them.\n",
+		"<details><summary>Thinking</summary>\n\nls should do\n\n</details>\n",
+		"**Tool call:** `Bash` (`tool1`)\n\n```json\n{\n  \"command\": \"ls\"\n}\n```\n",
+		// Live calls keep their input in the metadata
+		"**Tool call:** `Read` (`tool2`)\n\n```json\n{\n  \"file_path\": \"c.go\"\n}\n```\n",
+		// Results are named after their calls
+		"**Result:** `Bash` (`tool1`)\n\n```\na.go\nb.go\n```\n",
+		"**Error result:** `Read` (`tool2`)\n\n```\nENOENT\n```\n",
+		"> rate limited\n",
+		"_approval_request_: Run ls?\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Sur\n") || strings.Contains(out, "\na.go\n```") {
+		t.Errorf("markdown holds a delta or a superseded result:\n%s", out)
+	}
+	if n := strings.Count(out, "\n## User · "); n != 1 {
+		t.Errorf("%d User headings, want 1", n)
+	}
+	if n := strings.Count(out, "\n## Assistant · "); n != 1 {
+		t.Errorf("%d Assistant headings, want 1: one per change of speaker", n)
+	}
+}
+
+func TestExportMarkdownTurns(t *testing.T) {
+	c, messages := exportSample(t,
+		testMessage{ID: "m12", Type: "text", Role: "user", Content: "now the tests", Timestamp: 12},
+		testMessage{ID: "m13", Type: "text", Role: "assistant", Content: "Running them.", Timestamp: 13},
+	)
+	out := export(t, FormatMarkdown, c, messages)
+	if n := strings.Count(out, "\n## User · "); n != 2 {
+		t.Errorf("%d User headings, want 2", n)
+	}
+	if n := strings.Count(out, "\n## Assistant · "); n != 2 {
+		t.Errorf("%d Assistant headings, want 2", n)
+	}
+	if i, j := strings.Index(out, "now the tests"), strings.LastIndex(out, "\n## User · "); j > i {
+		t.Error("the second User heading comes after its message")
+	}
+}
+
+func TestFence(t *testing.T) {
+	tests := []struct {
+		name, body, marker string
+	}{
+		{"plain", "ls -la", "```"},
+		{"short runs", "`a` and ``b``", "```"},
+		{"a fence inside", "```go\nx := 1\n```", "````"},
+		{"a longer run", "`````", "``````"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.marker + "\n" + tt.body + "\n" + tt.marker + "\n"
+			if got := fence(tt.body, ""); got != want {
+				t.Errorf("fence(%q) = %q, want %q", tt.body, got, want)
+			}
+		})
+	}
+
+	c, messages := exportSample(t, testMessage{ID: "m12", Type: "tool_use_result", Timestamp: 12,
+		ToolID: "tool1", Content: "```\nnot the end\n```"})
+	out := export(t, FormatMarkdown, c, messages)
+	if !strings.Contains(out, "````\n```\nnot the end\n```\n````\n") {
+		t.Errorf("result with a fence inside is not fenced longer:\n%s", out)
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	c, messages := exportSample(t,
+		testMessage{ID: "m12", Type: "text", Role: "assistant", Content: "<script>alert(1)</script>", Timestamp: 12})
+	out := export(t, FormatHTML, c, messages)
+
+	if strings.Contains(out, "<script>") {
+		t.Errorf("message content is not escaped:\n%s", out)
+	}
+	for _, want := range []string{
+		"<title>Topic of abc123</title>",
+		`<div class="user text">list the files</div>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<div class="tool failed"><div class="meta">Error result <code>Read</code> <code>tool2</code>`,
+		"Result <code>Bash</code> <code>tool1</code></div><pre>a.go\nb.go</pre>",
+		`<p class="event">approval_request: Run ls?</p>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("html is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	c, messages := exportSample(t)
+
+	var doc struct {
+		Conversation Conversation `json:"conversation"`
+		Messages     []Message    `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(export(t, FormatJSON, c, messages)), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Conversation.ID != "abc123" || doc.Conversation.MessageCount != 9 {
+		t.Errorf("conversation = %+v", doc.Conversation)
+	}
+	if got, want := messageIDs(doc.Messages), messageIDs(messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	out := export(t, FormatJSON, c, nil)
+	if !strings.Contains(out, `"messages": []`) {
+		t.Errorf("no messages exported as %s, want an empty array", out)
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	c, messages := exportSample(t)
+	out := export(t, FormatJSONL, c, messages)
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != len(messages) {
+		t.Fatalf("%d lines, want one per message (%d)", len(lines), len(messages))
+	}
+	for i, line := range lines {
+		var m Message
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if m.ID != messages[i].ID || m.Content != messages[i].Content || !m.Timestamp.Equal(messages[i].Timestamp) {
+			t.Errorf("line %d = %+v, want %+v", i+1, m, messages[i])
+		}
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	err := Export(&buf, "pdf", &Conversation{}, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown format "pdf"`) {
+		t.Errorf("Export error = %v, want an unknown format", err)
+	}
+
+	for _, format := range ExportFormats {
+		if !ValidExportFormat(format) {
+			t.Errorf("ValidExportFormat(%q) = false", format)
+		}
+	}
+	for _, format := range []string{"", "pdf", "MD"} {
+		if ValidExportFormat(format) {
+			t.Errorf("ValidExportFormat(%q) = true", format)
+		}
+	}
+}
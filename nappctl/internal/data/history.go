@@ -0,0 +1,307 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ErrConversationNotFound is returned by History.Conversation for an unknown ID.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// Message kinds, used to filter a conversation's messages.
+const (
+	KindText     = "text"
+	KindThinking = "thinking"
+	KindTool     = "tool"
+	KindError    = "error"
+	KindEvent    = "event"
+)
+
+// MessageKinds lists the kinds accepted by MessageQuery.Kinds.
+var MessageKinds = []string{KindText, KindThinking, KindTool, KindError, KindEvent}
+
+// History reads the server's chat-persistence.db directly, so it works
+// while the server is stopped. The database is opened read-only.
+type History struct {
+	db *sql.DB
+}
+
+// Conversation is a row of the conversations table.
+type Conversation struct {
+	ID           string    `json:"id"`
+	Tool         string    `json:"tool"`
+	Topic        string    `json:"topic"`
+	Model        string    `json:"model,omitempty"`
+	Mode         string    `json:"mode"`
+	ProjectPath  string    `json:"projectPath"`
+	Status       string    `json:"status"`
+	SessionID    string    `json:"sessionId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	LastActivity time.Time `json:"lastActivity"`
+	MessageCount int       `json:"messageCount"`
+}
+
+// Message is a row of the messages table. Fields the server stores as
+// JSON metadata (tool input, approval prompts, ...) are in Metadata.
+type Message struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversationId"`
+	Type           string                 `json:"type"`
+	Role           string                 `json:"role,omitempty"`
+	Content        string                 `json:"content,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+	ToolID         string                 `json:"toolId,omitempty"`
+	ToolName       string                 `json:"toolName,omitempty"`
+	IsError        bool                   `json:"isError,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ConversationQuery filters and pages ListConversations. Empty fields
+// match everything; Limit 0 means no limit.
+type ConversationQuery struct {
+	// Project matches projectPath exactly or any path below it.
+	Project string
+	Status  string
+	// Since matches conversations active at or after this time.
+	Since  time.Time
+	Limit  int
+	Offset int
+}
+
+// MessageQuery filters and pages Messages. Streaming deltas are never
+// returned: the server also stores each finished block as one message.
+type MessageQuery struct {
+	// Kinds restricts messages to the given kinds; empty means all.
+	Kinds  []string
+	Limit  int
+	Offset int
+	// Last pages from the end of the conversation: Offset counts back
+	// from the newest message.
+	Last bool
+}
+
+// OpenHistory opens the chat database at path read-only.
+func OpenHistory(path string) (*History, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no chat history at %s", path)
+	} else if err != nil {
+		return nil, err
+	}
+	// The server keeps the database in WAL mode; wait for its writes
+	// instead of failing with SQLITE_BUSY.
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &History{db: db}, nil
+}
+
+// Close closes the database.
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+// messageFilter excludes streaming deltas, and live tool results the
+// server also stored in consolidated form.
+const messageFilter = `m.isPartial = 0 AND NOT (m.type = 'tool_use_result' AND m.id = m.toolId AND EXISTS (
+	SELECT 1 FROM messages d WHERE d.conversationId = m.conversationId AND d.type = 'tool_use_result'
+	AND d.toolId = m.toolId AND d.id <> m.id))`
+
+// ListConversations returns conversations matching q, most recently
+// active first.
+func (h *History) ListConversations(q ConversationQuery) ([]Conversation, error) {
+	var where []string
+	var args []interface{}
+	if q.Project != "" {
+		project := strings.TrimRight(q.Project, "/")
+		where = append(where, "(c.projectPath = ? OR c.projectPath LIKE ? ESCAPE '\\')")
+		args = append(args, project, escapeLike(project)+"/%")
+	}
+	if q.Status != "" {
+		where = append(where, "c.status = ?")
+		args = append(args, q.Status)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "c.lastActivity >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+
+	query := conversationSelect
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY c.lastActivity DESC" + limitClause(q.Limit, q.Offset)
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversations []Conversation
+	for rows.Next() {
+		c, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *c)
+	}
+	return conversations, rows.Err()
+}
+
+// Conversation returns the conversation with the given ID, or the only
+// one whose ID starts with it.
+func (h *History) Conversation(id string) (*Conversation, error) {
+	rows, err := h.db.Query(conversationSelect+" WHERE c.id = ? OR c.id LIKE ? ESCAPE '\\' LIMIT 3",
+		id, escapeLike(id)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*Conversation
+	for rows.Next() {
+		c, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c.ID == id {
+			return c, nil
+		}
+		matches = append(matches, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("conversation ID %q is ambiguous", id)
+}
+
+// Messages returns the messages of a conversation matching q, oldest
+// first.
+func (h *History) Messages(conversationID string, q MessageQuery) ([]Message, error) {
+	where := "m.conversationId = ? AND " + messageFilter
+	args := []interface{}{conversationID}
+	if len(q.Kinds) > 0 {
+		var kinds []string
+		for _, kind := range q.Kinds {
+			cond, ok := kindConditions[kind]
+			if !ok {
+				return nil, fmt.Errorf("unknown message kind %q (use %s)", kind, strings.Join(MessageKinds, ", "))
+			}
+			kinds = append(kinds, cond)
+		}
+		where += " AND (" + strings.Join(kinds, " OR ") + ")"
+	}
+
+	order := "ASC"
+	if q.Last {
+		order = "DESC"
+	}
+	query := `SELECT m.id, m.conversationId, m.type, m.role, m.content, m.timestamp, m.toolId, m.toolName,
+		m.isError, m.metadata FROM messages m WHERE ` + where +
+		" ORDER BY m.timestamp " + order + ", m.rowid " + order + limitClause(q.Limit, q.Offset)
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		var role, content, toolID, toolName, metadata sql.NullString
+		var timestamp int64
+		var isError sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Type, &role, &content, &timestamp, &toolID,
+			&toolName, &isError, &metadata); err != nil {
+			return nil, err
+		}
+		m.Role = role.String
+		m.Content = content.String
+		m.Timestamp = time.UnixMilli(timestamp)
+		m.ToolID = toolID.String
+		m.ToolName = toolName.String
+		m.IsError = isError.Int64 == 1
+		if metadata.String != "" {
+			// Unparseable metadata is skipped, as the server does
+			json.Unmarshal([]byte(metadata.String), &m.Metadata)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.Last {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, nil
+}
+
+// kindConditions maps message kinds to conditions on the messages table.
+// Failed tool results are both tool and error messages.
+var kindConditions = map[string]string{
+	KindText:     "m.type = 'text'",
+	KindThinking: "m.type = 'thinking'",
+	KindTool:     "m.type IN ('tool_use_start', 'tool_use_result')",
+	KindError:    "(m.type = 'error' OR m.isError = 1)",
+	KindEvent:    "(m.type NOT IN ('text', 'thinking', 'tool_use_start', 'tool_use_result', 'error') AND m.isError = 0)",
+}
+
+const conversationSelect = `SELECT c.id, c.tool, c.topic, c.model, c.mode, c.projectPath, c.status, c.sessionId,
+	c.createdAt, c.updatedAt, c.lastActivity,
+	(SELECT COUNT(*) FROM messages m WHERE m.conversationId = c.id AND ` + messageFilter + `)
+	FROM conversations c`
+
+func scanConversation(rows *sql.Rows) (*Conversation, error) {
+	var c Conversation
+	var model, sessionID sql.NullString
+	var createdAt, updatedAt, lastActivity int64
+	if err := rows.Scan(&c.ID, &c.Tool, &c.Topic, &model, &c.Mode, &c.ProjectPath, &c.Status, &sessionID,
+		&createdAt, &updatedAt, &lastActivity, &c.MessageCount); err != nil {
+		return nil, err
+	}
+	c.Model = model.String
+	c.SessionID = sessionID.String
+	c.CreatedAt = time.UnixMilli(createdAt)
+	c.UpdatedAt = time.UnixMilli(updatedAt)
+	c.LastActivity = time.UnixMilli(lastActivity)
+	return &c, nil
+}
+
+func limitClause(limit, offset int) string {
+	if limit <= 0 && offset <= 0 {
+		return ""
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,336 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chatSchema is the schema the server's ChatPersistenceStore creates.
+const chatSchema = `
+CREATE TABLE IF NOT EXISTS conversations (
+  id TEXT PRIMARY KEY,
+  tool TEXT NOT NULL,
+  topic TEXT NOT NULL,
+  model TEXT,
+  mode TEXT NOT NULL,
+  projectPath TEXT NOT NULL,
+  status TEXT NOT NULL,
+  createdAt INTEGER NOT NULL,
+  updatedAt INTEGER NOT NULL,
+  sessionId TEXT,
+  lastActivity INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+  id TEXT PRIMARY KEY,
+  conversationId TEXT NOT NULL,
+  type TEXT NOT NULL,
+  role TEXT,
+  content TEXT,
+  timestamp INTEGER NOT NULL,
+  isPartial INTEGER DEFAULT 0,
+  toolId TEXT,
+  toolName TEXT,
+  isError INTEGER DEFAULT 0,
+  metadata TEXT,
+  FOREIGN KEY (conversationId) REFERENCES conversations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversationId ON messages(conversationId);
+CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(conversationId, timestamp);
+CREATE INDEX IF NOT EXISTS idx_conversations_projectPath ON conversations(projectPath);
+CREATE INDEX IF NOT EXISTS idx_conversations_status ON conversations(status);
+CREATE INDEX IF NOT EXISTS idx_conversations_lastActivity ON conversations(lastActivity);
+`
+
+// baseTime is the timestamp test rows are relative to.
+var baseTime = time.UnixMilli(1700000000000)
+
+// testConversation is a conversations row; LastActivity is in seconds
+// after baseTime.
+type testConversation struct {
+	ID, ProjectPath, Status string
+	LastActivity            int
+}
+
+// testMessage is a messages row; Timestamp is in seconds after baseTime.
+type testMessage struct {
+	ID, ConversationID, Type, Role, Content string
+	Timestamp                               int
+	Partial, IsError                        bool
+	ToolID, ToolName, Metadata              string
+}
+
+// newChatDB creates chat-persistence.db with the server's schema in a
+// temporary directory, returning its path and a writable handle.
+func newChatDB(t *testing.T) (string, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chat-persistence.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(chatSchema); err != nil {
+		t.Fatal(err)
+	}
+	return path, db
+}
+
+func addConversations(t *testing.T, db *sql.DB, conversations ...testConversation) {
+	t.Helper()
+	for _, c := range conversations {
+		at := baseTime.Add(time.Duration(c.LastActivity) * time.Second).UnixMilli()
+		if _, err := db.Exec(`INSERT INTO conversations (id, tool, topic, model, mode, projectPath, status,
+			createdAt, updatedAt, sessionId, lastActivity) VALUES (?, 'claude', ?, NULL, 'agent', ?, ?, ?, ?, NULL, ?)`,
+			c.ID, "Topic of "+c.ID, c.ProjectPath, c.Status, baseTime.UnixMilli(), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// addMessages writes messages as the server does, with INSERT OR REPLACE.
+func addMessages(t *testing.T, db *sql.DB, messages ...testMessage) {
+	t.Helper()
+	for _, m := range messages {
+		var metadata interface{}
+		if m.Metadata != "" {
+			metadata = m.Metadata
+		}
+		if _, err := db.Exec(`INSERT OR REPLACE INTO messages (id, conversationId, type, role, content, timestamp,
+			isPartial, toolId, toolName, isError, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ID, m.ConversationID, m.Type, m.Role, m.Content,
+			baseTime.Add(time.Duration(m.Timestamp)*time.Second).UnixMilli(),
+			m.Partial, m.ToolID, m.ToolName, m.IsError, metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// sampleMessages is a conversation with every kind of message, a
+// streaming delta and a live tool result also stored consolidated.
+func sampleMessages(conversationID string) []testMessage {
+	return []testMessage{
+		{ID: "m1", Type: "text", Role: "user", Content: "list the files", Timestamp: 1},
+		{ID: "m2", Type: "text", Role: "assistant", Content: "Sur", Timestamp: 2, Partial: true},
+		{ID: "m3", Type: "text", Role: "assistant", Content: "Sure, listing them.", Timestamp: 3},
+		{ID: "m4", Type: "thinking", Content: "ls should do", Timestamp: 4},
+		{ID: "m5", Type: "tool_use_start", Content: `{"command":"ls"}`, Timestamp: 5, ToolID: "tool1", ToolName: "Bash"},
+		// The live result, stored under the tool's ID, and its consolidated copy
+		{ID: "tool1", Type: "tool_use_result", Content: "a.go", Timestamp: 6, ToolID: "tool1"},
+		{ID: "m7", Type: "tool_use_result", Content: "a.go\nb.go", Timestamp: 7, ToolID: "tool1"},
+		{ID: "m8", Type: "tool_use_start", Timestamp: 8, ToolID: "tool2", ToolName: "Read",
+			Metadata: `{"input":{"file_path":"c.go"}}`},
+		// A live result never consolidated is kept
+		{ID: "tool2", Type: "tool_use_result", Content: "ENOENT", Timestamp: 9, ToolID: "tool2", IsError: true},
+		{ID: "m10", Type: "error", Content: "rate limited", Timestamp: 10},
+		{ID: "m11", Type: "approval_request", Timestamp: 11, Metadata: `{"prompt":"Run ls?"}`},
+	}
+}
+
+// addSampleMessages adds sampleMessages to a conversation.
+func addSampleMessages(t *testing.T, db *sql.DB, conversationID string) {
+	t.Helper()
+	messages := sampleMessages(conversationID)
+	for i := range messages {
+		messages[i].ConversationID = conversationID
+	}
+	addMessages(t, db, messages...)
+}
+
+// openSampleHistory returns a history with the sample conversations,
+// abc123 holding sampleMessages.
+func openSampleHistory(t *testing.T) *History {
+	t.Helper()
+	path, db := newChatDB(t)
+	addConversations(t, db,
+		testConversation{"abc123", "/src/app", "active", 300},
+		testConversation{"abd456", "/src/app/sub", "ended", 200},
+		testConversation{"a_c789", "/src/app2", "active", 100},
+		testConversation{"a%b000", "/src/a_b", "ended", 50},
+		testConversation{"ab", "/src/axb/x", "active", 40},
+	)
+	addSampleMessages(t, db, "abc123")
+
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func messageIDs(messages []Message) []string {
+	ids := []string{}
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestListConversations(t *testing.T) {
+	h := openSampleHistory(t)
+
+	tests := []struct {
+		name  string
+		query ConversationQuery
+		want  []string
+	}{
+		{"all, most recent first", ConversationQuery{}, []string{"abc123", "abd456", "a_c789", "a%b000", "ab"}},
+		{"project and below", ConversationQuery{Project: "/src/app"}, []string{"abc123", "abd456"}},
+		{"project with a trailing slash", ConversationQuery{Project: "/src/app/"}, []string{"abc123", "abd456"}},
+		{"subproject", ConversationQuery{Project: "/src/app/sub"}, []string{"abd456"}},
+		// _ is a LIKE wildcard that would match /src/axb
+		{"LIKE characters are literal", ConversationQuery{Project: "/src/a_b"}, []string{"a%b000"}},
+		{"status", ConversationQuery{Status: "ended"}, []string{"abd456", "a%b000"}},
+		{"since", ConversationQuery{Since: baseTime.Add(200 * time.Second)}, []string{"abc123", "abd456"}},
+		{"limit and offset", ConversationQuery{Limit: 2, Offset: 1}, []string{"abd456", "a_c789"}},
+		{"offset only", ConversationQuery{Offset: 3}, []string{"a%b000", "ab"}},
+		{"no match", ConversationQuery{Project: "/elsewhere"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversations, err := h.ListConversations(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, c := range conversations {
+				got = append(got, c.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListConversations = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversation(t *testing.T) {
+	h := openSampleHistory(t)
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr string
+	}{
+		{id: "abc123", want: "abc123"},
+		{id: "abc", want: "abc123"},
+		// An exact match wins over the IDs it is a prefix of
+		{id: "ab", want: "ab"},
+		{id: "a_", want: "a_c789"},
+		{id: "a%", want: "a%b000"},
+		{id: "a", wantErr: "ambiguous"},
+		{id: "zz", wantErr: ErrConversationNotFound.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			c, err := h.Conversation(tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Conversation(%q) error = %v, want %q", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.ID != tt.want {
+				t.Errorf("Conversation(%q) = %s, want %s", tt.id, c.ID, tt.want)
+			}
+		})
+	}
+
+	c, err := h.Conversation("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MessageCount != 9 || c.ProjectPath != "/src/app" || !c.LastActivity.Equal(baseTime.Add(300*time.Second)) {
+		t.Errorf("Conversation = %+v, want 9 messages in /src/app", c)
+	}
+	if _, err := h.Conversation("zz"); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("error = %v, want ErrConversationNotFound", err)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	h := openSampleHistory(t)
+
+	tests := []struct {
+		name    string
+		query   MessageQuery
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "deltas and superseded live results are hidden",
+			query: MessageQuery{},
+			want:  []string{"m1", "m3", "m4", "m5", "m7", "m8", "tool2", "m10", "m11"},
+		},
+		{name: "text", query: MessageQuery{Kinds: []string{KindText}}, want: []string{"m1", "m3"}},
+		{name: "thinking", query: MessageQuery{Kinds: []string{KindThinking}}, want: []string{"m4"}},
+		{name: "tool", query: MessageQuery{Kinds: []string{KindTool}}, want: []string{"m5", "m7", "m8", "tool2"}},
+		{name: "failed tool results are errors", query: MessageQuery{Kinds: []string{KindError}}, want: []string{"tool2", "m10"}},
+		{name: "event", query: MessageQuery{Kinds: []string{KindEvent}}, want: []string{"m11"}},
+		{name: "several kinds", query: MessageQuery{Kinds: []string{KindText, KindError}}, want: []string{"m1", "m3", "tool2", "m10"}},
+		{name: "unknown kind", query: MessageQuery{Kinds: []string{"text", "image"}}, wantErr: `unknown message kind "image"`},
+		{name: "limit and offset", query: MessageQuery{Limit: 2, Offset: 1}, want: []string{"m3", "m4"}},
+		{name: "last, oldest first", query: MessageQuery{Limit: 2, Last: true}, want: []string{"m10", "m11"}},
+		{name: "last with offset", query: MessageQuery{Limit: 2, Offset: 1, Last: true}, want: []string{"tool2", "m10"}},
+		{name: "last of a kind", query: MessageQuery{Kinds: []string{KindTool}, Limit: 1, Last: true}, want: []string{"tool2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := h.Messages("abc123", tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Messages error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messageIDs(messages); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessagesFields(t *testing.T) {
+	h := openSampleHistory(t)
+	messages, err := h.Messages("abc123", MessageQuery{Kinds: []string{KindTool, KindEvent}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]Message{}
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+
+	if m := byID["m5"]; m.ToolID != "tool1" || m.ToolName != "Bash" || m.Content != `{"command":"ls"}` ||
+		!m.Timestamp.Equal(baseTime.Add(5*time.Second)) {
+		t.Errorf("tool call = %+v", m)
+	}
+	if m := byID["tool2"]; !m.IsError || m.Content != "ENOENT" {
+		t.Errorf("failed tool result = %+v", m)
+	}
+	input, _ := byID["m8"].Metadata["input"].(map[string]interface{})
+	if input["file_path"] != "c.go" {
+		t.Errorf("metadata = %v, want the tool input", byID["m8"].Metadata)
+	}
+	if byID["m11"].Metadata["prompt"] != "Run ls?" {
+		t.Errorf("metadata = %v, want the prompt", byID["m11"].Metadata)
+	}
+}
+
+func TestOpenHistoryMissing(t *testing.T) {
+	if _, err := OpenHistory(filepath.Join(t.TempDir(), "chat-persistence.db")); err == nil {
+		t.Error("OpenHistory succeeded without a database")
+	}
+}
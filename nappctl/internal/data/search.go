@@ -0,0 +1,301 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// searchSchemaVersion is bumped whenever the index schema or what gets
+// indexed changes; an index with another version is rebuilt.
+const searchSchemaVersion = 1
+
+// SearchIndex is a full-text index over the content of the messages in
+// chat-persistence.db. It lives in its own database, owned by nappctl,
+// so the server's schema is never altered; the chat database is attached
+// read-only.
+type SearchIndex struct {
+	db *sql.DB
+}
+
+// SearchQuery filters and pages Search. Empty fields match everything;
+// Limit 0 means no limit.
+type SearchQuery struct {
+	// Text is matched against message content. Each word must occur;
+	// with Raw it is passed to SQLite FTS5 as a query expression.
+	Text string
+	Raw  bool
+	// Project matches projectPath exactly or any path below it.
+	Project string
+	// Tool matches the conversation's tool (e.g. claude, cursor-agent).
+	Tool   string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+	// HighlightStart and HighlightEnd surround the matched terms in
+	// SearchHit.Snippet.
+	HighlightStart string
+	HighlightEnd   string
+}
+
+// SearchHit is a message matching a SearchQuery, with its conversation.
+type SearchHit struct {
+	MessageID      string    `json:"messageId"`
+	ConversationID string    `json:"conversationId"`
+	Topic          string    `json:"topic"`
+	ProjectPath    string    `json:"projectPath"`
+	Tool           string    `json:"tool"`
+	Type           string    `json:"type"`
+	Role           string    `json:"role,omitempty"`
+	ToolName       string    `json:"toolName,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+	Snippet        string    `json:"snippet"`
+	// Score is the BM25 relevance of the hit; lower is better.
+	Score float64 `json:"score"`
+}
+
+// OpenSearchIndex opens the search index at indexPath, creating it if
+// needed, and attaches the chat database at dbPath read-only. Call Sync
+// to bring the index up to date before searching.
+func OpenSearchIndex(indexPath, dbPath string) (*SearchIndex, error) {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no chat history at %s", dbPath)
+	} else if err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + (&url.URL{Path: indexPath}).EscapedPath() + "?_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// ATTACH is per connection, so keep to one
+	db.SetMaxOpenConns(1)
+
+	chat := "file:" + (&url.URL{Path: dbPath}).EscapedPath() + "?mode=ro"
+	if _, err := db.Exec("ATTACH DATABASE ? AS chat", chat); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", dbPath, err)
+	}
+
+	s := &SearchIndex{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open search index %s: %w", indexPath, err)
+	}
+	return s, nil
+}
+
+// Close closes the index and detaches the chat database.
+func (s *SearchIndex) Close() error {
+	return s.db.Close()
+}
+
+// migrate creates the index tables, dropping an index built by another
+// version of nappctl.
+func (s *SearchIndex) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA main.user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version == searchSchemaVersion {
+		return nil
+	}
+	_, err := s.db.Exec(fmt.Sprintf(`
+		DROP TABLE IF EXISTS main.message_fts;
+		DROP TABLE IF EXISTS main.indexed_messages;
+		CREATE VIRTUAL TABLE main.message_fts USING fts5(content, tokenize = 'porter unicode61');
+		CREATE TABLE main.indexed_messages (rowid INTEGER PRIMARY KEY, id TEXT NOT NULL);
+		PRAGMA main.user_version = %d;`, searchSchemaVersion))
+	return err
+}
+
+// indexable selects the messages of chat.messages m that belong in the
+// index. Streaming deltas are never indexed: the server also stores each
+// finished block as one message.
+const indexable = "m.isPartial = 0 AND m.content <> ''"
+
+// Sync indexes messages added to the chat database since the last sync
+// and drops messages that were deleted or rewritten. It returns the
+// number of messages added.
+//
+// The server only ever writes messages with INSERT OR REPLACE, which
+// gives a rewritten message a new rowid, so a message is unchanged as
+// long as its rowid still holds the same ID, and new messages always have
+// a rowid above every surviving indexed one. Index rowids are the chat
+// database's message rowids. Messages have a TEXT primary key, so those
+// rowids are implicit and VACUUM may renumber them; when Sync finds the
+// index no longer covers every message it rebuilds it, returning the
+// number of messages indexed.
+func (s *SearchIndex) Sync() (int, error) {
+	added, complete, err := s.sync()
+	if err != nil {
+		return 0, err
+	}
+	if !complete {
+		return s.Rebuild()
+	}
+	return added, nil
+}
+
+// sync does the work of Sync in one transaction. If afterwards the index
+// does not hold exactly the indexable messages, which happens when their
+// rowids were renumbered, it reports the index incomplete and rolls back.
+func (s *SearchIndex) sync() (added int, complete bool, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	const stale = `SELECT i.rowid FROM main.indexed_messages i WHERE NOT EXISTS (
+		SELECT 1 FROM chat.messages m WHERE m.rowid = i.rowid AND m.id = i.id)`
+	if _, err := tx.Exec("DELETE FROM main.message_fts WHERE rowid IN (" + stale + ")"); err != nil {
+		return 0, false, err
+	}
+	if _, err := tx.Exec("DELETE FROM main.indexed_messages WHERE rowid IN (" + stale + ")"); err != nil {
+		return 0, false, err
+	}
+
+	const fresh = "FROM chat.messages m WHERE " + indexable + `
+		AND m.rowid > (SELECT COALESCE(MAX(rowid), 0) FROM main.indexed_messages)`
+	if _, err := tx.Exec("INSERT INTO main.message_fts (rowid, content) SELECT m.rowid, m.content " + fresh); err != nil {
+		return 0, false, err
+	}
+	res, err := tx.Exec("INSERT INTO main.indexed_messages (rowid, id) SELECT m.rowid, m.id " + fresh)
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, false, err
+	}
+
+	// Every indexed message was just checked against the chat database,
+	// so the counts differ only if indexable messages were left out
+	if err := tx.QueryRow(`SELECT (SELECT COUNT(*) FROM main.indexed_messages) =
+		(SELECT COUNT(*) FROM chat.messages m WHERE ` + indexable + ")").Scan(&complete); err != nil {
+		return 0, false, err
+	}
+	if !complete {
+		return 0, false, nil
+	}
+	return int(n), true, tx.Commit()
+}
+
+// Rebuild drops the index and indexes every message again, returning the
+// number of messages indexed.
+func (s *SearchIndex) Rebuild() (int, error) {
+	if _, err := s.db.Exec("PRAGMA main.user_version = 0"); err != nil {
+		return 0, err
+	}
+	if err := s.migrate(); err != nil {
+		return 0, err
+	}
+	added, complete, err := s.sync()
+	if err == nil && !complete {
+		err = fmt.Errorf("search index does not match the chat database")
+	}
+	return added, err
+}
+
+// Search returns the indexed messages matching q, best match first.
+// Messages hidden from History.Messages, such as live tool results the
+// server also stored in consolidated form, are never returned.
+func (s *SearchIndex) Search(q SearchQuery) ([]SearchHit, error) {
+	match := q.Text
+	if !q.Raw {
+		match = matchExpression(q.Text)
+	}
+	if match == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+
+	where := []string{"message_fts MATCH ?", messageFilter}
+	args := []interface{}{q.HighlightStart, q.HighlightEnd, match}
+	if q.Project != "" {
+		project := strings.TrimRight(q.Project, "/")
+		where = append(where, "(c.projectPath = ? OR c.projectPath LIKE ? ESCAPE '\\')")
+		args = append(args, project, escapeLike(project)+"/%")
+	}
+	if q.Tool != "" {
+		where = append(where, "c.tool = ?")
+		args = append(args, q.Tool)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "m.timestamp >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "m.timestamp < ?")
+		args = append(args, q.Until.UnixMilli())
+	}
+
+	query := `SELECT m.id, c.id, c.topic, c.projectPath, c.tool, m.type, m.role, m.toolName, m.timestamp,
+		snippet(message_fts, 0, ?, ?, '…', 24), bm25(message_fts)
+		FROM main.message_fts
+		JOIN chat.messages m ON m.rowid = message_fts.rowid
+		JOIN chat.conversations c ON c.id = m.conversationId
+		WHERE ` + strings.Join(where, " AND ") +
+		" ORDER BY bm25(message_fts), m.timestamp DESC" + limitClause(q.Limit, q.Offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, searchError(q, err)
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var h SearchHit
+		var role, toolName sql.NullString
+		var timestamp int64
+		if err := rows.Scan(&h.MessageID, &h.ConversationID, &h.Topic, &h.ProjectPath, &h.Tool, &h.Type,
+			&role, &toolName, &timestamp, &h.Snippet, &h.Score); err != nil {
+			return nil, err
+		}
+		h.Role = role.String
+		h.ToolName = toolName.String
+		h.Timestamp = time.UnixMilli(timestamp)
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, searchError(q, err)
+	}
+	return hits, nil
+}
+
+// searchError reports an error running q. FTS5 parses the match
+// expression when the first row is read, so syntax errors can come from
+// either the query or the rows. Not all of them mention fts5 (e.g.
+// "unterminated string"), but only raw queries can have one.
+func searchError(q SearchQuery, err error) error {
+	if q.Raw || strings.Contains(err.Error(), "fts5") {
+		return fmt.Errorf("invalid search query %q: %w", q.Text, err)
+	}
+	return err
+}
+
+// matchExpression turns plain words into an FTS5 query requiring each of
+// them, quoting them so punctuation is not read as query syntax. A
+// trailing * is kept as a prefix match.
+func matchExpression(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
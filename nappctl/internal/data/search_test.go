@@ -0,0 +1,265 @@
+package data
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSearchIndex opens an index over a temp chat database holding
+// conversation abc123 with sampleMessages, returning the index and a
+// writable handle on the chat database.
+func newSearchIndex(t *testing.T) (*SearchIndex, *sql.DB) {
+	t.Helper()
+	path, db := newChatDB(t)
+	addConversations(t, db, testConversation{"abc123", "/src/app", "active", 300})
+	addSampleMessages(t, db, "abc123")
+
+	s, err := OpenSearchIndex(filepath.Join(t.TempDir(), "search.db"), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, db
+}
+
+func mustSync(t *testing.T, s *SearchIndex, want int) {
+	t.Helper()
+	added, err := s.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != want {
+		t.Errorf("Sync added %d messages, want %d", added, want)
+	}
+}
+
+// searchIDs returns the IDs of the messages matching q, sorted.
+func searchIDs(t *testing.T, s *SearchIndex, q SearchQuery) []string {
+	t.Helper()
+	hits, err := s.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, h := range hits {
+		ids = append(ids, h.MessageID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSearchSync(t *testing.T) {
+	s, db := newSearchIndex(t)
+
+	// Deltas and messages without content are left out
+	mustSync(t, s, 8)
+	mustSync(t, s, 0)
+
+	addMessages(t, db, testMessage{ID: "m12", ConversationID: "abc123", Type: "text", Role: "user",
+		Content: "deploy the widgets", Timestamp: 12})
+	mustSync(t, s, 1)
+	if got := searchIDs(t, s, SearchQuery{Text: "widgets"}); !reflect.DeepEqual(got, []string{"m12"}) {
+		t.Errorf("new message: hits = %q, want m12", got)
+	}
+
+	// The server rewrites messages with INSERT OR REPLACE
+	addMessages(t, db, testMessage{ID: "m1", ConversationID: "abc123", Type: "text", Role: "user",
+		Content: "list the gadgets", Timestamp: 1})
+	mustSync(t, s, 1)
+	if got := searchIDs(t, s, SearchQuery{Text: "files"}); len(got) != 0 {
+		t.Errorf("rewritten message: old content still hits %q", got)
+	}
+	if got := searchIDs(t, s, SearchQuery{Text: "gadgets"}); !reflect.DeepEqual(got, []string{"m1"}) {
+		t.Errorf("rewritten message: hits = %q, want m1", got)
+	}
+
+	if _, err := db.Exec("DELETE FROM messages WHERE id = 'm3'"); err != nil {
+		t.Fatal(err)
+	}
+	mustSync(t, s, 0)
+	if got := searchIDs(t, s, SearchQuery{Text: "sure"}); len(got) != 0 {
+		t.Errorf("deleted message still hits: %q", got)
+	}
+
+	addMessages(t, db, testMessage{ID: "m13", ConversationID: "abc123", Type: "text", Role: "assistant",
+		Content: "zebra", Timestamp: 13, Partial: true})
+	mustSync(t, s, 0)
+	if got := searchIDs(t, s, SearchQuery{Text: "zebra"}); len(got) != 0 {
+		t.Errorf("delta was indexed: %q", got)
+	}
+}
+
+func TestSearchSyncRebuildsRenumberedRowids(t *testing.T) {
+	s, db := newSearchIndex(t)
+	mustSync(t, s, 8)
+
+	// VACUUM may renumber the implicit rowids of messages. Swapping two
+	// below the highest indexed rowid leaves both stale in the index
+	// without making them new.
+	var r1, r3 int64
+	if err := db.QueryRow("SELECT rowid FROM messages WHERE id = 'm1'").Scan(&r1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT rowid FROM messages WHERE id = 'm3'").Scan(&r3); err != nil {
+		t.Fatal(err)
+	}
+	for _, swap := range []struct {
+		id    string
+		rowid int64
+	}{{"m1", -1}, {"m3", r1}, {"m1", r3}} {
+		if _, err := db.Exec("UPDATE messages SET rowid = ? WHERE id = ?", swap.rowid, swap.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A rebuild reports every message indexed
+	mustSync(t, s, 8)
+	if got := searchIDs(t, s, SearchQuery{Text: "files"}); !reflect.DeepEqual(got, []string{"m1"}) {
+		t.Errorf("after renumbering: hits = %q, want m1", got)
+	}
+	if got := searchIDs(t, s, SearchQuery{Text: "sure"}); !reflect.DeepEqual(got, []string{"m3"}) {
+		t.Errorf("after renumbering: hits = %q, want m3", got)
+	}
+	mustSync(t, s, 0)
+}
+
+func TestSearchRebuild(t *testing.T) {
+	s, _ := newSearchIndex(t)
+	mustSync(t, s, 8)
+
+	n, err := s.Rebuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 {
+		t.Errorf("Rebuild indexed %d messages, want 8", n)
+	}
+	if got := searchIDs(t, s, SearchQuery{Text: "files"}); !reflect.DeepEqual(got, []string{"m1"}) {
+		t.Errorf("after Rebuild: hits = %q, want m1", got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s, db := newSearchIndex(t)
+	addConversations(t, db,
+		testConversation{"a_c789", "/src/app2", "active", 100},
+		testConversation{"abd456", "/src/app/sub", "ended", 200},
+	)
+	if _, err := db.Exec("UPDATE conversations SET tool = 'cursor-agent' WHERE id = 'a_c789'"); err != nil {
+		t.Fatal(err)
+	}
+	addMessages(t, db,
+		testMessage{ID: "n1", ConversationID: "a_c789", Type: "text", Role: "user", Content: "list the files again", Timestamp: 20},
+		testMessage{ID: "n2", ConversationID: "abd456", Type: "text", Role: "user", Content: "files in sub", Timestamp: 30},
+	)
+	mustSync(t, s, 10)
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string
+	}{
+		{"all", SearchQuery{Text: "files"}, []string{"m1", "n1", "n2"}},
+		{"every word", SearchQuery{Text: "files again"}, []string{"n1"}},
+		{"case-insensitive", SearchQuery{Text: "FILES"}, []string{"m1", "n1", "n2"}},
+		{"stemmed", SearchQuery{Text: "listed"}, []string{"m1", "m3", "n1"}},
+		{"prefix", SearchQuery{Text: "fil*"}, []string{"m1", "n1", "n2"}},
+		{"punctuation is not syntax", SearchQuery{Text: "b.go"}, []string{"m7"}},
+		// The live tool1 result also holds a.go but is hidden
+		{"superseded live results", SearchQuery{Text: "a.go"}, []string{"m7"}},
+		{"raw", SearchQuery{Text: "files OR sure", Raw: true}, []string{"m1", "m3", "n1", "n2"}},
+		{"project and below", SearchQuery{Text: "files", Project: "/src/app"}, []string{"m1", "n2"}},
+		{"LIKE characters are literal", SearchQuery{Text: "files", Project: "/src/app_"}, []string{}},
+		{"tool", SearchQuery{Text: "files", Tool: "cursor-agent"}, []string{"n1"}},
+		{"since", SearchQuery{Text: "files", Since: baseTime.Add(20 * time.Second)}, []string{"n1", "n2"}},
+		{"until", SearchQuery{Text: "files", Until: baseTime.Add(20 * time.Second)}, []string{"m1"}},
+		{"limit", SearchQuery{Text: "files", Limit: 1, Offset: 2}, nil},
+		{"no match", SearchQuery{Text: "nothing"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchIDs(t, s, tt.query)
+			if tt.want == nil {
+				if len(got) != 1 {
+					t.Errorf("hits = %q, want one", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hits = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	hits, err := s.Search(SearchQuery{Text: "files", Tool: "claude", Project: "/src/app/sub",
+		HighlightStart: "[", HighlightEnd: "]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SearchHit{MessageID: "n2", ConversationID: "abd456", Topic: "Topic of abd456", ProjectPath: "/src/app/sub",
+		Tool: "claude", Type: "text", Role: "user", Timestamp: baseTime.Add(30 * time.Second), Snippet: "[files] in sub"}
+	if len(hits) != 1 {
+		t.Fatalf("hits = %+v, want n2", hits)
+	}
+	hits[0].Score = 0
+	if !hits[0].Timestamp.Equal(want.Timestamp) {
+		t.Errorf("timestamp = %v, want %v", hits[0].Timestamp, want.Timestamp)
+	}
+	hits[0].Timestamp = want.Timestamp
+	if hits[0] != want {
+		t.Errorf("hit = %+v, want %+v", hits[0], want)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	s, _ := newSearchIndex(t)
+	mustSync(t, s, 8)
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  string
+	}{
+		{"empty", SearchQuery{Text: "  "}, "empty search query"},
+		{"only wildcards", SearchQuery{Text: "* **"}, "empty search query"},
+		{"invalid raw query", SearchQuery{Text: "files AND", Raw: true}, "invalid search query"},
+		{"unterminated raw string", SearchQuery{Text: `"unterminated`, Raw: true}, "invalid search query"},
+		{"unknown column", SearchQuery{Text: "topic:files", Raw: true}, "invalid search query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Search(tt.query); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Search error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := OpenSearchIndex(filepath.Join(t.TempDir(), "search.db"), filepath.Join(t.TempDir(), "missing.db")); err == nil ||
+		!strings.Contains(err.Error(), "no chat history") {
+		t.Errorf("OpenSearchIndex error = %v, want no chat history", err)
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"files", `"files"`},
+		{"list  the files", `"list" "the" "files"`},
+		{"a.go OR b", `"a.go" "OR" "b"`},
+		{`say "hi"`, `"say" """hi"""`},
+		{"dep*", `"dep"*`},
+		{"* ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := matchExpression(tt.text); got != tt.want {
+			t.Errorf("matchExpression(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Levels lists the server's log levels from least to most severe.
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// LevelRank returns the severity of a level (higher is more severe), or -1
+// for an unknown level.
+func LevelRank(level string) int {
+	for i, l := range Levels {
+		if l == strings.ToLower(level) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Entry is one structured log line written by the server's LogManager.
+type Entry struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Level     string          `json:"level"`
+	Category  string          `json:"category"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data,omitempty"`
+
+	// Raw is the original line. For lines that are not JSON (e.g. console
+	// output captured in server.log) it is the only field set.
+	Raw string `json:"-"`
+}
+
+// Structured reports whether the entry was parsed from a JSON log line.
+func (e *Entry) Structured() bool {
+	return e.Level != ""
+}
+
+// ParseLine parses a log line. Lines that are not LogManager JSON are
+// returned as unstructured entries carrying only Raw and Message.
+func ParseLine(line string) *Entry {
+	line = strings.TrimRight(line, "\r\n")
+
+	var entry Entry
+	if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &entry) == nil && entry.Level != "" {
+		entry.Raw = line
+		return &entry
+	}
+	return &Entry{Message: line, Raw: line}
+}
+
+// Filter selects log entries. Zero-valued fields match everything.
+type Filter struct {
+	// MinLevel drops entries below this level.
+	MinLevel string
+	// Category keeps entries whose category contains this substring.
+	Category string
+	// Since and Until bound the entry timestamp (inclusive).
+	Since time.Time
+	Until time.Time
+	// Grep keeps entries whose message or data match.
+	Grep *regexp.Regexp
+}
+
+// structuredOnly reports whether the filter depends on fields that only
+// structured entries carry.
+func (f *Filter) structuredOnly() bool {
+	return f.MinLevel != "" || f.Category != "" || !f.Since.IsZero() || !f.Until.IsZero()
+}
+
+// Match reports whether the entry passes the filter.
+func (f *Filter) Match(e *Entry) bool {
+	if !e.Structured() {
+		if f.structuredOnly() {
+			return false
+		}
+		return f.Grep == nil || f.Grep.MatchString(e.Raw)
+	}
+
+	if f.MinLevel != "" && LevelRank(e.Level) < LevelRank(f.MinLevel) {
+		return false
+	}
+	if f.Category != "" && !strings.Contains(strings.ToLower(e.Category), strings.ToLower(f.Category)) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.Grep != nil && !f.Grep.MatchString(e.Message) && !f.Grep.Match(e.Data) {
+		return false
+	}
+	return true
+}
+
+// ParseTime parses a --since/--until value: an RFC 3339 timestamp, a
+// YYYY-MM-DD date (local midnight), or a duration such as "90m" meaning
+// that long before now.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use RFC 3339, YYYY-MM-DD or a duration like 1h)", value)
+}
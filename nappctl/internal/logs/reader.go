@@ -0,0 +1,272 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/server"
+)
+
+// dailyFilePattern matches the LogManager's daily rotated files.
+var dailyFilePattern = regexp.MustCompile(`^server-(\d{4}-\d{2}-\d{2})\.log$`)
+
+// maxLineSize bounds a single log line; entries with large data payloads
+// can be well beyond bufio's default 64KB.
+const maxLineSize = 4 * 1024 * 1024
+
+// pollInterval is how often Follow checks for new lines and rotation.
+const pollInterval = 250 * time.Millisecond
+
+// Source is where log lines are read from: either a directory of daily
+// server-YYYY-MM-DD.log files, or a single file such as server.log.
+type Source struct {
+	Dir  string
+	File string
+}
+
+// String describes the source for messages.
+func (s Source) String() string {
+	if s.File != "" {
+		return s.File
+	}
+	return filepath.Join(s.Dir, "server-*.log")
+}
+
+// files returns the files of the source, oldest first.
+func (s Source) files() ([]string, error) {
+	if s.File != "" {
+		if _, err := os.Stat(s.File); err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return []string{s.File}, nil
+	}
+	return DailyFiles(s.Dir)
+}
+
+// DailyFiles returns the daily log files in dir, oldest first.
+func DailyFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read log directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && dailyFilePattern.MatchString(entry.Name()) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// YYYY-MM-DD names sort chronologically
+	sort.Strings(files)
+	return files, nil
+}
+
+// ResolveDir returns the directory holding the server's structured daily
+// logs. The LogManager writes them under the server installation's
+// .napp-trapp-data/logs; the data directory's logs folder is also checked.
+// The candidate with the most recent daily file wins.
+func ResolveDir(dataDir string) string {
+	candidates := []string{config.GetLogDir(dataDir)}
+	if serverDir, err := server.FindServerPath(); err == nil {
+		candidates = append([]string{filepath.Join(serverDir, ".napp-trapp-data", "logs")}, candidates...)
+	}
+
+	best, bestFile := candidates[0], ""
+	for _, dir := range candidates {
+		files, err := DailyFiles(dir)
+		if err != nil || len(files) == 0 {
+			continue
+		}
+		if latest := filepath.Base(files[len(files)-1]); latest > bestFile {
+			best, bestFile = dir, latest
+		}
+	}
+	return best
+}
+
+// Tail returns the last n entries of the source that match filter, oldest
+// first. n <= 0 returns every matching entry.
+func Tail(src Source, n int, filter *Filter) ([]*Entry, error) {
+	files, err := src.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Entry
+	// Walk files newest first so we can stop once n entries are collected
+	for i := len(files) - 1; i >= 0; i-- {
+		if src.File == "" && skipDailyFile(files[i], filter) {
+			continue
+		}
+
+		entries, err := readFile(files[i], filter)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(entries, result...)
+		if n > 0 && len(result) >= n {
+			return result[len(result)-n:], nil
+		}
+	}
+	return result, nil
+}
+
+// skipDailyFile reports whether a daily file lies entirely outside the
+// filter's time range. Files are named by UTC date.
+func skipDailyFile(path string, filter *Filter) bool {
+	m := dailyFilePattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return false
+	}
+	day, err := time.Parse("2006-01-02", m[1])
+	if err != nil {
+		return false
+	}
+	if !filter.Since.IsZero() && day.Add(24*time.Hour).Before(filter.Since) {
+		return true
+	}
+	if !filter.Until.IsZero() && day.After(filter.Until) {
+		return true
+	}
+	return false
+}
+
+// readFile returns the matching entries of a single file.
+func readFile(path string, filter *Filter) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if entry := ParseLine(scanner.Text()); filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Follow calls fn for every matching entry appended to the source until
+// ctx is cancelled. For a daily source it moves on to the next day's file
+// when the LogManager rotates, after draining the previous one. Truncated
+// files are re-read from the start.
+func Follow(ctx context.Context, src Source, filter *Filter, fn func(*Entry)) error {
+	var (
+		current string
+		offset  int64
+		partial []byte
+	)
+
+	// Start at the end of the newest file so only new lines are reported
+	if files, err := src.files(); err != nil {
+		return err
+	} else if len(files) > 0 {
+		current = files[len(files)-1]
+		if info, err := os.Stat(current); err == nil {
+			offset = info.Size()
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if current != "" {
+			var err error
+			offset, partial, err = readFrom(current, offset, partial, func(line string) {
+				if entry := ParseLine(line); filter.Match(entry) {
+					fn(entry)
+				}
+			})
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		files, err := src.files()
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 && files[len(files)-1] != current {
+			// Rotated (or first file appeared): the old file was drained above
+			current, offset, partial = files[len(files)-1], 0, nil
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// readFrom reads complete lines appended to path after offset and returns
+// the new offset plus any trailing partial line.
+func readFrom(path string, offset int64, partial []byte, emit func(string)) (int64, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, partial, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return offset, partial, err
+	}
+	if info.Size() < offset {
+		// Truncated (e.g. by `nappctl data clean`): start over
+		offset, partial = 0, nil
+	}
+	if info.Size() == offset {
+		return offset, partial, nil
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, partial, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return offset, partial, err
+	}
+	offset += int64(len(data))
+
+	data = append(partial, data...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if line := data[:i]; len(line) > 0 {
+			emit(string(line))
+		}
+		data = data[i+1:]
+	}
+	return offset, append([]byte(nil), data...), nil
+}
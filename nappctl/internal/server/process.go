@@ -1,211 +1,445 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/auth"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
 	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
 )
 
-// StartServer starts the Napp Trapp server
-func StartServer(port int, token string, foreground bool) error {
-	dataDir, err := config.ResolveDataDir()
-	if err != nil {
-		return fmt.Errorf("failed to resolve data directory: %w", err)
+const (
+	// DefaultPort is the port the server listens on when none is given.
+	DefaultPort = 3847
+
+	// DefaultStopTimeout is how long Stop waits for a graceful exit.
+	DefaultStopTimeout = 30 * time.Second
+
+	// DefaultReadyTimeout is how long WaitReady waits for /health by default.
+	DefaultReadyTimeout = 30 * time.Second
+
+	// DefaultLogFile is the log file name (inside the logs directory)
+	// that receives the server's stdout and stderr in daemon mode.
+	DefaultLogFile = "server.log"
+)
+
+var (
+	// ErrNotRunning is returned when an operation requires a running server.
+	ErrNotRunning = errors.New("server is not running")
+
+	// ErrStopTimeout is returned when the server does not exit within the
+	// stop timeout and escalation is disabled.
+	ErrStopTimeout = errors.New("timeout waiting for server to stop (use --force for SIGKILL)")
+
+	// ErrExited is returned by WaitReady when the server process dies
+	// before it starts answering health checks.
+	ErrExited = errors.New("server exited before becoming healthy")
+
+	// ErrNotReady is returned by WaitReady when the server is still
+	// running but has not passed a health check within the timeout.
+	ErrNotReady = errors.New("server did not become healthy in time")
+)
+
+// Options configures how a Supervisor launches and stops the server.
+// Zero values are replaced with sensible defaults by NewSupervisor.
+type Options struct {
+	// DataDir is the Napp Trapp data directory (default: config.ResolveDataDir).
+	DataDir string
+
+	// Port is passed to the server as PORT.
+	Port int
+
+	// Token, when non-empty, is injected as AUTH_TOKEN and overrides the
+	// token persisted in auth.json.
+	Token string
+
+	// Command overrides the node + server script command line. When empty
+	// the supervisor resolves it with FindNodeJS and FindNappTrappServer.
+	Command []string
+
+	// Env holds extra environment variables (KEY=VALUE) for the child.
+	Env []string
+
+	// LogPath receives stdout/stderr in daemon mode (default: logs/server.log).
+	LogPath string
+
+	// Stdout and Stderr receive output in foreground mode (default: os.Stdout/os.Stderr).
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// StopSignal is sent to request a graceful shutdown. The Node server
+	// only handles SIGINT, so that is the default.
+	StopSignal syscall.Signal
+
+	// StopTimeout is how long Stop waits after StopSignal (default: DefaultStopTimeout).
+	StopTimeout time.Duration
+
+	// Escalate sends SIGKILL when StopTimeout elapses instead of failing.
+	Escalate bool
+}
+
+// Supervisor owns the lifecycle of the Napp Trapp server process and its
+// PID file. Every `nappctl server` subcommand goes through it.
+type Supervisor struct {
+	opts    Options
+	pidPath string
+}
+
+// NewSupervisor creates a Supervisor, filling in defaults for unset options.
+func NewSupervisor(opts Options) (*Supervisor, error) {
+	if opts.DataDir == "" {
+		dataDir, err := config.ResolveDataDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve data directory: %w", err)
+		}
+		opts.DataDir = dataDir
+	}
+	if opts.Port == 0 {
+		opts.Port = DefaultPort
+	}
+	if opts.LogPath == "" {
+		opts.LogPath = filepath.Join(config.GetLogDir(opts.DataDir), DefaultLogFile)
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
 	}
+	if opts.StopSignal == 0 {
+		opts.StopSignal = syscall.SIGINT
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = DefaultStopTimeout
+	}
+
+	return &Supervisor{
+		opts:    opts,
+		pidPath: config.GetPIDPath(opts.DataDir),
+	}, nil
+}
+
+// DataDir returns the data directory the supervisor operates on.
+func (s *Supervisor) DataDir() string {
+	return s.opts.DataDir
+}
+
+// PIDPath returns the path of the PID file.
+func (s *Supervisor) PIDPath() string {
+	return s.pidPath
+}
 
-	// Check if server is already running
-	pidPath := config.GetPIDPath(dataDir)
-	if pidfile.IsRunning(pidPath) {
-		return fmt.Errorf("server is already running")
+// LogPath returns the path of the daemon log file.
+func (s *Supervisor) LogPath() string {
+	return s.opts.LogPath
+}
+
+// Port returns the port the server is started on.
+func (s *Supervisor) Port() int {
+	return s.opts.Port
+}
+
+// Start launches the server in the background, detached from the current
+// process group, and records it in the PID file.
+func (s *Supervisor) Start() (*pidfile.PIDInfo, error) {
+	if err := s.checkNotRunning(); err != nil {
+		return nil, err
 	}
 
-	// Find Node.js binary
-	nodePath, err := exec.LookPath("node")
+	cmd, err := s.command()
 	if err != nil {
-		return fmt.Errorf("node not found in PATH: %w", err)
+		return nil, err
 	}
 
-	// Find server path
-	serverPath, err := FindServerPath()
+	if err := os.MkdirAll(filepath.Dir(s.opts.LogPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logFile, err := os.OpenFile(s.opts.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to find server: %w", err)
+		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
+	// The child keeps its own descriptor, so ours can be closed once it has started
+	defer logFile.Close()
 
-	// Build command
-	entryPoint := fmt.Sprintf("%s/src/index.js", serverPath)
-	cmd := exec.Command(nodePath, entryPoint)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	// Set environment variables
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("PORT=%d", port),
-		fmt.Sprintf("NAPPTRAPP_DATA_DIR=%s", dataDir),
-		"NAPPTRAPP_CLI=true",
-	)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start server: %w", err)
+	}
 
-	if token != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("AUTH_TOKEN=%s", token))
+	if err := pidfile.Write(s.pidPath, cmd.Process.Pid, s.opts.Port, s.opts.DataDir); err != nil {
+		// Don't leave an untracked server behind
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
 	}
 
-	// Configure stdio
-	if foreground {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-	} else {
-		// Daemon mode: redirect to log file
-		logPath := fmt.Sprintf("%s/logs/nappctl.log", dataDir)
-		logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to open log file: %w", err)
-		}
-		cmd.Stdout = logFile
-		cmd.Stderr = logFile
+	// Reap the child if it exits while we are still around
+	go cmd.Wait()
 
-		// Detach process
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			Setpgid: true,
-		}
+	return pidfile.Read(s.pidPath)
+}
+
+// Run starts the server in the foreground and blocks until it exits.
+// SIGINT and SIGTERM received by nappctl are forwarded to the server.
+func (s *Supervisor) Run() error {
+	if err := s.checkNotRunning(); err != nil {
+		return err
 	}
 
-	// Start the server
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start server: %w", err)
+	cmd, err := s.command()
+	if err != nil {
+		return err
 	}
 
-	pid := cmd.Process.Pid
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = s.opts.Stdout
+	cmd.Stderr = s.opts.Stderr
 
-	if foreground {
-		// Wait for process in foreground mode
-		return cmd.Wait()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
 	}
 
-	// Daemon mode: write PID file and return
-	if err := pidfile.Write(pidPath, pid, port, dataDir); err != nil {
-		// Kill the process if we can't write PID file
+	if err := pidfile.Write(s.pidPath, cmd.Process.Pid, s.opts.Port, s.opts.DataDir); err != nil {
 		cmd.Process.Kill()
 		return fmt.Errorf("failed to write PID file: %w", err)
 	}
+	defer pidfile.Remove(s.pidPath)
 
-	fmt.Printf("Server started successfully (PID: %d, Port: %d)\n", pid, port)
-	fmt.Printf("Data directory: %s\n", dataDir)
-	fmt.Printf("Logs: %s/logs/nappctl.log\n", dataDir)
+	stopForwarding := forwardSignals(cmd.Process, syscall.SIGINT, syscall.SIGTERM)
+	defer stopForwarding()
 
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("server exited with error: %w", err)
+	}
 	return nil
 }
 
-// StopServer stops the running server
-func StopServer(force bool) error {
-	dataDir, err := config.ResolveDataDir()
+// Stop sends StopSignal to the running server and waits up to StopTimeout
+// for it to exit. If Escalate is set the server is then sent SIGKILL and
+// killed is reported as true; otherwise ErrStopTimeout is returned.
+//
+// A supervised server is stopped through its supervisor (with SIGTERM) so
+// that the exit is not mistaken for a crash and restarted.
+func (s *Supervisor) Stop() (killed bool, err error) {
+	pid, err := pidfile.GetRunningPID(s.pidPath)
 	if err != nil {
-		return fmt.Errorf("failed to resolve data directory: %w", err)
+		return false, err
+	}
+	if pid == 0 {
+		return false, ErrNotRunning
 	}
 
-	pidPath := config.GetPIDPath(dataDir)
-	pidInfo, err := pidfile.Read(pidPath)
+	info, err := pidfile.Read(s.pidPath)
 	if err != nil {
-		return fmt.Errorf("server is not running or PID file not found: %w", err)
+		return false, err
 	}
 
-	process, err := os.FindProcess(pidInfo.PID)
-	if err != nil {
-		// Clean up stale PID file
-		os.Remove(pidPath)
-		return fmt.Errorf("process not found: %w", err)
+	// Processes to signal and wait for, in order
+	targets := []int{info.PID}
+	sig := s.opts.StopSignal
+	if info.Supervised() && pidfile.IsProcessRunning(info.SupervisorPID) {
+		targets = []int{info.SupervisorPID, info.PID}
+		sig = syscall.SIGTERM
 	}
 
-	// Send SIGTERM for graceful shutdown
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		os.Remove(pidPath)
-		return fmt.Errorf("failed to send SIGTERM: %w", err)
+	if err := syscall.Kill(targets[0], sig); err != nil && pidfile.IsProcessRunning(targets[0]) {
+		return false, fmt.Errorf("failed to send %v: %w", sig, err)
 	}
 
-	// Wait for process to exit
-	timeout := 30 * time.Second
-	if force {
-		timeout = 5 * time.Second
+	if waitForExit(targets, s.opts.StopTimeout) {
+		pidfile.Remove(s.pidPath)
+		return false, nil
 	}
 
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+	if !s.opts.Escalate {
+		return false, ErrStopTimeout
+	}
 
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
+	for _, target := range targets {
+		var err error
+		if target == info.PID {
+			err = killServer(target)
+		} else {
+			err = syscall.Kill(target, syscall.SIGKILL)
+		}
+		if err != nil && pidfile.IsProcessRunning(target) {
+			return false, fmt.Errorf("failed to send SIGKILL: %w", err)
+		}
+	}
+	if !waitForExit(targets, 5*time.Second) {
+		return true, fmt.Errorf("server (PID: %d) still running after SIGKILL", pid)
+	}
+
+	pidfile.Remove(s.pidPath)
+	return true, nil
+}
+
+// WaitReady polls the server's /health endpoint on the supervisor's port
+// until it succeeds, the process identified by pid exits (ErrExited), or
+// timeout elapses (ErrNotReady).
+func (s *Supervisor) WaitReady(pid int, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+
+	client := api.NewClient(fmt.Sprintf("http://127.0.0.1:%d", s.opts.Port), "")
+	deadline := time.Now().Add(timeout)
 
 	for {
-		select {
-		case <-timer.C:
-			// Timeout reached
-			if force {
-				// Send SIGKILL
-				fmt.Println("Timeout reached, sending SIGKILL...")
-				if err := process.Signal(syscall.SIGKILL); err != nil {
-					return fmt.Errorf("failed to send SIGKILL: %w", err)
-				}
-				time.Sleep(500 * time.Millisecond)
-			} else {
-				return fmt.Errorf("timeout waiting for server to stop (use --force for SIGKILL)")
-			}
-		case <-ticker.C:
-			// Check if process is still running
-			if err := process.Signal(syscall.Signal(0)); err != nil {
-				// Process has exited
-				os.Remove(pidPath)
-				fmt.Println("Server stopped successfully")
-				return nil
-			}
+		if !pidfile.IsProcessRunning(pid) {
+			pidfile.Remove(s.pidPath)
+			return ErrExited
 		}
+		if err := client.HealthCheck(context.Background()); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrNotReady
+		}
+		time.Sleep(250 * time.Millisecond)
 	}
 }
 
-// GetServerStatus returns the server status
-func GetServerStatus() (*pidfile.PIDInfo, bool, error) {
-	dataDir, err := config.ResolveDataDir()
+// Status returns the PID file contents and whether that process is alive.
+// A nil info means no PID file exists.
+func (s *Supervisor) Status() (*pidfile.PIDInfo, bool, error) {
+	info, err := pidfile.Read(s.pidPath)
 	if err != nil {
+		if errors.Is(err, pidfile.ErrNotFound) {
+			return nil, false, nil
+		}
 		return nil, false, err
 	}
+	return info, info.Alive(), nil
+}
 
-	pidPath := config.GetPIDPath(dataDir)
-	pidInfo, err := pidfile.Read(pidPath)
+// checkNotRunning returns an error if the PID file points at a live process.
+func (s *Supervisor) checkNotRunning() error {
+	pid, err := pidfile.GetRunningPID(s.pidPath)
 	if err != nil {
-		return nil, false, nil
+		return err
 	}
+	if pid != 0 {
+		return fmt.Errorf("server is already running (PID: %d)", pid)
+	}
+	return nil
+}
 
-	// Check if process is actually running
-	process, err := os.FindProcess(pidInfo.PID)
-	if err != nil {
-		return pidInfo, false, nil
+// command builds the exec.Cmd for the server with its environment applied.
+func (s *Supervisor) command() (*exec.Cmd, error) {
+	argv := s.opts.Command
+	if len(argv) == 0 {
+		nodePath, err := FindNodeJS()
+		if err != nil {
+			return nil, fmt.Errorf("Node.js not found: %w", err)
+		}
+
+		scriptPath, err := FindNappTrappServer()
+		if err != nil {
+			return nil, fmt.Errorf("server script not found: %w", err)
+		}
+
+		if err := CheckServerDependencies(scriptPath); err != nil {
+			return nil, err
+		}
+
+		argv = []string{nodePath, scriptPath}
 	}
 
-	// Send signal 0 to check if process exists
-	if err := process.Signal(syscall.Signal(0)); err != nil {
-		return pidInfo, false, nil
+	// The server replaces an auth.json it cannot read with a new token
+	authPath := config.GetAuthPath(s.opts.DataDir)
+	if _, err := auth.MigrateAuthFile(authPath); err != nil {
+		return nil, err
 	}
 
-	return pidInfo, true, nil
+	// Nor can it read a token kept in an encrypted or keyring secret store
+	token := s.opts.Token
+	if token == "" {
+		var err error
+		if token, err = auth.ExternalToken(authPath); err != nil {
+			return nil, fmt.Errorf("failed to read the master token: %w", err)
+		}
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("PORT=%d", s.opts.Port),
+		fmt.Sprintf("NAPPTRAPP_DATA_DIR=%s", s.opts.DataDir),
+		"NAPPTRAPP_CLI=true",
+	)
+	if token != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("AUTH_TOKEN=%s", token))
+	}
+	cmd.Env = append(cmd.Env, s.opts.Env...)
+
+	return cmd, nil
 }
 
-// RestartServer restarts the server
-func RestartServer(port int, token string, foreground bool) error {
-	dataDir, err := config.ResolveDataDir()
-	if err != nil {
-		return err
+// killServer sends SIGKILL to the server. A server started by Start leads
+// its own process group, which is killed with it so that the terminals and
+// agent CLIs it spawned do not outlive it. The graceful StopSignal goes to
+// the server alone, which shuts its children down itself.
+func killServer(pid int) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return syscall.Kill(-pgid, syscall.SIGKILL)
 	}
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
 
-	// Stop if running
-	if pidfile.IsRunning(config.GetPIDPath(dataDir)) {
-		fmt.Println("Stopping server...")
-		if err := StopServer(false); err != nil {
-			return fmt.Errorf("failed to stop server: %w", err)
+// waitForExit polls until all of the processes exit or the timeout elapses.
+// Returns true if they all exited.
+func waitForExit(pids []int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		running := false
+		for _, pid := range pids {
+			if pidfile.IsProcessRunning(pid) {
+				running = true
+				break
+			}
 		}
-		time.Sleep(1 * time.Second)
+		if !running {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
+}
 
-	// Start server
-	fmt.Println("Starting server...")
-	return StartServer(port, token, foreground)
+// forwardSignals relays the given signals received by nappctl to process.
+// The returned function stops forwarding.
+func forwardSignals(process *os.Process, signals ...os.Signal) func() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigCh:
+				process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
 }
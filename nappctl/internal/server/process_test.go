@@ -0,0 +1,278 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
+)
+
+// helperEnv selects how TestHelperProcess behaves when the test binary is
+// run as a stand-in server.
+const helperEnv = "NAPPCTL_TEST_SERVER"
+
+// TestHelperProcess is not a test: the supervisor runs the test binary as
+// the server, and this function plays it. In "graceful" mode it exits on
+// SIGINT or SIGTERM after recording the signal, and like the Node server
+// is killed by SIGHUP; in "stubborn" mode it ignores SIGINT and SIGTERM,
+// and "forking" is stubborn with a child process, whose PID it records in
+// "child".
+// "lingering" mode survives SIGHUP and exits with status 1 once a "crash"
+// file appears. In every mode it creates "ready" once its handlers are set.
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperEnv)
+	if mode == "" {
+		return
+	}
+	dir := os.Getenv("NAPPCTL_TEST_DIR")
+
+	signals := make(chan os.Signal, 1)
+	var poll <-chan time.Time
+	switch mode {
+	case "stubborn", "forking":
+		signal.Ignore(syscall.SIGINT, syscall.SIGTERM)
+		if mode == "forking" {
+			child := exec.Command("sleep", "60")
+			if err := child.Start(); err != nil {
+				os.Exit(1)
+			}
+			os.WriteFile(filepath.Join(dir, "child"), []byte(strconv.Itoa(child.Process.Pid)), 0644)
+		}
+	case "lingering":
+		signal.Ignore(syscall.SIGHUP)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		poll = time.Tick(10 * time.Millisecond)
+	default:
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	}
+	os.WriteFile(filepath.Join(dir, "ready"), nil, 0644)
+
+	timeout := time.After(time.Minute)
+	for {
+		select {
+		case sig := <-signals:
+			os.WriteFile(filepath.Join(dir, "signal"), []byte(sig.String()), 0644)
+			os.Exit(0)
+		case <-poll:
+			if _, err := os.Stat(filepath.Join(dir, "crash")); err == nil {
+				os.Exit(1)
+			}
+		case <-timeout:
+			os.Exit(1)
+		}
+	}
+}
+
+// newTestSupervisor returns a supervisor whose server is the test binary
+// in the given helper mode, on a temporary data directory.
+func newTestSupervisor(t *testing.T, mode string, opts Options) *Supervisor {
+	t.Helper()
+	dir := t.TempDir()
+	opts.DataDir = dir
+	opts.Command = []string{os.Args[0], "-test.run=^TestHelperProcess$"}
+	opts.Env = []string{helperEnv + "=" + mode, "NAPPCTL_TEST_DIR=" + dir}
+
+	sup, err := NewSupervisor(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sup
+}
+
+// startHelper starts the supervisor's server and waits until it handles
+// signals. The server is killed when the test ends.
+func startHelper(t *testing.T, sup *Supervisor) *pidfile.PIDInfo {
+	t.Helper()
+	info, err := sup.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Kill(info.PID, syscall.SIGKILL) })
+
+	waitForFile(t, filepath.Join(sup.DataDir(), "ready"))
+	return info
+}
+
+// waitForFile waits for a helper server to create file.
+func waitForFile(t *testing.T, file string) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		if _, err := os.Stat(file); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("helper server did not create %s", filepath.Base(file))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestStartWritesPIDFile(t *testing.T) {
+	sup := newTestSupervisor(t, "graceful", Options{Port: 45678})
+	info := startHelper(t, sup)
+
+	if !pidfile.IsProcessRunning(info.PID) {
+		t.Fatalf("server (PID %d) is not running", info.PID)
+	}
+	recorded, err := pidfile.Read(sup.PIDPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.PID != info.PID || recorded.Port != 45678 || recorded.DataDir != sup.DataDir() {
+		t.Errorf("PID file = %+v, want PID %d, port 45678, data dir %s", recorded, info.PID, sup.DataDir())
+	}
+
+	if _, running, err := sup.Status(); err != nil || !running {
+		t.Errorf("Status = running %t, %v; want running", running, err)
+	}
+	if _, err := sup.Start(); err == nil {
+		t.Error("second Start succeeded while the server was running")
+	}
+}
+
+func TestStopSendsStopSignal(t *testing.T) {
+	for _, tc := range []struct {
+		signal syscall.Signal
+		want   string
+	}{
+		{0, syscall.SIGINT.String()}, // the default
+		{syscall.SIGTERM, syscall.SIGTERM.String()},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			sup := newTestSupervisor(t, "graceful", Options{StopSignal: tc.signal})
+			startHelper(t, sup)
+
+			killed, err := sup.Stop()
+			if err != nil || killed {
+				t.Fatalf("Stop = killed %t, %v; want a graceful stop", killed, err)
+			}
+			got, err := os.ReadFile(filepath.Join(sup.DataDir(), "signal"))
+			if err != nil {
+				t.Fatalf("server received no signal: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("server received %s, want %s", got, tc.want)
+			}
+			if _, err := os.Stat(sup.PIDPath()); !os.IsNotExist(err) {
+				t.Errorf("PID file not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestStopEscalatesAfterTimeout(t *testing.T) {
+	sup := newTestSupervisor(t, "stubborn", Options{StopTimeout: 300 * time.Millisecond, Escalate: true})
+	info := startHelper(t, sup)
+
+	start := time.Now()
+	killed, err := sup.Stop()
+	if err != nil || !killed {
+		t.Fatalf("Stop = killed %t, %v; want killed", killed, err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("SIGKILL sent after %v, before the stop timeout", elapsed)
+	}
+	if pidfile.IsProcessRunning(info.PID) {
+		t.Error("server still running after Stop")
+	}
+	if _, err := os.Stat(sup.PIDPath()); !os.IsNotExist(err) {
+		t.Errorf("PID file not removed: %v", err)
+	}
+}
+
+func TestStopEscalationKillsProcessGroup(t *testing.T) {
+	sup := newTestSupervisor(t, "forking", Options{StopTimeout: 300 * time.Millisecond, Escalate: true})
+	startHelper(t, sup)
+	data, err := os.ReadFile(filepath.Join(sup.DataDir(), "child"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := strconv.Atoi(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Kill(child, syscall.SIGKILL) })
+
+	if killed, err := sup.Stop(); err != nil || !killed {
+		t.Fatalf("Stop = killed %t, %v; want killed", killed, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !exited(child); {
+		if time.Now().After(deadline) {
+			t.Fatalf("server's child (PID %d) survived SIGKILL", child)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// exited reports whether pid has exited. An orphan counts once it is a
+// zombie, as not every init (e.g. in a container) reaps it.
+func exited(pid int) bool {
+	if !pidfile.IsProcessRunning(pid) {
+		return true
+	}
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	return err != nil || strings.HasPrefix(strings.TrimSpace(string(out)), "Z")
+}
+
+func TestStopTimeoutWithoutEscalation(t *testing.T) {
+	sup := newTestSupervisor(t, "stubborn", Options{StopTimeout: 300 * time.Millisecond})
+	info := startHelper(t, sup)
+
+	if _, err := sup.Stop(); !errors.Is(err, ErrStopTimeout) {
+		t.Fatalf("Stop error = %v, want ErrStopTimeout", err)
+	}
+	if !pidfile.IsProcessRunning(info.PID) {
+		t.Error("server was killed without Escalate")
+	}
+	if _, err := os.Stat(sup.PIDPath()); err != nil {
+		t.Errorf("PID file of the running server was removed: %v", err)
+	}
+}
+
+func TestStalePIDFileIsCleanedUp(t *testing.T) {
+	sup := newTestSupervisor(t, "graceful", Options{})
+	stale := deadPID(t)
+
+	if err := pidfile.Write(sup.PIDPath(), stale, DefaultPort, sup.DataDir()); err != nil {
+		t.Fatal(err)
+	}
+	if info, running, err := sup.Status(); err != nil || running || info.PID != stale {
+		t.Errorf("Status = %+v, running %t, %v; want the stale PID, not running", info, running, err)
+	}
+	if _, err := sup.Stop(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Stop error = %v, want ErrNotRunning", err)
+	}
+	if _, err := os.Stat(sup.PIDPath()); !os.IsNotExist(err) {
+		t.Errorf("stale PID file not removed by Stop: %v", err)
+	}
+
+	// Start replaces a stale PID file rather than refusing to run
+	if err := pidfile.Write(sup.PIDPath(), stale, DefaultPort, sup.DataDir()); err != nil {
+		t.Fatal(err)
+	}
+	info := startHelper(t, sup)
+	if info.PID == stale {
+		t.Fatalf("Start kept the stale PID %d", stale)
+	}
+	if recorded, err := pidfile.Read(sup.PIDPath()); err != nil || recorded.PID != info.PID {
+		t.Errorf("PID file = %+v, %v; want PID %d", recorded, err, info.PID)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the kernel's USER_HZ, which is 100 on every Linux platform
+// nappctl is built for.
+const clockTicks = 100
+
+// ProcessStats holds resource usage of a server process as read from /proc.
+type ProcessStats struct {
+	RSSBytes   int64   `json:"rss_bytes"`
+	Threads    int     `json:"threads"`
+	CPUPercent float64 `json:"cpu_percent"` // average over the process lifetime
+}
+
+// ReadProcessStats reads memory and CPU usage for pid from /proc.
+// It returns an error on systems without procfs (e.g. macOS).
+func ReadProcessStats(pid int) (*ProcessStats, error) {
+	stats := &ProcessStats{}
+
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, fmt.Errorf("process stats unavailable: %w", err)
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "VmRSS:":
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			stats.RSSBytes = kb * 1024
+		case "Threads:":
+			stats.Threads, _ = strconv.Atoi(fields[1])
+		}
+	}
+
+	fields, err := readProcStat(pid)
+	if err != nil {
+		return nil, err
+	}
+	utime, _ := strconv.ParseFloat(fields[11], 64)
+	stime, _ := strconv.ParseFloat(fields[12], 64)
+	startTicks, _ := strconv.ParseFloat(fields[19], 64)
+
+	uptimeData, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return stats, nil
+	}
+	uptimeFields := strings.Fields(string(uptimeData))
+	if len(uptimeFields) == 0 {
+		return stats, nil
+	}
+	systemUptime, _ := strconv.ParseFloat(uptimeFields[0], 64)
+
+	elapsed := systemUptime - startTicks/clockTicks
+	if elapsed > 0 {
+		stats.CPUPercent = (utime + stime) / clockTicks / elapsed * 100
+	}
+
+	return stats, nil
+}
+
+// ProcessStartTime returns when pid was started, computed from its start
+// time in /proc/<pid>/stat and the boot time in /proc/stat.
+func ProcessStartTime(pid int) (time.Time, error) {
+	fields, err := readProcStat(pid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	startTicks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed /proc/%d/stat: %w", pid, err)
+	}
+
+	procStat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("process start time unavailable: %w", err)
+	}
+	for _, line := range strings.Split(string(procStat), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			bootTime, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+			if err != nil {
+				break
+			}
+			started := time.Unix(bootTime, 0).Add(time.Duration(startTicks) * time.Second / clockTicks)
+			return started, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("boot time not found in /proc/stat")
+}
+
+// readProcStat returns the fields of /proc/<pid>/stat that follow the
+// command name. The name may contain spaces, so fields are counted from the
+// closing parenthesis; index 0 is field 3 (state) of proc(5).
+func readProcStat(pid int) ([]string, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, fmt.Errorf("process stats unavailable: %w", err)
+	}
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(stat)[end+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	return fields, nil
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
+)
+
+// AuthReload is the outcome of a successful NotifyAuthChanged or ReloadAuth.
+type AuthReload struct {
+	PID          int
+	URL          string
+	SessionCount int
+}
+
+// RunningURL returns the local URL and PID of the running server. When no
+// server is running, or a supervisor is waiting to restart it, the error
+// wraps ErrNotRunning.
+func (s *Supervisor) RunningURL() (string, int, error) {
+	pid, err := pidfile.GetRunningPID(s.pidPath)
+	if err != nil {
+		return "", 0, err
+	}
+	if pid == 0 {
+		return "", 0, ErrNotRunning
+	}
+	info, err := pidfile.Read(s.pidPath)
+	if err != nil {
+		return "", 0, err
+	}
+	if pid != info.PID {
+		return "", 0, fmt.Errorf("%w: its supervisor is waiting to restart it", ErrNotRunning)
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", info.Port), pid, nil
+}
+
+// NotifyAuthChanged makes the running server re-read auth.json after
+// nappctl changed it, authenticating with token (the master token the
+// server is using). Until it does, the server keeps using its in-memory
+// copy, and adopts the file only when it next saves it.
+//
+// When the server is not running (see RunningURL) the change takes effect
+// when it starts.
+func (s *Supervisor) NotifyAuthChanged(ctx context.Context, token string) (*AuthReload, error) {
+	return s.notifyAuthChanged(ctx, token, "")
+}
+
+// notifyAuthChanged is NotifyAuthChanged, also handing the server
+// newToken, which it cannot read when the token is kept in an encrypted
+// or keyring secret store.
+func (s *Supervisor) notifyAuthChanged(ctx context.Context, token, newToken string) (*AuthReload, error) {
+	url, pid, err := s.RunningURL()
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, errors.New("no master token to authenticate the reload with; restart the server instead")
+	}
+
+	reload := &AuthReload{PID: pid, URL: url}
+	if err := api.NewClient(reload.URL, "").HealthCheck(ctx); err != nil {
+		return nil, fmt.Errorf("server is not healthy: %w", err)
+	}
+
+	result, err := api.NewClient(reload.URL, token).ReloadAuth(ctx, newToken)
+	switch {
+	case api.IsUnauthorized(err):
+		return nil, errors.New("server rejected the master token (was it started with --token?); restart it instead")
+	case api.IsNotFound(err):
+		return nil, errors.New("server does not support reloading auth.json; restart it instead")
+	case err != nil:
+		return nil, err
+	}
+	reload.SessionCount = result.SessionCount
+	return reload, nil
+}
+
+// ReloadAuth makes the running server re-read auth.json after its master
+// token was rotated from oldToken to newToken, then verifies the switch:
+// /health must answer, newToken must be accepted and oldToken rejected.
+// Errors are as for NotifyAuthChanged.
+func (s *Supervisor) ReloadAuth(ctx context.Context, oldToken, newToken string) (*AuthReload, error) {
+	reload, err := s.notifyAuthChanged(ctx, oldToken, newToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := api.NewClient(reload.URL, newToken).GetSystemInfo(ctx); err != nil {
+		return nil, fmt.Errorf("server did not accept the new token: %w", err)
+	}
+	if oldToken != newToken {
+		_, err := api.NewClient(reload.URL, oldToken).GetSystemInfo(ctx)
+		if err == nil {
+			return nil, errors.New("server still accepts the old token")
+		}
+		if !api.IsUnauthorized(err) {
+			return nil, fmt.Errorf("failed to check the old token: %w", err)
+		}
+	}
+
+	return reload, nil
+}
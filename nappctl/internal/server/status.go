@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/api"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
+)
+
+// Server states reported by Inspect.
+const (
+	StateStopped   = "stopped"
+	StateUnhealthy = "unhealthy"
+	StateHealthy   = "healthy"
+)
+
+// HealthStatus is the result of probing the server's /health endpoint.
+type HealthStatus struct {
+	Reachable bool    `json:"reachable"`
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Status is a detailed snapshot of the server, combining the PID file,
+// live HTTP probes and process statistics.
+type Status struct {
+	State         string            `json:"state"`
+	ManagedBy     string            `json:"managed_by,omitempty"`
+	PID           int               `json:"pid,omitempty"`
+	SupervisorPID int               `json:"supervisor_pid,omitempty"`
+	Port          int               `json:"port,omitempty"`
+	URL           string            `json:"url,omitempty"`
+	DataDir       string            `json:"data_dir"`
+	StartedAt     *time.Time        `json:"started_at,omitempty"`
+	UptimeSeconds int64             `json:"uptime_seconds,omitempty"`
+	Restarts      int               `json:"restarts,omitempty"`
+	LastExitCode  int               `json:"last_exit_code,omitempty"`
+	LastExitAt    *time.Time        `json:"last_exit_at,omitempty"`
+	Health        *HealthStatus     `json:"health,omitempty"`
+	Discover      *api.DiscoverInfo `json:"discover,omitempty"`
+	Process       *ProcessStats     `json:"process,omitempty"`
+}
+
+// Uptime returns how long the current server process has been running.
+func (st *Status) Uptime() time.Duration {
+	return time.Duration(st.UptimeSeconds) * time.Second
+}
+
+// Inspect gathers a detailed status of the server. The port is taken from
+// the PID file, not from configuration, so it reflects what the server was
+// actually started with.
+func (s *Supervisor) Inspect() (*Status, error) {
+	info, running, err := s.Status()
+	if err != nil {
+		return nil, err
+	}
+	return s.InspectInfo(info, running), nil
+}
+
+// InspectInfo gathers a detailed status for a server described by info,
+// which may come from somewhere other than the PID file (e.g. a systemd
+// unit). A nil info or running=false reports the server as stopped.
+func (s *Supervisor) InspectInfo(info *pidfile.PIDInfo, running bool) *Status {
+	st := &Status{
+		State:   StateStopped,
+		DataDir: s.opts.DataDir,
+	}
+	if info == nil || !running {
+		return st
+	}
+
+	st.State = StateUnhealthy
+	st.PID = info.PID
+	st.SupervisorPID = info.SupervisorPID
+	st.Port = info.Port
+	st.Restarts = info.Restarts
+	st.LastExitCode = info.LastExitCode
+	st.LastExitAt = info.LastExitAt
+	if info.DataDir != "" {
+		st.DataDir = info.DataDir
+	}
+	if !info.StartedAt.IsZero() {
+		startedAt := info.StartedAt
+		st.StartedAt = &startedAt
+		st.UptimeSeconds = int64(time.Since(startedAt).Seconds())
+	}
+
+	if stats, err := ReadProcessStats(info.PID); err == nil {
+		st.Process = stats
+	}
+
+	st.URL = fmt.Sprintf("http://127.0.0.1:%d", info.Port)
+	client := api.NewClient(st.URL, "")
+
+	started := time.Now()
+	err := client.HealthCheck(context.Background())
+	latency := time.Since(started)
+	if err != nil {
+		st.Health = &HealthStatus{Error: err.Error()}
+		return st
+	}
+
+	st.State = StateHealthy
+	st.Health = &HealthStatus{
+		Reachable: true,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+	if discover, err := client.Discover(context.Background()); err == nil {
+		st.Discover = discover
+	}
+
+	return st
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
+)
+
+// ErrRestartBudgetExhausted is returned by Supervise when the server crashes
+// more often than the restart policy allows.
+var ErrRestartBudgetExhausted = errors.New("server crashed too often, giving up")
+
+// RestartPolicy controls how Supervise restarts a server that exits
+// unexpectedly.
+type RestartPolicy struct {
+	// InitialBackoff is the delay before the first restart. It doubles
+	// with every crash inside Window, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// MaxRestarts is the number of crash restarts allowed within Window
+	// before supervision gives up.
+	MaxRestarts int
+	Window      time.Duration
+}
+
+// DefaultRestartPolicy returns the policy used by `server start --supervise`.
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     1 * time.Minute,
+		MaxRestarts:    5,
+		Window:         10 * time.Minute,
+	}
+}
+
+// backoff returns the delay before restart number n (1-based) in the window.
+func (p RestartPolicy) backoff(n int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < n && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// Supervise runs the server in the foreground and keeps nappctl resident as
+// its supervisor. A server that exits with a non-zero status is restarted
+// with exponential backoff until more than MaxRestarts crashes happen within
+// Window. A clean exit (status 0) ends supervision.
+//
+// SIGINT and SIGTERM stop the server with StopSignal and end supervision.
+// SIGHUP is forwarded to the server, and an exit within StopTimeout of it
+// is restarted immediately without counting against the crash budget.
+//
+// Restart counts and the last exit code are kept in the PID file so that
+// `server status` can report them.
+func (s *Supervisor) Supervise(policy RestartPolicy) error {
+	if err := s.checkNotRunning(); err != nil {
+		return err
+	}
+
+	defaults := DefaultRestartPolicy()
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaults.InitialBackoff
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
+	}
+	if policy.MaxRestarts <= 0 {
+		policy.MaxRestarts = defaults.MaxRestarts
+	}
+	if policy.Window <= 0 {
+		policy.Window = defaults.Window
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	defer pidfile.Remove(s.pidPath)
+
+	info := &pidfile.PIDInfo{
+		Port:          s.opts.Port,
+		DataDir:       s.opts.DataDir,
+		SupervisorPID: os.Getpid(),
+	}
+	var crashes []time.Time
+
+	for {
+		cmd, err := s.command()
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = s.opts.Stdout
+		cmd.Stderr = s.opts.Stderr
+
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+
+		info.PID = cmd.Process.Pid
+		info.StartedAt = time.Now()
+		if err := pidfile.WriteInfo(s.pidPath, info); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("failed to write PID file: %w", err)
+		}
+		s.logf("server started (PID: %d)", info.PID)
+
+		exitCh := make(chan error, 1)
+		go func() { exitCh <- cmd.Wait() }()
+
+		stopping := false
+		var hupAt time.Time
+		var killTimer <-chan time.Time
+		var waitErr error
+
+	wait:
+		for {
+			select {
+			case sig := <-sigCh:
+				if sig == syscall.SIGHUP {
+					hupAt = time.Now()
+					cmd.Process.Signal(syscall.SIGHUP)
+					continue
+				}
+				stopping = true
+				cmd.Process.Signal(s.opts.StopSignal)
+				if killTimer == nil {
+					killTimer = time.After(s.opts.StopTimeout)
+				}
+			case <-killTimer:
+				s.logf("server did not stop within %s, sending SIGKILL", s.opts.StopTimeout)
+				cmd.Process.Kill()
+			case waitErr = <-exitCh:
+				break wait
+			}
+		}
+
+		exitedAt := time.Now()
+		info.LastExitCode = exitCode(waitErr)
+		info.LastExitAt = &exitedAt
+
+		switch {
+		case stopping:
+			s.logf("server stopped")
+			return nil
+		// A server that outlived SIGHUP and exits later has crashed
+		case !hupAt.IsZero() && exitedAt.Sub(hupAt) <= s.opts.StopTimeout:
+			info.Restarts++
+			s.logf("server exited on SIGHUP (code %d), restarting", info.LastExitCode)
+			continue
+		case info.LastExitCode == 0:
+			s.logf("server exited cleanly, not restarting")
+			return nil
+		}
+
+		// Unexpected exit: count it against the budget for the current window
+		crashes = append(crashes, exitedAt)
+		for len(crashes) > 0 && exitedAt.Sub(crashes[0]) > policy.Window {
+			crashes = crashes[1:]
+		}
+		if len(crashes) > policy.MaxRestarts {
+			return fmt.Errorf("%w: %d crashes within %s (last exit code %d)",
+				ErrRestartBudgetExhausted, len(crashes), policy.Window, info.LastExitCode)
+		}
+
+		delay := policy.backoff(len(crashes))
+		info.Restarts++
+		if err := pidfile.WriteInfo(s.pidPath, info); err != nil {
+			return fmt.Errorf("failed to write PID file: %w", err)
+		}
+		s.logf("server exited with code %d, restarting in %s (%d/%d restarts in %s)",
+			info.LastExitCode, delay, len(crashes), policy.MaxRestarts, policy.Window)
+
+		select {
+		case <-time.After(delay):
+		case sig := <-sigCh:
+			if sig != syscall.SIGHUP {
+				s.logf("server stopped")
+				return nil
+			}
+		}
+	}
+}
+
+// logf writes a supervisor message to the foreground error stream.
+func (s *Supervisor) logf(format string, args ...interface{}) {
+	fmt.Fprintf(s.opts.Stderr, "[nappctl] "+format+"\n", args...)
+}
+
+// exitCode converts the result of exec.Cmd.Wait into a shell-style exit
+// code, using 128+N for processes terminated by signal N.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/pkg/pidfile"
+)
+
+// logBuffer collects the supervisor's messages, which it writes to the
+// same stream as the server's stderr.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// supervise runs sup.Supervise in the background until the server it
+// started is ready, returning the channel that receives its result.
+// Signals sent to the test process after that reach the supervisor.
+func supervise(t *testing.T, sup *Supervisor) <-chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- sup.Supervise(RestartPolicy{InitialBackoff: 10 * time.Millisecond, MaxRestarts: 1, Window: time.Minute})
+	}()
+	t.Cleanup(func() {
+		if info, err := pidfile.Read(sup.PIDPath()); err == nil {
+			syscall.Kill(info.PID, syscall.SIGKILL)
+		}
+	})
+
+	ready := filepath.Join(sup.DataDir(), "ready")
+	waitForFile(t, ready)
+	os.Remove(ready)
+	return done
+}
+
+// result waits for Supervise to return.
+func result(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("Supervise did not return")
+		return nil
+	}
+}
+
+func TestSuperviseRestartsOnSIGHUP(t *testing.T) {
+	var log logBuffer
+	sup := newTestSupervisor(t, "graceful", Options{StopTimeout: 5 * time.Second, Stderr: &log})
+	done := supervise(t, sup)
+
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	waitForFile(t, filepath.Join(sup.DataDir(), "ready"))
+	select {
+	case err := <-done:
+		t.Fatalf("Supervise returned after SIGHUP: %v", err)
+	default:
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	if err := result(t, done); err != nil {
+		t.Fatalf("Supervise = %v, want a clean stop", err)
+	}
+	if !strings.Contains(log.String(), "server exited on SIGHUP") {
+		t.Errorf("exit after SIGHUP was not treated as a reload:\n%s", log.String())
+	}
+	if _, err := os.Stat(sup.PIDPath()); !os.IsNotExist(err) {
+		t.Errorf("PID file not removed: %v", err)
+	}
+}
+
+func TestSuperviseCountsLateExitAfterSIGHUPAsCrash(t *testing.T) {
+	var log logBuffer
+	sup := newTestSupervisor(t, "lingering", Options{StopTimeout: 200 * time.Millisecond, Stderr: &log})
+	done := supervise(t, sup)
+
+	// The server survives SIGHUP and crashes after the reload window, and
+	// again as soon as it is restarted, exhausting the budget of one
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	time.Sleep(500 * time.Millisecond)
+	os.WriteFile(filepath.Join(sup.DataDir(), "crash"), nil, 0644)
+
+	if err := result(t, done); !errors.Is(err, ErrRestartBudgetExhausted) {
+		t.Fatalf("Supervise = %v, want ErrRestartBudgetExhausted", err)
+	}
+	if strings.Contains(log.String(), "exited on SIGHUP") {
+		t.Errorf("crash long after SIGHUP was treated as a reload:\n%s", log.String())
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UnitStatus is the state of the systemd user unit.
+type UnitStatus struct {
+	Installed   bool   `json:"installed"`
+	Enabled     bool   `json:"enabled"`
+	ActiveState string `json:"active_state,omitempty"`
+	SubState    string `json:"sub_state,omitempty"`
+	MainPID     int    `json:"main_pid,omitempty"`
+	UnitPath    string `json:"unit_path"`
+}
+
+// Active reports whether the unit is currently running.
+func (s *UnitStatus) Active() bool {
+	return s.ActiveState == "active" || s.ActiveState == "activating" || s.ActiveState == "reloading"
+}
+
+// SystemdAvailable reports whether a systemd user manager can be reached.
+func SystemdAvailable() bool {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return false
+	}
+	return exec.Command("systemctl", "--user", "show-environment").Run() == nil
+}
+
+// UnitPath returns where the user unit file is installed.
+func UnitPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "systemd", "user", UnitName), nil
+}
+
+// IsInstalled reports whether the unit file exists.
+func IsInstalled() bool {
+	path, err := UnitPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Install writes the unit file, reloads systemd and enables the unit so
+// that it starts at login. When start is true it is also started now.
+func Install(cfg *UnitConfig, start bool) (string, error) {
+	unit, err := RenderSystemdUnit(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := UnitPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create unit directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.LogPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		return "", fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return path, err
+	}
+
+	args := []string{"enable", UnitName}
+	if start {
+		args = []string{"enable", "--now", UnitName}
+	}
+	if err := systemctl(args...); err != nil {
+		return path, err
+	}
+
+	return path, nil
+}
+
+// Uninstall stops and disables the unit and removes the unit file.
+func Uninstall() error {
+	path, err := UnitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("service is not installed")
+	}
+
+	// Disabling fails if systemd has already forgotten the unit; the file
+	// still needs to go.
+	systemctl("disable", "--now", UnitName)
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	return systemctl("daemon-reload")
+}
+
+// Start starts the unit.
+func Start() error {
+	return systemctl("start", UnitName)
+}
+
+// Stop stops the unit.
+func Stop() error {
+	return systemctl("stop", UnitName)
+}
+
+// Restart restarts the unit.
+func Restart() error {
+	return systemctl("restart", UnitName)
+}
+
+// Status queries systemd for the unit's state.
+func Status() (*UnitStatus, error) {
+	path, err := UnitPath()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &UnitStatus{UnitPath: path}
+	if _, err := os.Stat(path); err != nil {
+		return status, nil
+	}
+	status.Installed = true
+
+	out, err := exec.Command("systemctl", "--user", "show", UnitName,
+		"--property=ActiveState,SubState,MainPID,UnitFileState").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query systemd: %w", err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "ActiveState":
+			status.ActiveState = value
+		case "SubState":
+			status.SubState = value
+		case "MainPID":
+			status.MainPID, _ = strconv.Atoi(value)
+		case "UnitFileState":
+			status.Enabled = value == "enabled"
+		}
+	}
+
+	return status, nil
+}
+
+// UnitPort returns the PORT the installed unit starts the server with.
+func UnitPort() (int, error) {
+	path, err := UnitPath()
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read unit file: %w", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "Environment=") {
+			continue
+		}
+		value := strings.Trim(strings.TrimPrefix(line, "Environment="), `"`)
+		if port, ok := strings.CutPrefix(value, "PORT="); ok {
+			return strconv.Atoi(port)
+		}
+	}
+	return 0, fmt.Errorf("PORT not set in %s", path)
+}
+
+// systemctl runs `systemctl --user` with the given arguments.
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl --user %s failed: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/config"
+	"github.com/OS-justinloveless/Napp-Trapp/nappctl/internal/server"
+)
+
+const (
+	// UnitName is the systemd user unit that runs the server.
+	UnitName = "napptrapp.service"
+
+	// LaunchdLabel is the launchd job label used in the rendered plist.
+	LaunchdLabel = "com.napptrapp.server"
+)
+
+// UnitConfig describes how the service manager should launch the server.
+type UnitConfig struct {
+	NodePath   string
+	ScriptPath string
+	WorkingDir string
+	Port       int
+	DataDir    string
+	LogPath    string
+	// Path is the PATH given to the server so it can find git, tmux and
+	// the AI CLIs, which a login service manager does not inherit.
+	Path string
+}
+
+// ResolveUnitConfig builds a UnitConfig from the resolved Node.js binary,
+// the napptrapp server script and the current data directory.
+func ResolveUnitConfig(port int) (*UnitConfig, error) {
+	nodePath, err := server.FindNodeJS()
+	if err != nil {
+		return nil, fmt.Errorf("Node.js not found: %w", err)
+	}
+
+	scriptPath, err := server.FindNappTrappServer()
+	if err != nil {
+		return nil, fmt.Errorf("server script not found: %w", err)
+	}
+
+	dataDir, err := config.ResolveDataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve data directory: %w", err)
+	}
+
+	if port == 0 {
+		port = server.DefaultPort
+	}
+
+	return &UnitConfig{
+		NodePath:   nodePath,
+		ScriptPath: scriptPath,
+		WorkingDir: filepath.Dir(filepath.Dir(scriptPath)),
+		Port:       port,
+		DataDir:    dataDir,
+		LogPath:    filepath.Join(config.GetLogDir(dataDir), server.DefaultLogFile),
+		Path:       os.Getenv("PATH"),
+	}, nil
+}
+
+var systemdTemplate = template.Must(template.New("systemd").Funcs(template.FuncMap{
+	"quoted": systemdQuoted,
+	"exec":   systemdExecutable,
+	"arg":    systemdArg,
+	"path":   systemdPath,
+}).Parse(`[Unit]
+Description=Napp Trapp server
+Documentation=https://github.com/OS-justinloveless/Napp-Trapp
+
+[Service]
+Type=simple
+ExecStart="{{exec .NodePath}}" "{{arg .ScriptPath}}"
+WorkingDirectory={{path .WorkingDir}}
+Environment="PORT={{.Port}}"
+Environment="NAPPTRAPP_DATA_DIR={{quoted .DataDir}}"
+Environment="NAPPTRAPP_CLI=true"
+Environment="PATH={{quoted .Path}}"
+StandardOutput=append:{{path .LogPath}}
+StandardError=append:{{path .LogPath}}
+# The server only shuts down gracefully on SIGINT
+KillSignal=SIGINT
+TimeoutStopSec=30
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=default.target
+`))
+
+// systemdQuoter escapes a value for use inside double quotes. % is
+// doubled everywhere so systemd does not expand specifiers such as %h.
+var systemdQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "%", "%%")
+
+// systemdQuoted escapes s for use inside a double-quoted systemd value.
+func systemdQuoted(s string) string {
+	return systemdQuoter.Replace(s)
+}
+
+// systemdExecutable escapes the program path of ExecStart, which systemd
+// refuses if it contains quotes, backslashes or control characters.
+func systemdExecutable(p string) (string, error) {
+	if strings.ContainsAny(p, `"'\`) || strings.IndexFunc(p, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("executable %q cannot be used in a systemd unit", p)
+	}
+	return systemdQuoted(p), nil
+}
+
+// systemdArg escapes s for use inside a double-quoted ExecStart argument,
+// where $ also starts a variable reference.
+func systemdArg(s string) string {
+	return strings.ReplaceAll(systemdQuoted(s), "$", "$$")
+}
+
+// systemdPath escapes an absolute path for WorkingDirectory= and
+// StandardOutput=, which take the rest of the line as the path and do
+// not support quoting. Such a path cannot start or end with whitespace
+// or span lines.
+func systemdPath(p string) (string, error) {
+	if !filepath.IsAbs(p) || strings.TrimSpace(p) != p || strings.ContainsAny(p, "\n\r") {
+		return "", fmt.Errorf("path %q cannot be used in a systemd unit", p)
+	}
+	return strings.ReplaceAll(p, "%", "%%"), nil
+}
+
+var launchdTemplate = template.Must(template.New("launchd").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .NodePath}}</string>
+		<string>{{xml .ScriptPath}}</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkingDir}}</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PORT</key>
+		<string>{{.Port}}</string>
+		<key>NAPPTRAPP_DATA_DIR</key>
+		<string>{{xml .DataDir}}</string>
+		<key>NAPPTRAPP_CLI</key>
+		<string>true</string>
+		<key>PATH</key>
+		<string>{{xml .Path}}</string>
+	</dict>
+	<key>StandardOutPath</key>
+	<string>{{xml .LogPath}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .LogPath}}</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+</dict>
+</plist>
+`))
+
+// xmlEscape escapes s for use as plist text.
+func xmlEscape(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// RenderSystemdUnit renders a systemd user unit for the server.
+func RenderSystemdUnit(cfg *UnitConfig) (string, error) {
+	var buf bytes.Buffer
+	if err := systemdTemplate.Execute(&buf, cfg); err != nil {
+		return "", fmt.Errorf("failed to render systemd unit: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderLaunchdPlist renders a launchd agent plist for the server. It is
+// only produced as a text artifact; nappctl does not load it.
+func RenderLaunchdPlist(cfg *UnitConfig) (string, error) {
+	data := struct {
+		*UnitConfig
+		Label string
+	}{cfg, LaunchdLabel}
+
+	var buf bytes.Buffer
+	if err := launchdTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render launchd plist: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,369 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// peer is the server end of a test connection, speaking raw frames.
+type peer struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialPeer starts a server with a minimal upgrader and dials it, returning
+// both ends. The upgrader checks the request as a server must.
+func dialPeer(t *testing.T, header http.Header) (*Conn, *peer) {
+	t.Helper()
+	peers := make(chan *peer, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Sec-WebSocket-Key")
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+			r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+			http.Error(w, "not a websocket handshake", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != header.Get("Authorization") {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		sum := sha1.Sum([]byte(key + acceptGUID))
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		brw.Flush()
+		peers <- &peer{t: t, conn: conn, br: brw.Reader}
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := <-peers
+	t.Cleanup(func() {
+		c.conn.Close()
+		p.conn.Close()
+	})
+	p.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return c, p
+}
+
+// writeFrame sends an unmasked frame, as servers do.
+func (p *peer) writeFrame(fin bool, op byte, payload []byte) {
+	p.t.Helper()
+	head := op
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if _, err := p.conn.Write(append(frame, payload...)); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// readFrame reads a frame from the client, failing the test unless it is
+// masked, and returns its unmasked payload.
+func (p *peer) readFrame() (fin bool, op byte, payload []byte) {
+	p.t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(p.br, head[:]); err != nil {
+		p.t.Fatal(err)
+	}
+	if head[1]&0x80 == 0 {
+		p.t.Fatal("client frame is not masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(p.br, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(p.br, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	io.ReadFull(p.br, mask[:])
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(p.br, payload); err != nil {
+		p.t.Fatal(err)
+	}
+	maskBytes(mask, payload)
+	return head[0]&0x80 != 0, head[0] & 0x0f, payload
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+func TestDialHandshake(t *testing.T) {
+	header := http.Header{"Authorization": {"Bearer token"}}
+	dialPeer(t, header)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	_, err := Dial(context.Background(), srv.URL, nil)
+	if err == nil || !strings.Contains(err.Error(), "401: Unauthorized") {
+		t.Errorf("Dial error = %v, want the handshake status and body", err)
+	}
+
+	if _, err := Dial(context.Background(), "ftp://example.com", nil); err == nil {
+		t.Error("Dial accepted an ftp URL")
+	}
+}
+
+func TestWriteMessageMasksFrames(t *testing.T) {
+	c, p := dialPeer(t, nil)
+
+	// One payload per length encoding: 7-bit, 16-bit and 64-bit
+	for _, size := range []int{5, 300, 70000} {
+		payload := bytes.Repeat([]byte("x"), size)
+		go c.WriteMessage(BinaryMessage, payload)
+
+		fin, op, got := p.readFrame()
+		if !fin || op != BinaryMessage {
+			t.Errorf("size %d: frame fin %t, opcode %d; want a final binary frame", size, fin, op)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Errorf("size %d: unmasked payload differs", size)
+		}
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		send    func(p *peer)
+		msgType int
+		want    string
+		// pong is the payload of a pong the client must answer with
+		pong string
+	}{
+		{
+			name:    "single frame",
+			send:    func(p *peer) { p.writeFrame(true, TextMessage, []byte(`{"type":"hello"}`)) },
+			msgType: TextMessage,
+			want:    `{"type":"hello"}`,
+		},
+		{
+			name: "fragmented",
+			send: func(p *peer) {
+				p.writeFrame(false, BinaryMessage, []byte("frag"))
+				p.writeFrame(false, opContinuation, []byte("men"))
+				p.writeFrame(true, opContinuation, []byte("ted"))
+			},
+			msgType: BinaryMessage,
+			want:    "fragmented",
+		},
+		{
+			name: "ping between fragments",
+			send: func(p *peer) {
+				p.writeFrame(false, TextMessage, []byte("hel"))
+				p.writeFrame(true, opPing, []byte("are you there"))
+				p.writeFrame(true, opContinuation, []byte("lo"))
+			},
+			msgType: TextMessage,
+			want:    "hello",
+			pong:    "are you there",
+		},
+		{
+			name: "unsolicited pong",
+			send: func(p *peer) {
+				p.writeFrame(true, opPong, nil)
+				p.writeFrame(true, TextMessage, []byte("after"))
+			},
+			msgType: TextMessage,
+			want:    "after",
+		},
+		{
+			name:    "126-byte length",
+			send:    func(p *peer) { p.writeFrame(true, TextMessage, bytes.Repeat([]byte("y"), 1000)) },
+			msgType: TextMessage,
+			want:    strings.Repeat("y", 1000),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, p := dialPeer(t, nil)
+			go tt.send(p)
+
+			msgType, msg, err := c.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msgType != tt.msgType || string(msg) != tt.want {
+				t.Errorf("ReadMessage = %d %q, want %d %q", msgType, msg, tt.msgType, tt.want)
+			}
+			if tt.pong != "" {
+				if fin, op, payload := p.readFrame(); !fin || op != opPong || string(payload) != tt.pong {
+					t.Errorf("client answered with opcode %d %q, want a pong %q", op, payload, tt.pong)
+				}
+			}
+		})
+	}
+}
+
+func TestReadMessageProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(p *peer)
+		want string
+	}{
+		{
+			name: "continuation without a message",
+			send: func(p *peer) { p.writeFrame(true, opContinuation, []byte("x")) },
+			want: "unexpected continuation frame",
+		},
+		{
+			name: "new message inside a fragmented one",
+			send: func(p *peer) {
+				p.writeFrame(false, TextMessage, []byte("a"))
+				p.writeFrame(true, TextMessage, []byte("b"))
+			},
+			want: "expected continuation frame",
+		},
+		{
+			name: "unknown opcode",
+			send: func(p *peer) { p.writeFrame(true, 0x3, nil) },
+			want: "unknown opcode 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, p := dialPeer(t, nil)
+			go tt.send(p)
+
+			if _, _, err := c.ReadMessage(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ReadMessage error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeerClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    CloseError
+		// echo is the payload of the client's answering close frame
+		echo []byte
+	}{
+		{
+			name:    "with a status",
+			payload: closePayload(4001, "Token revoked"),
+			want:    CloseError{Code: 4001, Reason: "Token revoked"},
+			echo:    closePayload(4001, ""),
+		},
+		{
+			name: "without a status",
+			want: CloseError{Code: closeNoStatus},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, p := dialPeer(t, nil)
+			go p.writeFrame(true, opClose, tt.payload)
+
+			_, _, err := c.ReadMessage()
+			var closeErr *CloseError
+			if !errors.As(err, &closeErr) || *closeErr != tt.want {
+				t.Fatalf("ReadMessage error = %v, want %v", err, &tt.want)
+			}
+
+			fin, op, payload := p.readFrame()
+			if !fin || op != opClose || !bytes.Equal(payload, tt.echo) {
+				t.Errorf("client answered with opcode %d %v, want a close %v", op, payload, tt.echo)
+			}
+			if _, err := p.br.ReadByte(); err != io.EOF {
+				t.Errorf("connection still open after the close handshake: %v", err)
+			}
+			if err := c.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrClosed) {
+				t.Errorf("WriteMessage after close = %v, want ErrClosed", err)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	c, p := dialPeer(t, nil)
+	read := make(chan error, 1)
+	go func() {
+		_, _, err := c.ReadMessage()
+		read <- err
+	}()
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fin, op, payload := p.readFrame()
+	if !fin || op != opClose || !bytes.Equal(payload, closePayload(CloseNormal, "")) {
+		t.Errorf("client sent opcode %d %v, want a normal close", op, payload)
+	}
+	if err := <-read; !errors.Is(err, ErrClosed) {
+		t.Errorf("pending ReadMessage = %v, want ErrClosed", err)
+	}
+	if err := c.WriteMessage(TextMessage, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteMessage after Close = %v, want ErrClosed", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+func TestCloseDoesNotBlockOnPeer(t *testing.T) {
+	// A pipe has no buffer: every write blocks until the peer reads, and
+	// this peer never does.
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &Conn{conn: client, br: bufio.NewReader(client)}
+
+	written := make(chan error, 1)
+	go func() { written <- c.WriteMessage(TextMessage, []byte("stuck")) }()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(closeTimeout + 5*time.Second):
+		t.Fatal("Close blocked on a peer that does not read")
+	}
+	if err := <-written; err == nil {
+		t.Error("blocked WriteMessage succeeded")
+	}
+}
@@ -2,6 +2,7 @@ package pidfile
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -10,23 +11,46 @@ import (
 	"time"
 )
 
+// ErrNotFound is returned by Read when the PID file does not exist.
+var ErrNotFound = errors.New("PID file not found")
+
 // PIDInfo contains information about a running server process
 type PIDInfo struct {
 	PID       int       `json:"pid"`
 	Port      int       `json:"port"`
 	StartedAt time.Time `json:"started_at"`
 	DataDir   string    `json:"data_dir"`
+
+	// Set only when the server runs under a resident nappctl supervisor
+	SupervisorPID int        `json:"supervisor_pid,omitempty"`
+	Restarts      int        `json:"restarts,omitempty"`
+	LastExitCode  int        `json:"last_exit_code,omitempty"`
+	LastExitAt    *time.Time `json:"last_exit_at,omitempty"`
+}
+
+// Supervised reports whether the server is managed by a nappctl supervisor.
+func (i *PIDInfo) Supervised() bool {
+	return i.SupervisorPID != 0
+}
+
+// Alive reports whether the server process, or its supervisor while it is
+// between restarts, is still running.
+func (i *PIDInfo) Alive() bool {
+	return IsProcessRunning(i.PID) || (i.Supervised() && IsProcessRunning(i.SupervisorPID))
 }
 
 // Write creates a PID file with the given process information.
 func Write(path string, pid int, port int, dataDir string) error {
-	info := PIDInfo{
+	return WriteInfo(path, &PIDInfo{
 		PID:       pid,
 		Port:      port,
 		StartedAt: time.Now(),
 		DataDir:   dataDir,
-	}
+	})
+}
 
+// WriteInfo writes the given PID information to a PID file.
+func WriteInfo(path string, info *PIDInfo) error {
 	data, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal PID info: %w", err)
@@ -43,7 +67,7 @@ func Read(path string) (*PIDInfo, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("PID file not found")
+			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to read PID file: %w", err)
 	}
@@ -98,16 +122,17 @@ func IsRunning(path string) bool {
 	if err != nil {
 		return false
 	}
-	return IsProcessRunning(info.PID)
+	return info.Alive()
 }
 
 // GetRunningPID reads the PID file and checks if the process is running.
-// Returns the PID if running, 0 otherwise.
+// Returns the PID if running, 0 otherwise. For a supervised server that is
+// between restarts, the supervisor's PID is returned.
 func GetRunningPID(path string) (int, error) {
 	info, err := Read(path)
 	if err != nil {
 		// If file doesn't exist, that's fine - no server is running
-		if os.IsNotExist(err) || strings.Contains(err.Error(), "PID file not found") {
+		if errors.Is(err, ErrNotFound) {
 			return 0, nil
 		}
 		return 0, err
@@ -117,7 +142,7 @@ func GetRunningPID(path string) (int, error) {
 		return 0, nil
 	}
 
-	if !IsProcessRunning(info.PID) {
+	if !info.Alive() {
 		// PID file exists but process is not running - clean up
 		if err := Remove(path); err != nil {
 			return 0, fmt.Errorf("failed to clean up stale PID file: %w", err)
@@ -125,5 +150,8 @@ func GetRunningPID(path string) (int, error) {
 		return 0, nil
 	}
 
+	if !IsProcessRunning(info.PID) {
+		return info.SupervisorPID, nil
+	}
 	return info.PID, nil
 }
@@ -1,8 +1,65 @@
 import fs from 'fs';
 import path from 'path';
+import { createHash, randomInt } from 'crypto';
+import { EventEmitter } from 'events';
 import { v4 as uuidv4 } from 'uuid';
 
-export class AuthManager {
+/**
+ * Hash a named token the way it is stored in auth.json
+ * @param {string} token
+ * @returns {string} hex SHA-256
+ */
+function hashToken(token) {
+  return createHash('sha256').update(token).digest('hex');
+}
+
+/**
+ * Public ID of a session: a prefix of its token's hash, so sessions can be
+ * listed and revoked without revealing their tokens
+ * @param {string} token
+ * @returns {string}
+ */
+export function sessionId(token) {
+  return hashToken(token).slice(0, 12);
+}
+
+// How long a pairing code can be redeemed
+export const PAIRING_TTL = 5 * 60 * 1000;
+
+// Failed redemptions, while codes are pending, before all of them are voided
+const MAX_PAIRING_FAILURES = 10;
+
+// Unambiguous characters for pairing codes (no 0/O, 1/I/L, U)
+const PAIRING_ALPHABET = 'ABCDEFGHJKMNPQRSTVWXYZ23456789';
+
+/**
+ * Generate a pairing code such as "K7QX-3MHA"
+ * @returns {string}
+ */
+function generatePairingCode() {
+  let code = '';
+  for (let i = 0; i < 8; i++) {
+    code += PAIRING_ALPHABET[randomInt(PAIRING_ALPHABET.length)];
+  }
+  return `${code.slice(0, 4)}-${code.slice(4)}`;
+}
+
+/**
+ * Normalize a pairing code as typed: case and separators do not matter
+ * @param {string} code
+ * @returns {string}
+ */
+function normalizePairingCode(code) {
+  const chars = String(code || '').toUpperCase().replace(/[^A-Z0-9]/g, '');
+  return chars.length === 8 ? `${chars.slice(0, 4)}-${chars.slice(4)}` : chars;
+}
+
+/**
+ * Emits 'revoked' when tokens may have stopped resolving: sessions were
+ * revoked or expired, or auth.json was reloaded. Listeners re-check the
+ * tokens they hold, e.g. to close WebSocket connections.
+ */
+export class AuthManager extends EventEmitter {
   /**
    * Create an AuthManager with persistent storage
    * @param {Object} options
@@ -10,9 +67,15 @@ export class AuthManager {
    * @param {string} options.masterToken - Override master token (from env var)
    */
   constructor(options = {}) {
+    super();
     this.dataDir = options.dataDir || path.join(process.cwd(), '.napp-trapp-data');
     this.dataFilePath = path.join(this.dataDir, 'auth.json');
     this.sessions = new Map();
+    // Named tokens created by nappctl: name -> { tokenHash, scopes, createdAt, expiresAt, lastUsed }
+    this.tokens = new Map();
+    // Pending pairing codes, kept in memory only: code -> { sessionToken, expiresAt }
+    this.pairings = new Map();
+    this.pairingFailures = 0;
     
     // Ensure data directory exists
     if (!fs.existsSync(this.dataDir)) {
@@ -21,12 +84,16 @@ export class AuthManager {
     
     // Load existing data or initialize new
     const existingData = this._loadData();
+    // Where nappctl keeps the master token: 'file' (auth.json), or a secret
+    // store the server cannot read ('encrypted', 'keyring'), in which case
+    // nappctl passes it as AUTH_TOKEN and it is never written to auth.json
+    this.secretStore = existingData?.secretStore || 'file';
     
     if (options.masterToken) {
       // If master token provided via env var, use it (allows override)
       this.masterToken = options.masterToken;
       // Check if it changed from saved token
-      if (existingData && existingData.masterToken !== options.masterToken) {
+      if (existingData && existingData.masterToken && existingData.masterToken !== options.masterToken) {
         console.log('[Auth] Master token changed via environment variable');
         // Clear sessions since master token changed
         this.sessions.clear();
@@ -39,11 +106,17 @@ export class AuthManager {
       this.masterToken = existingData.masterToken;
       this._restoreSessions(existingData.sessions);
       console.log('[Auth] Loaded persisted authentication token');
+    } else if (this.secretStore !== 'file') {
+      // Usable until restarted, but not persisted over the secret store's token
+      this.masterToken = uuidv4();
+      this._restoreSessions(existingData.sessions);
+      console.warn(`[Auth] The master token is kept in nappctl's ${this.secretStore} secret store; start the server with nappctl or set AUTH_TOKEN. Using a temporary token.`);
     } else {
       // Generate new token
       this.masterToken = uuidv4();
       console.log('[Auth] Generated new authentication token');
     }
+    this._restoreTokens(existingData?.tokens);
     
     // Save initial state
     this._saveData();
@@ -68,18 +141,54 @@ export class AuthManager {
   }
 
   _saveData() {
+    try {
+      // nappctl rewrites auth.json to rotate tokens and revoke sessions;
+      // adopt its changes rather than writing stale state over them
+      const onDisk = this._changedOnDisk();
+      if (onDisk) {
+        console.log('[Auth] auth.json was changed by another process; reloading it before saving');
+        this._adopt(onDisk);
+      }
+      this._writeData();
+    } catch (error) {
+      console.error('[Auth] Failed to save auth data:', error.message);
+    }
+  }
+
+  _writeData() {
     try {
       const data = {
-        masterToken: this.masterToken,
+        masterToken: this.secretStore === 'file' ? this.masterToken : undefined,
+        secretStore: this.secretStore === 'file' ? undefined : this.secretStore,
         sessions: this._serializeSessions(),
+        tokens: Object.fromEntries(this.tokens),
         lastSaved: Date.now()
       };
       fs.writeFileSync(this.dataFilePath, JSON.stringify(data, null, 2));
+      this._lastSaved = data.lastSaved;
+      this._savedMtime = fs.statSync(this.dataFilePath).mtimeMs;
     } catch (error) {
       console.error('[Auth] Failed to save auth data:', error.message);
     }
   }
 
+  /**
+   * The content of auth.json if another process wrote it since our last
+   * save, detected by its lastSaved and modification time
+   * @returns {Object | null}
+   */
+  _changedOnDisk() {
+    if (this._lastSaved === undefined || !fs.existsSync(this.dataFilePath)) {
+      return null;
+    }
+    const mtime = fs.statSync(this.dataFilePath).mtimeMs;
+    const data = this._loadData();
+    if (!data || (data.lastSaved === this._lastSaved && mtime === this._savedMtime)) {
+      return null;
+    }
+    return data;
+  }
+
   _serializeSessions() {
     const sessions = {};
     for (const [token, session] of this.sessions) {
@@ -106,28 +215,228 @@ export class AuthManager {
     }
   }
 
+  _restoreTokens(tokensObj) {
+    const previous = this.tokens;
+    this.tokens = new Map();
+    for (const [name, entry] of Object.entries(tokensObj || {})) {
+      if (!entry || !entry.tokenHash) continue;
+      // Keep usage recorded since the last save
+      const known = previous.get(name);
+      if (known && known.tokenHash === entry.tokenHash && known.lastUsed > (entry.lastUsed || 0)) {
+        entry.lastUsed = known.lastUsed;
+      }
+      this.tokens.set(name, entry);
+    }
+  }
+
   validateToken(token) {
-    if (!token) return false;
-    
+    return this.resolveToken(token) !== null;
+  }
+
+  /**
+   * Resolve a token to the access it grants. Expired named tokens are
+   * rejected but kept until nappctl revokes them.
+   * @param {string} token
+   * @param {Object} [meta]
+   * @param {string} [meta.ip] - Client address, recorded as a session's last IP
+   * @returns {{ kind: 'master' | 'session' | 'named', name?: string, scopes: string[] | null } | null}
+   *   scopes is null for unscoped access; null if the token is not valid
+   */
+  resolveToken(token, meta = {}) {
+    if (!token) return null;
+
+    if (token === this.masterToken) {
+      return { kind: 'master', scopes: null };
+    }
+
     // Update session activity on validation
     if (this.sessions.has(token)) {
-      this.sessions.get(token).lastActivity = Date.now();
+      const session = this.sessions.get(token);
+      session.lastActivity = Date.now();
+      if (meta.ip) {
+        session.ip = meta.ip;
+      }
+      return { kind: 'session', id: sessionId(token), scopes: null };
     }
-    
-    return token === this.masterToken || this.sessions.has(token);
+
+    if (this.tokens.size > 0) {
+      const hash = hashToken(token);
+      const now = Date.now();
+      for (const [name, entry] of this.tokens) {
+        if (entry.tokenHash === hash) {
+          if (entry.expiresAt && now >= entry.expiresAt) {
+            return null;
+          }
+          entry.lastUsed = now;
+          return { kind: 'named', name, scopes: entry.scopes || [] };
+        }
+      }
+    }
+
+    return null;
   }
 
-  createSession(masterToken) {
+  /**
+   * Create a session for a device, authorized by the master token
+   * @param {string} masterToken
+   * @param {Object} [device]
+   * @param {string} [device.device] - Device name shown when listing sessions
+   * @param {string} [device.userAgent]
+   * @param {string} [device.ip]
+   * @returns {string | null} the session token, or null if masterToken is wrong
+   */
+  createSession(masterToken, device = {}) {
     if (masterToken !== this.masterToken) {
       return null;
     }
     const sessionToken = crypto.randomUUID();
+    this._addSession(sessionToken, device);
+    return sessionToken;
+  }
+
+  _addSession(sessionToken, device) {
+    const now = Date.now();
     this.sessions.set(sessionToken, {
-      createdAt: Date.now(),
-      lastActivity: Date.now()
+      device: device.device || undefined,
+      userAgent: device.userAgent || undefined,
+      ip: device.ip || undefined,
+      createdAt: now,
+      lastActivity: now
     });
     this._saveData();
-    return sessionToken;
+  }
+
+  /**
+   * Mint a one-time pairing code bound to a fresh session token, authorized
+   * by the master token. The session is created when a device redeems the
+   * code, so the master token never has to leave this machine.
+   * @param {string} masterToken
+   * @returns {{ code: string, id: string, expiresAt: number } | null} id is
+   *   the ID the session will have; null if masterToken is wrong
+   */
+  createPairing(masterToken) {
+    if (masterToken !== this.masterToken) {
+      return null;
+    }
+    this._prunePairings();
+
+    let code;
+    do {
+      code = generatePairingCode();
+    } while (this.pairings.has(code));
+    const sessionToken = crypto.randomUUID();
+    const expiresAt = Date.now() + PAIRING_TTL;
+    this.pairings.set(code, { sessionToken, expiresAt });
+    return { code, id: sessionId(sessionToken), expiresAt };
+  }
+
+  /**
+   * Redeem a pairing code, creating the session bound to it. Each code
+   * works once; after too many wrong codes every pending code is voided.
+   * @param {string} code
+   * @param {Object} [device] - As for createSession
+   * @returns {{ token: string, id: string } | null} null if the code is
+   *   unknown, used or expired
+   */
+  redeemPairing(code, device = {}) {
+    this._prunePairings();
+    const key = normalizePairingCode(code);
+    const pairing = this.pairings.get(key);
+    if (!pairing) {
+      if (this.pairings.size > 0 && ++this.pairingFailures >= MAX_PAIRING_FAILURES) {
+        console.warn(`[Auth] ${this.pairingFailures} wrong pairing codes; voiding ${this.pairings.size} pending code(s)`);
+        this.pairings.clear();
+        this.pairingFailures = 0;
+      }
+      return null;
+    }
+
+    this.pairings.delete(key);
+    this._addSession(pairing.sessionToken, device);
+    console.log(`[Auth] Paired device${device.device ? ` "${device.device}"` : ''}`);
+    return { token: pairing.sessionToken, id: sessionId(pairing.sessionToken) };
+  }
+
+  /**
+   * State of a pairing, by the ID its session will have
+   * @param {string} id
+   * @returns {{ status: 'pending' | 'paired' | 'expired', expiresAt?: number, device?: string }}
+   *   expired also covers cancelled and unknown pairings
+   */
+  getPairing(id) {
+    this._prunePairings();
+    for (const pairing of this.pairings.values()) {
+      if (sessionId(pairing.sessionToken) === id) {
+        return { status: 'pending', expiresAt: pairing.expiresAt };
+      }
+    }
+    for (const [token, session] of this.sessions) {
+      if (sessionId(token) === id) {
+        return { status: 'paired', device: session.device };
+      }
+    }
+    return { status: 'expired' };
+  }
+
+  /**
+   * Void a pending pairing code
+   * @param {string} id - As for getPairing
+   * @returns {boolean} whether a pending code was voided
+   */
+  cancelPairing(id) {
+    for (const [code, pairing] of this.pairings) {
+      if (sessionId(pairing.sessionToken) === id) {
+        return this.pairings.delete(code);
+      }
+    }
+    return false;
+  }
+
+  _prunePairings() {
+    const now = Date.now();
+    for (const [code, pairing] of this.pairings) {
+      if (now >= pairing.expiresAt) {
+        this.pairings.delete(code);
+      }
+    }
+    if (this.pairings.size === 0) {
+      this.pairingFailures = 0;
+    }
+  }
+
+  /**
+   * List sessions without their tokens
+   * @returns {Array<{ id: string, device?: string, userAgent?: string, ip?: string, createdAt: number, lastActivity: number }>}
+   */
+  listSessions() {
+    return [...this.sessions].map(([token, session]) => ({ id: sessionId(token), ...session }));
+  }
+
+  /**
+   * Revoke the session with the given public ID
+   * @param {string} id
+   * @returns {boolean} whether a session was revoked
+   */
+  revokeSessionById(id) {
+    for (const token of this.sessions.keys()) {
+      if (sessionId(token) === id) {
+        return this.revokeSession(token);
+      }
+    }
+    return false;
+  }
+
+  /**
+   * Revoke every session, and void pending pairing codes
+   * @returns {number} the number of sessions revoked
+   */
+  revokeAllSessions() {
+    const count = this.sessions.size;
+    this.sessions.clear();
+    this.pairings.clear();
+    this._saveData();
+    this.emit('revoked');
+    return count;
   }
 
   refreshSession(token) {
@@ -142,10 +451,78 @@ export class AuthManager {
     const result = this.sessions.delete(token);
     if (result) {
       this._saveData();
+      this.emit('revoked');
     }
     return result;
   }
 
+  /**
+   * Re-read auth.json after another process (nappctl) rewrote it, adopting
+   * its master token and named tokens and dropping sessions that were
+   * removed from it.
+   * Activity of sessions that are kept is not lost.
+   * @param {string} [masterToken] - New master token, used when auth.json
+   *   names a secret store the server cannot read; the current one is kept
+   *   if omitted
+   * @returns {{ changed: boolean, sessionCount: number } | null} null if
+   *   the file holds no master token
+   */
+  reload(masterToken) {
+    const data = this._loadData();
+    if (!data) {
+      return null;
+    }
+
+    const changed = this._adopt(data, masterToken);
+    if (changed === null) {
+      return null;
+    }
+
+    this._writeData();
+    console.log(`[Auth] Reloaded authentication data${changed ? ' (master token changed)' : ''}`);
+    return { changed, sessionCount: this.sessions.size };
+  }
+
+  /**
+   * Adopt the master token, named tokens and sessions of auth.json's
+   * content, as for reload, without saving
+   * @param {Object} data - Parsed auth.json
+   * @param {string} [masterToken] - As for reload
+   * @returns {boolean | null} whether the master token changed; null if
+   *   data holds no master token
+   */
+  _adopt(data, masterToken) {
+    const secretStore = data.secretStore || 'file';
+    const newToken = secretStore === 'file' ? data.masterToken : masterToken || this.masterToken;
+    if (!newToken) {
+      return null;
+    }
+
+    const changed = newToken !== this.masterToken;
+    this.masterToken = newToken;
+    if (changed) {
+      // Codes minted with the old token no longer pair
+      this.pairings.clear();
+    }
+    this.secretStore = secretStore;
+
+    this._restoreTokens(data.tokens);
+
+    const fileSessions = data.sessions || {};
+    for (const token of this.sessions.keys()) {
+      if (!(token in fileSessions)) {
+        this.sessions.delete(token);
+      }
+    }
+    for (const [token, session] of Object.entries(fileSessions)) {
+      if (!this.sessions.has(token)) {
+        this.sessions.set(token, session);
+      }
+    }
+    this.emit('revoked');
+    return changed;
+  }
+
   getMasterToken() {
     return this.masterToken;
   }
@@ -166,6 +543,7 @@ export class AuthManager {
     if (cleaned > 0) {
       console.log(`[Auth] Cleaned up ${cleaned} expired session(s)`);
       this._saveData();
+      this.emit('revoked');
     }
   }
 
@@ -182,6 +560,7 @@ export class AuthManager {
   getInfo() {
     return {
       dataFile: this.dataFilePath,
+      secretStore: this.secretStore,
       sessionCount: this.sessions.size,
       isPersisted: fs.existsSync(this.dataFilePath)
     };
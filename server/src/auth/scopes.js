@@ -0,0 +1,73 @@
+/**
+ * Scopes that limit what a named token may do. The master token and
+ * paired sessions are not scoped.
+ *
+ * - read-only:   GET requests anywhere under /api, and file watching
+ * - files-write: writing, uploading, moving and deleting files, and git
+ *                operations that change the working tree
+ * - terminal:    the terminals API and terminal WebSocket messages
+ * - chat:        the conversations API and chat WebSocket messages
+ */
+export const TOKEN_SCOPES = ['read-only', 'files-write', 'terminal', 'chat'];
+
+/**
+ * Scopes that grant an HTTP request. Any one of them is enough; an empty
+ * list means only unscoped access (master token or session) will do.
+ * @param {string} method - HTTP method
+ * @param {string} path - Path below /api, e.g. /files/write
+ * @returns {string[]}
+ */
+export function requiredScopes(method, path) {
+  const read = method === 'GET' || method === 'HEAD';
+  // Express routes case-insensitively, so /api/AUTH/sessions reaches the
+  // auth router: compare the area in lowercase, as routing does
+  const area = path.toLowerCase().split('/').find(Boolean) || '';
+
+  switch (area) {
+    case 'auth':
+      return [];
+    case 'files':
+    case 'git':
+      return read ? ['read-only', 'files-write'] : ['files-write'];
+    case 'terminals':
+      return read ? ['read-only', 'terminal'] : ['terminal'];
+    case 'conversations':
+      return read ? ['read-only', 'chat'] : ['chat'];
+    default:
+      return read ? ['read-only'] : [];
+  }
+}
+
+/**
+ * Scopes that grant a WebSocket message, or null if any authenticated
+ * client may send it.
+ * @param {string} type - Message type
+ * @returns {string[] | null}
+ */
+export function requiredWebSocketScopes(type) {
+  if (type === 'ping') {
+    return null;
+  }
+  if (type.startsWith('terminal')) {
+    return ['terminal'];
+  }
+  if (type.startsWith('chat')) {
+    return ['chat'];
+  }
+  if (type === 'watch' || type === 'unwatch') {
+    return ['read-only', 'files-write'];
+  }
+  return [];
+}
+
+/**
+ * Whether access resolved by AuthManager.resolveToken satisfies required.
+ * @param {{ scopes: string[] | null }} access
+ * @param {string[] | null} required
+ */
+export function hasScope(access, required) {
+  if (!access.scopes || required === null) {
+    return true;
+  }
+  return required.some(scope => access.scopes.includes(scope));
+}
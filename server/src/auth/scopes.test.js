@@ -0,0 +1,101 @@
+import { test } from 'node:test';
+import assert from 'node:assert/strict';
+import { requiredScopes, requiredWebSocketScopes, hasScope } from './scopes.js';
+
+test('requiredScopes maps each area to its scopes', () => {
+  const cases = [
+    // [method, path, scopes]
+    ['GET', '/files/read', ['read-only', 'files-write']],
+    ['HEAD', '/files/read', ['read-only', 'files-write']],
+    ['POST', '/files/write', ['files-write']],
+    ['DELETE', '/files/delete', ['files-write']],
+    ['GET', '/git/p1/status', ['read-only', 'files-write']],
+    ['POST', '/git/p1/commit', ['files-write']],
+    ['GET', '/terminals', ['read-only', 'terminal']],
+    ['POST', '/terminals', ['terminal']],
+    ['DELETE', '/terminals/t1', ['terminal']],
+    ['GET', '/conversations', ['read-only', 'chat']],
+    ['POST', '/conversations', ['chat']],
+    ['GET', '/projects', ['read-only']],
+    ['GET', '/system/info', ['read-only']],
+    ['GET', '/', ['read-only']],
+    // Writes outside the scoped areas need unscoped access
+    ['POST', '/projects', []],
+    ['PUT', '/logs', []],
+    ['DELETE', '/logs', []],
+    // The auth API is unscoped-only, even for reads
+    ['GET', '/auth/sessions', []],
+    ['GET', '/auth/tokens', []],
+    ['POST', '/auth/tokens', []],
+    ['DELETE', '/auth/sessions', []],
+    // Express routes case-insensitively, and so must the scope check
+    ['GET', '/AUTH/sessions', []],
+    ['GET', '/Auth/tokens', []],
+    ['POST', '/FILES/write', ['files-write']],
+    ['POST', '/Terminals', ['terminal']],
+    ['POST', '//conversations', ['chat']],
+  ];
+  for (const [method, path, scopes] of cases) {
+    assert.deepEqual(requiredScopes(method, path), scopes, `${method} ${path}`);
+  }
+});
+
+test('requiredWebSocketScopes maps each message type to its scopes', () => {
+  const cases = [
+    ['ping', null],
+    ['terminalCreate', ['terminal']],
+    ['terminalInput', ['terminal']],
+    ['terminalAttach', ['terminal']],
+    ['chatAttach', ['chat']],
+    ['chatMessage', ['chat']],
+    ['chatApproval', ['chat']],
+    ['watch', ['read-only', 'files-write']],
+    ['unwatch', ['read-only', 'files-write']],
+    // Message types nobody scoped for are unscoped-only
+    ['auth', []],
+    ['somethingNew', []],
+  ];
+  for (const [type, scopes] of cases) {
+    assert.deepEqual(requiredWebSocketScopes(type), scopes, type);
+  }
+});
+
+test('hasScope grants when any required scope is held', () => {
+  const master = { scopes: null };
+  const reader = { scopes: ['read-only'] };
+  const writer = { scopes: ['files-write', 'chat'] };
+  const cases = [
+    // [access, required, granted]
+    [master, [], true],
+    [master, ['terminal'], true],
+    [reader, null, true],
+    [reader, [], false],
+    [reader, ['read-only', 'files-write'], true],
+    [reader, ['files-write'], false],
+    [reader, ['terminal'], false],
+    [writer, ['read-only', 'files-write'], true],
+    [writer, ['chat'], true],
+    [writer, ['terminal'], false],
+    [writer, [], false],
+    [{ scopes: [] }, ['read-only'], false],
+  ];
+  for (const [access, required, granted] of cases) {
+    assert.equal(hasScope(access, required), granted,
+      `${JSON.stringify(access.scopes)} for ${JSON.stringify(required)}`);
+  }
+});
+
+test('a read-only token can read but not write or use the auth API', () => {
+  const reader = { scopes: ['read-only'] };
+  const allowed = (method, path) => hasScope(reader, requiredScopes(method, path));
+
+  assert.equal(allowed('GET', '/files/read'), true);
+  assert.equal(allowed('GET', '/terminals'), true);
+  assert.equal(allowed('POST', '/files/write'), false);
+  assert.equal(allowed('POST', '/terminals'), false);
+  assert.equal(allowed('POST', '/conversations'), false);
+  assert.equal(allowed('GET', '/auth/tokens'), false);
+  assert.equal(allowed('GET', '/AUTH/tokens'), false);
+  assert.equal(hasScope(reader, requiredWebSocketScopes('terminalInput')), false);
+  assert.equal(hasScope(reader, requiredWebSocketScopes('watch')), true);
+});
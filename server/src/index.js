@@ -10,8 +10,10 @@ import { fileURLToPath } from "url";
 import QRCode from "qrcode";
 
 import { setupRoutes } from "./routes/index.js";
+import { pairRoutes } from "./routes/auth.js";
 import { setupWebSocket } from "./websocket/index.js";
 import { AuthManager } from "./auth/AuthManager.js";
+import { hasScope, requiredScopes } from "./auth/scopes.js";
 import { LogManager } from "./utils/LogManager.js";
 import { chatProcessManager } from "./utils/ChatProcessManager.js";
 import { tailscaleManager } from "./utils/TailscaleManager.js";
@@ -110,7 +112,7 @@ const LOCAL_IP = getLocalIP();
 
 // Generate connection URL for QR code - includes token in URL for one-scan connection
 function getConnectionUrl() {
-  return `http://${LOCAL_IP}:${PORT}/?token=${AUTH_TOKEN}`;
+  return `http://${LOCAL_IP}:${PORT}/?token=${authManager.getMasterToken()}`;
 }
 
 // Middleware
@@ -118,10 +120,14 @@ app.use(cors());
 app.use(express.json());
 app.use(express.static(CLIENT_DIST_PATH));
 
+// Pairing codes are redeemed by devices that have no token yet
+app.use("/api/auth/pair", pairRoutes);
+
 // Auth middleware for API routes
 app.use("/api", (req, res, next) => {
   const token = req.headers.authorization?.replace("Bearer ", "");
-  if (!authManager.validateToken(token)) {
+  const access = authManager.resolveToken(token, { ip: req.ip });
+  if (!access) {
     logger.warn("Auth", "Unauthorized API access attempt", {
       path: req.path,
       method: req.method,
@@ -129,6 +135,18 @@ app.use("/api", (req, res, next) => {
     });
     return res.status(401).json({ error: "Unauthorized" });
   }
+  if (!hasScope(access, requiredScopes(req.method, req.path))) {
+    logger.warn("Auth", "Token scope does not allow API access", {
+      token: access.name,
+      path: req.path,
+      method: req.method,
+      ip: req.ip,
+    });
+    return res.status(403).json({
+      error: `Token '${access.name}' is not allowed to ${req.method} ${req.path}`,
+    });
+  }
+  req.auth = access;
   next();
 });
 
@@ -151,8 +169,9 @@ app.use("/api", (req, res, next) => {
   next();
 });
 
-// Make DATA_DIR available to routes via app.locals
+// Make DATA_DIR and the auth manager available to routes via app.locals
 app.locals.dataDir = DATA_DIR;
+app.locals.authManager = authManager;
 
 // Setup routes
 setupRoutes(app);
@@ -0,0 +1,137 @@
+import { Router } from 'express';
+import { sessionId } from '../auth/AuthManager.js';
+
+const router = Router();
+
+/**
+ * POST /api/auth/reload
+ * Re-read auth.json so a token rotated by nappctl takes effect without a
+ * restart. The request itself is authenticated with the token in use
+ * before the reload.
+ *
+ * Body: { masterToken?: string } - the new master token, when nappctl keeps
+ * it in a secret store the server cannot read. Only accepted from a request
+ * made with the master token.
+ */
+router.post('/reload', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  const masterToken = req.auth?.kind === 'master' ? req.body?.masterToken : undefined;
+  const result = authManager.reload(masterToken);
+  if (!result) {
+    return res.status(409).json({ error: 'auth.json has no master token; nothing was reloaded' });
+  }
+  res.json({ success: true, ...result });
+});
+
+/**
+ * GET /api/auth/sessions
+ * List paired sessions (without their tokens)
+ */
+router.get('/sessions', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  res.json({ sessions: authManager.listSessions() });
+});
+
+/**
+ * POST /api/auth/sessions
+ * Create a session for the calling device. Requires the master token.
+ *
+ * Body: { device?: string }
+ */
+router.post('/sessions', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  const token = req.headers.authorization?.replace('Bearer ', '');
+  const sessionToken = authManager.createSession(token, {
+    device: req.body?.device,
+    userAgent: req.get('user-agent'),
+    ip: req.ip,
+  });
+  if (!sessionToken) {
+    return res.status(403).json({ error: 'Sessions can only be created with the master token' });
+  }
+  res.status(201).json({ success: true, token: sessionToken, id: sessionId(sessionToken) });
+});
+
+/**
+ * DELETE /api/auth/sessions/:id
+ * Revoke one session by its ID
+ */
+router.delete('/sessions/:id', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  if (!authManager.revokeSessionById(req.params.id)) {
+    return res.status(404).json({ error: `Session not found: ${req.params.id}` });
+  }
+  res.json({ success: true, revoked: 1 });
+});
+
+/**
+ * DELETE /api/auth/sessions
+ * Revoke every session
+ */
+router.delete('/sessions', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  res.json({ success: true, revoked: authManager.revokeAllSessions() });
+});
+
+/**
+ * POST /api/auth/pairings
+ * Mint a one-time pairing code for a new device (see POST /api/auth/pair).
+ * Requires the master token.
+ */
+router.post('/pairings', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  const token = req.headers.authorization?.replace('Bearer ', '');
+  const pairing = authManager.createPairing(token);
+  if (!pairing) {
+    return res.status(403).json({ error: 'Pairing codes can only be created with the master token' });
+  }
+  res.status(201).json({ success: true, ...pairing });
+});
+
+/**
+ * GET /api/auth/pairings/:id
+ * State of a pairing: pending, paired (with the device name) or expired
+ */
+router.get('/pairings/:id', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  res.json(authManager.getPairing(req.params.id));
+});
+
+/**
+ * DELETE /api/auth/pairings/:id
+ * Void a pending pairing code
+ */
+router.delete('/pairings/:id', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  if (!authManager.cancelPairing(req.params.id)) {
+    return res.status(404).json({ error: `No pending pairing: ${req.params.id}` });
+  }
+  res.json({ success: true });
+});
+
+/**
+ * Unauthenticated: a new device has no token yet. Mounted at
+ * /api/auth/pair ahead of the API auth middleware.
+ */
+const pairRouter = Router();
+
+/**
+ * POST /api/auth/pair
+ * Redeem a pairing code for a session token
+ *
+ * Body: { code: string, device?: string }
+ */
+pairRouter.post('/', (req, res) => {
+  const authManager = req.app.locals.authManager;
+  const result = authManager.redeemPairing(req.body?.code, {
+    device: req.body?.device,
+    userAgent: req.get('user-agent'),
+    ip: req.ip,
+  });
+  if (!result) {
+    return res.status(401).json({ error: 'Invalid or expired pairing code' });
+  }
+  res.status(201).json({ success: true, ...result });
+});
+
+export { router as authRoutes, pairRouter as pairRoutes };
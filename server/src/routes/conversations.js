@@ -134,6 +134,17 @@ router.get("/notifications/pending", async (req, res) => {
   }
 });
 
+// List tool calls awaiting approval across all chats. Unlike the
+// notifications above, reading them does not clear them.
+router.get("/approvals/pending", async (req, res) => {
+  try {
+    res.json({ approvals: chatProcessManager.getPendingApprovals() });
+  } catch (error) {
+    console.error("Error fetching pending approvals:", error);
+    res.status(500).json({ error: "Failed to fetch pending approvals" });
+  }
+});
+
 // Get tool availability status
 router.get("/tools/availability", async (req, res) => {
   try {
@@ -546,6 +557,39 @@ router.post("/:conversationId/fork", async (req, res) => {
   }
 });
 
+// Approve or reject a tool call awaiting approval (same as the chatApproval
+// WebSocket message)
+router.post("/:conversationId/approvals", async (req, res) => {
+  try {
+    const { conversationId } = req.params;
+    const { toolUseId, approved } = req.body;
+
+    if (!toolUseId || typeof approved !== "boolean") {
+      return res.status(400).json({ error: "toolUseId and approved are required" });
+    }
+    if (!chatProcessManager.hasChat(conversationId)) {
+      return res.status(404).json({ error: "Chat not found" });
+    }
+
+    const result = await chatProcessManager.sendApproval(conversationId, approved, toolUseId);
+    if (!result.success) {
+      return res.status(404).json({ error: result.message || "Approval not found" });
+    }
+
+    logger.info("Chat", approved ? "Tool call approved" : "Tool call rejected", {
+      conversationId,
+      toolUseId,
+    });
+    res.json({ success: true });
+  } catch (error) {
+    console.error("Error answering approval:", error);
+    res.status(500).json({
+      error: "Failed to answer approval",
+      details: error.message,
+    });
+  }
+});
+
 // Upload files for a conversation (images, documents)
 router.post("/:conversationId/upload", upload.array("files", 5), async (req, res) => {
   try {
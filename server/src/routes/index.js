@@ -6,6 +6,7 @@ import { terminalRoutes } from './terminals.js';
 import { gitRoutes } from './git.js';
 import { suggestionsRoutes } from './suggestions.js';
 import { logsRoutes } from './logs.js';
+import { authRoutes } from './auth.js';
 
 export function setupRoutes(app) {
   app.use('/api/projects', projectRoutes);
@@ -16,6 +17,7 @@ export function setupRoutes(app) {
   app.use('/api/git', gitRoutes);
   app.use('/api/suggestions', suggestionsRoutes);
   app.use('/api/logs', logsRoutes);
+  app.use('/api/auth', authRoutes);
   
   // Health check (no auth required)
   app.get('/health', (req, res) => {
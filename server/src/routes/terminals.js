@@ -343,6 +343,54 @@ terminalRoutes.post('/:id/resize', (req, res) => {
   }
 });
 
+/**
+ * GET /api/terminals/:id/scrollback
+ * Get a terminal's scrollback history
+ * Query params:
+ *   - lines: Number of lines to capture for tmux terminals (default: 2000)
+ * PTY terminals return their buffered output.
+ */
+terminalRoutes.get('/:id/scrollback', (req, res) => {
+  try {
+    const { id } = req.params;
+    const lines = parseInt(req.query.lines, 10) || 2000;
+
+    // Handle PTY terminals
+    if (ptyManager.isPTYTerminal(id)) {
+      if (!ptyManager.getTerminal(id)) {
+        return res.status(404).json({
+          error: 'Terminal not found',
+          id
+        });
+      }
+      return res.json({ terminalId: id, content: ptyManager.getBuffer(id) });
+    }
+
+    // Handle tmux terminals
+    if (tmuxManager.isTmuxTerminal(id)) {
+      const { sessionName, windowIndex } = tmuxManager.parseTerminalId(id);
+      if (!tmuxManager.sessionExists(sessionName)) {
+        return res.status(404).json({
+          error: 'Tmux session not found',
+          id
+        });
+      }
+      const content = tmuxManager.getWindowScrollback(sessionName, windowIndex, lines);
+      return res.json({ terminalId: id, content });
+    }
+
+    return res.status(400).json({
+      error: 'Invalid terminal ID format'
+    });
+  } catch (error) {
+    console.error('Error getting terminal scrollback:', error);
+    res.status(500).json({
+      error: 'Failed to get terminal scrollback',
+      message: error.message
+    });
+  }
+});
+
 /**
  * DELETE /api/terminals/:id
  * Kill/close a terminal
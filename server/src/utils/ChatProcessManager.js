@@ -843,7 +843,7 @@ class ChatProcessManager extends EventEmitter {
 
             // Store for later execution if user approves
             if (permMap) {
-              permMap.set(denialId, denial);
+              permMap.set(denialId, { ...denial, requestedAt: timestamp });
             }
 
             // Build a human-readable prompt from the tool input
@@ -994,6 +994,32 @@ class ChatProcessManager extends EventEmitter {
     return all;
   }
 
+  /**
+   * List the permission denials awaiting approval across all chats, without
+   * clearing them. Used by clients that answer approvals automatically.
+   */
+  getPendingApprovals() {
+    const approvals = [];
+
+    for (const [conversationId, permMap] of this.pendingPermissions) {
+      const chatInfo = this.processes.get(conversationId);
+      for (const [toolUseId, denial] of permMap) {
+        approvals.push({
+          conversationId,
+          toolUseId,
+          toolName: denial.tool_name,
+          input: denial.tool_input || {},
+          tool: chatInfo?.tool,
+          topic: chatInfo?.topic,
+          projectPath: chatInfo?.projectPath,
+          requestedAt: denial.requestedAt,
+        });
+      }
+    }
+
+    return approvals;
+  }
+
   /**
    * Get and clear pending notifications for a specific conversation.
    */
@@ -6,6 +6,7 @@ import { ptyManager, tmuxManager } from '../routes/terminals.js';
 import { LogManager } from '../utils/LogManager.js';
 import { chatProcessManager } from '../utils/ChatProcessManager.js';
 import { ChatPersistenceStore } from '../utils/ChatPersistenceStore.js';
+import { hasScope, requiredWebSocketScopes } from '../auth/scopes.js';
 
 const logger = LogManager.getInstance();
 const tmuxSubscribers = new Map(); // "sessionName:windowIndex" -> Set of clientIds
@@ -15,9 +16,12 @@ const watchers = new Map();
 const ptySubscribers = new Map(); // terminalId -> Set of clientIds
 
 export function setupWebSocket(wss, authManager) {
+  authManager.on('revoked', () => closeRevokedClients(authManager));
+
   wss.on('connection', (ws, req) => {
     const clientId = crypto.randomUUID();
     let authenticated = false;
+    let authToken = null;
     let watchedPaths = new Set();
     let subscribedTerminals = new Set();
     
@@ -36,7 +40,8 @@ export function setupWebSocket(wss, authManager) {
         if (message.type === 'auth') {
           if (authManager.validateToken(message.token)) {
             authenticated = true;
-            clients.set(clientId, { ws, watchedPaths, subscribedTerminals });
+            authToken = message.token;
+            clients.set(clientId, { ws, authToken, watchedPaths, subscribedTerminals });
             ws.send(JSON.stringify({
               type: 'auth',
               success: true,
@@ -79,6 +84,25 @@ export function setupWebSocket(wss, authManager) {
           }));
           return;
         }
+
+        // Re-checked on every message so revoked and expired tokens stop
+        // working on open connections too
+        const access = authManager.resolveToken(authToken);
+        if (!access) {
+          ws.send(JSON.stringify({
+            type: 'error',
+            message: 'Token is no longer valid'
+          }));
+          ws.close(4001, 'Token revoked');
+          return;
+        }
+        if (!hasScope(access, requiredWebSocketScopes(message.type))) {
+          ws.send(JSON.stringify({
+            type: 'error',
+            message: `Token '${access.name}' is not allowed to send ${message.type}`
+          }));
+          return;
+        }
         
         switch (message.type) {
           case 'watch':
@@ -343,6 +367,22 @@ function handleUnwatch(clientId, message) {
   }
 }
 
+// Close the connections of clients whose token no longer resolves, as soon
+// as it is revoked rather than on their next message
+function closeRevokedClients(authManager) {
+  for (const [clientId, client] of clients) {
+    if (client.ws.readyState !== 1 || authManager.validateToken(client.authToken)) {
+      continue;
+    }
+    logger.info('WebSocket', 'Closing connection of revoked token', { clientId });
+    client.ws.send(JSON.stringify({
+      type: 'error',
+      message: 'Token is no longer valid'
+    }));
+    client.ws.close(4001, 'Token revoked');
+  }
+}
+
 // Broadcast to all authenticated clients
 export function broadcast(message) {
   const data = JSON.stringify(message);